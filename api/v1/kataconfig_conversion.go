@@ -0,0 +1,283 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+
+	kataconfigurationv2 "github.com/openshift/sandboxed-containers-operator/api/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+)
+
+const (
+	// v2SpecAnnotation holds the v2 spec of a KataConfig converted to v1 when it has settings
+	// v1 cannot represent
+	v2SpecAnnotation = "kataconfiguration.openshift.io/v2-spec"
+
+	// v2StatusAnnotation holds the v2 status of a KataConfig converted to v1 when it has fields
+	// v1 cannot represent
+	v2StatusAnnotation = "kataconfiguration.openshift.io/v2-status"
+)
+
+var _ conversion.Convertible = &KataConfig{}
+
+// ConvertTo converts this KataConfig to the hub version (v2)
+func (src *KataConfig) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*kataconfigurationv2.KataConfig)
+
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+
+	// Restore the v2 settings and status v1 cannot represent, the v1 ones take precedence
+	if err := restoreStashed(dst, v2SpecAnnotation, &dst.Spec); err != nil {
+		return err
+	}
+	if err := restoreStashed(dst, v2StatusAnnotation, &dst.Status); err != nil {
+		return err
+	}
+	convertSpecTo(&src.Spec, &dst.Spec)
+	convertStatusTo(&src.Status, &dst.Status)
+
+	return nil
+}
+
+// ConvertFrom converts from the hub version (v2) to this KataConfig
+func (dst *KataConfig) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*kataconfigurationv2.KataConfig)
+
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+
+	convertSpecFrom(&src.Spec, &dst.Spec)
+	convertStatusFrom(&src.Status, &dst.Status)
+
+	// Keep the v2 settings and status v1 cannot represent in annotations so that they are not
+	// lost when the KataConfig or its status is updated through v1
+	convertedSpec := kataconfigurationv2.KataConfigSpec{}
+	convertSpecTo(&dst.Spec, &convertedSpec)
+	if !equality.Semantic.DeepEqual(convertedSpec, src.Spec) {
+		if err := stash(dst, v2SpecAnnotation, src.Spec); err != nil {
+			return err
+		}
+	}
+	convertedStatus := kataconfigurationv2.KataConfigStatus{}
+	convertStatusTo(&dst.Status, &convertedStatus)
+	if !equality.Semantic.DeepEqual(convertedStatus, src.Status) {
+		if err := stash(dst, v2StatusAnnotation, src.Status); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// stash keeps the v2 value v1 cannot represent in the given annotation
+func stash(dst *KataConfig, annotation string, value interface{}) error {
+	stashed, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if dst.Annotations == nil {
+		dst.Annotations = map[string]string{}
+	}
+	dst.Annotations[annotation] = string(stashed)
+	return nil
+}
+
+// restoreStashed restores the v2 value kept in the given annotation and removes it
+func restoreStashed(dst *kataconfigurationv2.KataConfig, annotation string, value interface{}) error {
+	stashed, ok := dst.Annotations[annotation]
+	if !ok {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(stashed), value); err != nil {
+		return fmt.Errorf("Invalid %s annotation: %v", annotation, err)
+	}
+	delete(dst.Annotations, annotation)
+	if len(dst.Annotations) == 0 {
+		dst.Annotations = nil
+	}
+	return nil
+}
+
+// convertSpecTo converts the v1 spec settings to v2, leaving the v2 only settings untouched
+func convertSpecTo(src *KataConfigSpec, dst *kataconfigurationv2.KataConfigSpec) {
+	dst.KataConfigPoolSelector = src.KataConfigPoolSelector.DeepCopy()
+	dst.RuntimeClassName = src.RuntimeClassName
+	dst.RuntimeClassHandler = src.RuntimeClassHandler
+	dst.RuntimeClassOverhead = src.RuntimeClassOverhead.DeepCopy()
+	dst.RuntimeClasses = nil
+	for _, variant := range src.RuntimeClasses {
+		variant := variant.DeepCopy()
+		dst.RuntimeClasses = append(dst.RuntimeClasses, kataconfigurationv2.RuntimeClassVariant{
+			Name:               variant.Name,
+			Handler:            variant.Handler,
+			ConfigPath:         variant.ConfigPath,
+			AllowedAnnotations: variant.AllowedAnnotations,
+			Overhead:           variant.Overhead,
+			NodeSelector:       variant.NodeSelector,
+			Tolerations:        variant.Tolerations,
+		})
+	}
+}
+
+// convertSpecFrom converts the v2 spec settings v1 can represent
+func convertSpecFrom(src *kataconfigurationv2.KataConfigSpec, dst *KataConfigSpec) {
+	dst.KataConfigPoolSelector = src.KataConfigPoolSelector.DeepCopy()
+	dst.RuntimeClassName = src.RuntimeClassName
+	dst.RuntimeClassHandler = src.RuntimeClassHandler
+	dst.RuntimeClassOverhead = src.RuntimeClassOverhead.DeepCopy()
+	dst.RuntimeClasses = nil
+	for _, variant := range src.RuntimeClasses {
+		variant := variant.DeepCopy()
+		dst.RuntimeClasses = append(dst.RuntimeClasses, RuntimeClassVariant{
+			Name:               variant.Name,
+			Handler:            variant.Handler,
+			ConfigPath:         variant.ConfigPath,
+			AllowedAnnotations: variant.AllowedAnnotations,
+			Overhead:           variant.Overhead,
+			NodeSelector:       variant.NodeSelector,
+			Tolerations:        variant.Tolerations,
+		})
+	}
+}
+
+// convertStatusTo converts the v1 status fields to v2, leaving the v2 only fields untouched
+func convertStatusTo(src *KataConfigStatus, dst *kataconfigurationv2.KataConfigStatus) {
+	status := src.DeepCopy()
+	dst.RuntimeClass = status.RuntimeClass
+	dst.TotalNodesCount = status.TotalNodesCount
+	dst.BaseMcpGeneration = status.BaseMcpGeneration
+	dst.InstalledVersion = status.InstalledVersion
+	dst.Conditions = status.Conditions
+	dst.ObservedGeneration = status.ObservedGeneration
+	dst.RuntimeClasses = nil
+	for _, rcStatus := range status.RuntimeClasses {
+		dst.RuntimeClasses = append(dst.RuntimeClasses, kataconfigurationv2.RuntimeClassStatus(rcStatus))
+	}
+
+	dst.InstallationStatus.KataNodesStatus = convertNodesStatusTo(status.InstallationStatus.IsInProgress,
+		status.InstallationStatus.InProgress.InProgressNodesCount,
+		status.InstallationStatus.InProgress.BinariesInstalledNodesList,
+		status.InstallationStatus.Completed, status.InstallationStatus.Failed)
+	dst.UnInstallationStatus.KataNodesStatus = convertNodesStatusTo(status.UnInstallationStatus.InProgress.IsInProgress,
+		status.UnInstallationStatus.InProgress.InProgressNodesCount,
+		status.UnInstallationStatus.InProgress.BinariesUnInstalledNodesList,
+		status.UnInstallationStatus.Completed, status.UnInstallationStatus.Failed)
+	dst.UnInstallationStatus.ErrorMessage = status.UnInstallationStatus.ErrorMessage
+	dst.UpgradeStatus.KataNodesStatus = convertNodesStatusTo(status.Upgradestatus.IsInProgress,
+		status.Upgradestatus.InProgress.InProgressNodesCount,
+		status.Upgradestatus.InProgress.BinariesInstalledNodesList,
+		status.Upgradestatus.Completed, status.Upgradestatus.Failed)
+	dst.UpgradeStatus.FromVersion = status.Upgradestatus.FromVersion
+	dst.UpgradeStatus.ToVersion = status.Upgradestatus.ToVersion
+}
+
+// convertStatusFrom converts the v2 status fields v1 can represent
+func convertStatusFrom(src *kataconfigurationv2.KataConfigStatus, dst *KataConfigStatus) {
+	status := src.DeepCopy()
+	dst.RuntimeClass = status.RuntimeClass
+	dst.TotalNodesCount = status.TotalNodesCount
+	dst.BaseMcpGeneration = status.BaseMcpGeneration
+	dst.InstalledVersion = status.InstalledVersion
+	dst.Conditions = status.Conditions
+	dst.ObservedGeneration = status.ObservedGeneration
+	dst.RuntimeClasses = nil
+	for _, rcStatus := range status.RuntimeClasses {
+		dst.RuntimeClasses = append(dst.RuntimeClasses, RuntimeClassStatus(rcStatus))
+	}
+
+	installation := status.InstallationStatus
+	dst.InstallationStatus = KataInstallationStatus{
+		IsInProgress: convertIsInProgressFrom(installation.IsInProgress),
+		InProgress: KataInstallationInProgressStatus{
+			InProgressNodesCount:       installation.InProgress.InProgressNodesCount,
+			IsInProgress:               installation.IsInProgress,
+			BinariesInstalledNodesList: installation.InProgress.InProgressNodesList,
+		},
+		Completed: KataConfigCompletedStatus(installation.Completed),
+		Failed:    convertFailedStatusFrom(installation.Failed),
+	}
+
+	uninstallation := status.UnInstallationStatus
+	dst.UnInstallationStatus = KataUnInstallationStatus{
+		InProgress: KataUnInstallationInProgressStatus{
+			InProgressNodesCount:         uninstallation.InProgress.InProgressNodesCount,
+			IsInProgress:                 convertIsInProgressFrom(uninstallation.IsInProgress),
+			BinariesUnInstalledNodesList: uninstallation.InProgress.InProgressNodesList,
+		},
+		Completed:    KataConfigCompletedStatus(uninstallation.Completed),
+		Failed:       convertFailedStatusFrom(uninstallation.Failed),
+		ErrorMessage: uninstallation.ErrorMessage,
+	}
+
+	upgrade := status.UpgradeStatus
+	dst.Upgradestatus = KataUpgradeStatus{
+		FromVersion:  upgrade.FromVersion,
+		ToVersion:    upgrade.ToVersion,
+		IsInProgress: convertIsInProgressFrom(upgrade.IsInProgress),
+		InProgress: KataInstallationInProgressStatus{
+			InProgressNodesCount:       upgrade.InProgress.InProgressNodesCount,
+			IsInProgress:               upgrade.IsInProgress,
+			BinariesInstalledNodesList: upgrade.InProgress.InProgressNodesList,
+		},
+		Completed: KataConfigCompletedStatus(upgrade.Completed),
+		Failed:    convertFailedStatusFrom(upgrade.Failed),
+	}
+}
+
+// convertNodesStatusTo converts the v1 progress of an operation, spread over several
+// structures, to the single v2 one
+func convertNodesStatusTo(isInProgress corev1.ConditionStatus, inProgressNodesCount int, inProgressNodesList []string,
+	completed KataConfigCompletedStatus, failed KataFailedNodeStatus) kataconfigurationv2.KataNodesStatus {
+	nodesStatus := kataconfigurationv2.KataNodesStatus{
+		IsInProgress: isInProgress == corev1.ConditionTrue,
+		InProgress: kataconfigurationv2.KataInProgressNodesStatus{
+			InProgressNodesCount: inProgressNodesCount,
+			InProgressNodesList:  inProgressNodesList,
+		},
+		Completed: kataconfigurationv2.KataConfigCompletedStatus(completed),
+		Failed: kataconfigurationv2.KataFailedNodeStatus{
+			FailedNodesCount: failed.FailedNodesCount,
+			FailedReason:     failed.FailedReason,
+		},
+	}
+	for _, failedNode := range failed.FailedNodesList {
+		nodesStatus.Failed.FailedNodesList = append(nodesStatus.Failed.FailedNodesList,
+			kataconfigurationv2.FailedNodeStatus(failedNode))
+	}
+	return nodesStatus
+}
+
+// convertFailedStatusFrom converts the v2 failed nodes of an operation to v1
+func convertFailedStatusFrom(failed kataconfigurationv2.KataFailedNodeStatus) KataFailedNodeStatus {
+	failedStatus := KataFailedNodeStatus{
+		FailedNodesCount: failed.FailedNodesCount,
+		FailedReason:     failed.FailedReason,
+	}
+	for _, failedNode := range failed.FailedNodesList {
+		failedStatus.FailedNodesList = append(failedStatus.FailedNodesList, FailedNodeStatus(failedNode))
+	}
+	return failedStatus
+}
+
+// convertIsInProgressFrom converts the v2 progress flag to the v1 condition status
+func convertIsInProgressFrom(isInProgress bool) corev1.ConditionStatus {
+	if isInProgress {
+		return corev1.ConditionTrue
+	}
+	return corev1.ConditionFalse
+}
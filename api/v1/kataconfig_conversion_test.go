@@ -0,0 +1,235 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"testing"
+
+	kataconfigurationv2 "github.com/openshift/sandboxed-containers-operator/api/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/diff"
+)
+
+func newV1KataConfig() *KataConfig {
+	return &KataConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "example-kataconfig",
+			Generation: 2,
+			Finalizers: []string{"kataconfiguration.openshift.io/finalizer"},
+		},
+		Spec: KataConfigSpec{
+			KataConfigPoolSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"custom-kata-pool": "true"},
+			},
+			RuntimeClassName:    "kata-qemu",
+			RuntimeClassHandler: "kata",
+			RuntimeClassOverhead: corev1.ResourceList{
+				corev1.ResourceCPU: resource.MustParse("500m"),
+			},
+			RuntimeClasses: []RuntimeClassVariant{{
+				Name:               "kata-debug",
+				ConfigPath:         "/etc/kata-containers/configuration-debug.toml",
+				AllowedAnnotations: []string{"io.katacontainers.config.agent.debug_console_enabled"},
+				NodeSelector:       map[string]string{"debug": "true"},
+			}},
+		},
+		Status: KataConfigStatus{
+			RuntimeClass:    "kata-qemu",
+			TotalNodesCount: 3,
+			InstallationStatus: KataInstallationStatus{
+				IsInProgress: corev1.ConditionTrue,
+				InProgress: KataInstallationInProgressStatus{
+					InProgressNodesCount:       1,
+					IsInProgress:               true,
+					BinariesInstalledNodesList: []string{"worker0"},
+				},
+				Completed: KataConfigCompletedStatus{
+					CompletedNodesCount: 1,
+					CompletedNodesList:  []string{"worker1"},
+				},
+				Failed: KataFailedNodeStatus{
+					FailedNodesCount: 1,
+					FailedReason:     "Node worker2 is reporting: unexpected on-disk state",
+					FailedNodesList:  []FailedNodeStatus{{Name: "worker2", Error: "unexpected on-disk state"}},
+				},
+			},
+			UnInstallationStatus: KataUnInstallationStatus{
+				InProgress: KataUnInstallationInProgressStatus{
+					IsInProgress: corev1.ConditionFalse,
+				},
+				ErrorMessage: "Existing pods using Kata Runtime found",
+			},
+			Upgradestatus: KataUpgradeStatus{
+				FromVersion:  "1.0.0",
+				ToVersion:    "1.0.1",
+				IsInProgress: corev1.ConditionFalse,
+			},
+			BaseMcpGeneration: 4,
+			InstalledVersion:  "1.0.1",
+			RuntimeClasses: []RuntimeClassStatus{
+				{Name: "kata-debug", Handler: "kata-debug", Ready: true},
+			},
+			Conditions: []metav1.Condition{{
+				Type:   KataConfigReady,
+				Status: metav1.ConditionFalse,
+				Reason: "Installing",
+			}},
+			ObservedGeneration: 2,
+		},
+	}
+}
+
+func TestConvertRoundTripFromV1(t *testing.T) {
+	original := newV1KataConfig()
+
+	hub := &kataconfigurationv2.KataConfig{}
+	if err := original.ConvertTo(hub); err != nil {
+		t.Fatalf("ConvertTo failed: %v", err)
+	}
+
+	converted := &KataConfig{}
+	if err := converted.ConvertFrom(hub); err != nil {
+		t.Fatalf("ConvertFrom failed: %v", err)
+	}
+
+	if !equality.Semantic.DeepEqual(original, converted) {
+		t.Errorf("KataConfig changed after a round trip through v2:\n%s", diff.ObjectReflectDiff(original, converted))
+	}
+}
+
+func TestConvertRoundTripFromV2(t *testing.T) {
+	original := &kataconfigurationv2.KataConfig{}
+	if err := newV1KataConfig().ConvertTo(original); err != nil {
+		t.Fatalf("ConvertTo failed: %v", err)
+	}
+	original.Status.UnInstallationStatus.IsInProgress = true
+	original.Status.UnInstallationStatus.InProgress.InProgressNodesList = []string{"worker1"}
+
+	/* The status fields v1 cannot represent */
+	lastAttemptTime := metav1.Unix(1600000000, 0)
+	status := &original.Status
+	status.PoolName = "batch"
+	status.ClusterTopology = kataconfigurationv2.ClusterTopologyHostedControlPlane
+	status.NodePoolConfigMap = "sandboxed-containers-nodepool-config-batch"
+	status.MachineConfigPools = []kataconfigurationv2.MachineConfigPoolStatus{{Name: "kata-oc-batch", MachineCount: 2}}
+	status.UnInstallationStatus.Phase = kataconfigurationv2.UninstallPhaseBlockedByPods
+	status.UnInstallationStatus.BlockingPods = []kataconfigurationv2.BlockingPod{{Namespace: "default", Name: "kata-pod", NodeName: "worker1"}}
+	status.UnInstallationStatus.BlockingPodsCount = 1
+	status.Rollback = &kataconfigurationv2.RollbackStatus{
+		Phase:     kataconfigurationv2.RollbackPhaseInProgress,
+		Reason:    "The MachineConfigPool is degraded",
+		StartTime: lastAttemptTime,
+	}
+	status.Canary = &kataconfigurationv2.CanaryStatus{
+		Phase: kataconfigurationv2.CanaryPhaseTesting,
+		Nodes: []kataconfigurationv2.CanaryNodeStatus{{Name: "worker0", Passed: true}},
+	}
+	status.NodeEligibility = []kataconfigurationv2.NodeEligibilityStatus{{Name: "worker0", Eligible: true, KVM: true}}
+	status.NodeValidation = []kataconfigurationv2.NodeValidationStatus{{
+		Name:            "worker0",
+		Message:         "The smoke test pod failed",
+		Attempts:        2,
+		LastAttemptTime: &lastAttemptTime,
+	}}
+	status.LeavingNodes = []string{"worker2"}
+	status.PeerPods = &kataconfigurationv2.PeerPodsStatus{RuntimeClass: "kata-remote", Ready: true}
+	status.InstallationStatus.NodePoolNodes = []kataconfigurationv2.NodePoolNodeStatus{{Name: "worker0", InitialConfig: "config-1"}}
+	status.InstallationStatus.ContainerdNodes = []kataconfigurationv2.ContainerdNodeStatus{
+		{Name: "worker0", Phase: kataconfigurationv2.ContainerdNodeConfigured},
+	}
+
+	spoke := &KataConfig{}
+	if err := spoke.ConvertFrom(original); err != nil {
+		t.Fatalf("ConvertFrom failed: %v", err)
+	}
+	if _, ok := spoke.Annotations[v2StatusAnnotation]; !ok {
+		t.Fatalf("Expected the v2 status to be kept in the %s annotation", v2StatusAnnotation)
+	}
+
+	/* A v1 client updates the status */
+	spoke.Status.InstallationStatus.Completed.CompletedNodesCount++
+	original.Status.InstallationStatus.Completed.CompletedNodesCount++
+
+	converted := &kataconfigurationv2.KataConfig{}
+	if err := spoke.ConvertTo(converted); err != nil {
+		t.Fatalf("ConvertTo failed: %v", err)
+	}
+
+	if !equality.Semantic.DeepEqual(original, converted) {
+		t.Errorf("KataConfig changed after a round trip through v1:\n%s", diff.ObjectReflectDiff(original, converted))
+	}
+}
+
+func TestConvertStatusToV2(t *testing.T) {
+	hub := &kataconfigurationv2.KataConfig{}
+	if err := newV1KataConfig().ConvertTo(hub); err != nil {
+		t.Fatalf("ConvertTo failed: %v", err)
+	}
+
+	if !hub.Status.InstallationStatus.IsInProgress {
+		t.Errorf("Expected the installation to be in progress")
+	}
+	if hub.Status.UnInstallationStatus.IsInProgress || hub.Status.UpgradeStatus.IsInProgress {
+		t.Errorf("Expected neither the uninstallation nor the upgrade to be in progress")
+	}
+	if list := hub.Status.InstallationStatus.InProgress.InProgressNodesList; len(list) != 1 || list[0] != "worker0" {
+		t.Errorf("Unexpected in progress nodes %v", list)
+	}
+	if hub.Status.BaseMcpGeneration != 4 {
+		t.Errorf("Unexpected base MachineConfigPool generation %d", hub.Status.BaseMcpGeneration)
+	}
+}
+
+func TestConvertKeepsV2OnlySpec(t *testing.T) {
+	vcpus := int32(2)
+	original := &kataconfigurationv2.KataConfig{}
+	if err := newV1KataConfig().ConvertTo(original); err != nil {
+		t.Fatalf("ConvertTo failed: %v", err)
+	}
+	original.Spec.HypervisorConfig = &kataconfigurationv2.HypervisorConfig{
+		DefaultVCPUs: &vcpus,
+		KernelParams: []string{"agent.log=debug"},
+	}
+
+	spoke := &KataConfig{}
+	if err := spoke.ConvertFrom(original); err != nil {
+		t.Fatalf("ConvertFrom failed: %v", err)
+	}
+	if _, ok := spoke.Annotations[v2SpecAnnotation]; !ok {
+		t.Fatalf("Expected the v2 spec to be kept in the %s annotation", v2SpecAnnotation)
+	}
+
+	/* A v1 client changes a setting known to v1 */
+	spoke.Spec.RuntimeClassName = "kata-vm"
+
+	converted := &kataconfigurationv2.KataConfig{}
+	if err := spoke.ConvertTo(converted); err != nil {
+		t.Fatalf("ConvertTo failed: %v", err)
+	}
+
+	if _, ok := converted.Annotations[v2SpecAnnotation]; ok {
+		t.Errorf("Expected the %s annotation to be removed", v2SpecAnnotation)
+	}
+	if converted.Spec.RuntimeClassName != "kata-vm" {
+		t.Errorf("Expected the v1 change to be kept, got runtimeClassName %s", converted.Spec.RuntimeClassName)
+	}
+	if !equality.Semantic.DeepEqual(original.Spec.HypervisorConfig, converted.Spec.HypervisorConfig) {
+		t.Errorf("hypervisorConfig changed after a round trip through v1:\n%s",
+			diff.ObjectReflectDiff(original.Spec.HypervisorConfig, converted.Spec.HypervisorConfig))
+	}
+}
@@ -16,6 +16,7 @@ package v1
 
 import (
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -29,6 +30,115 @@ type KataConfigSpec struct {
 	// +optional
 	// +nullable
 	KataConfigPoolSelector *metav1.LabelSelector `json:"kataConfigPoolSelector"`
+
+	// RuntimeClassName is the name of the RuntimeClass created for the kata runtime
+	// if not specified, "kata" is used
+	// +optional
+	RuntimeClassName string `json:"runtimeClassName,omitempty"`
+
+	// RuntimeClassHandler is the CRI-O runtime handler referenced by the RuntimeClass.
+	// It must name a runtime configured in CRI-O on the selected nodes
+	// if not specified, "kata" is used
+	// +optional
+	RuntimeClassHandler string `json:"runtimeClassHandler,omitempty"`
+
+	// RuntimeClassOverhead is the fixed pod overhead set on the RuntimeClass, only
+	// cpu and memory are accepted
+	// if not specified, 250m of cpu and 350Mi of memory are used
+	// +optional
+	RuntimeClassOverhead corev1.ResourceList `json:"runtimeClassOverhead,omitempty"`
+
+	// RuntimeClasses is a list of additional RuntimeClasses, each one backed by its own
+	// CRI-O runtime handler
+	// +optional
+	RuntimeClasses []RuntimeClassVariant `json:"runtimeClasses,omitempty"`
+}
+
+// RuntimeClassVariant describes an additional RuntimeClass and the CRI-O runtime handler
+// rendered for it on the selected nodes
+type RuntimeClassVariant struct {
+	// Name is the name of the RuntimeClass
+	Name string `json:"name"`
+
+	// Handler is the name of the CRI-O runtime handler
+	// if not specified, the RuntimeClass name is used
+	// +optional
+	Handler string `json:"handler,omitempty"`
+
+	// ConfigPath is the kata configuration file used by the runtime handler
+	// if not specified, the default kata configuration is used
+	// +optional
+	ConfigPath string `json:"configPath,omitempty"`
+
+	// AllowedAnnotations is the list of kata annotations pods are allowed to set
+	// when using this runtime handler, e.g. io.katacontainers.config.agent.debug_console_enabled
+	// +optional
+	AllowedAnnotations []string `json:"allowedAnnotations,omitempty"`
+
+	// Overhead is the fixed pod overhead set on the RuntimeClass
+	// if not specified, the overhead of the default RuntimeClass is used
+	// +optional
+	Overhead corev1.ResourceList `json:"overhead,omitempty"`
+
+	// NodeSelector restricts the nodes pods using the RuntimeClass are scheduled on
+	// if not specified, the nodes selected by KataConfigPoolSelector are used
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Tolerations are added to pods using the RuntimeClass
+	// +optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+}
+
+// GetHandler returns the CRI-O runtime handler of the variant
+func (v *RuntimeClassVariant) GetHandler() string {
+	if v.Handler == "" {
+		return v.Name
+	}
+	return v.Handler
+}
+
+const (
+	// DefaultRuntimeClassName is the RuntimeClass name used when none is specified
+	DefaultRuntimeClassName = "kata"
+
+	// DefaultRuntimeClassHandler is the CRI-O runtime handler shipped by the
+	// sandboxed-containers extension
+	DefaultRuntimeClassHandler = "kata"
+)
+
+// DefaultRuntimeClassOverhead returns the pod overhead used when none is specified. These are
+// the same values as upstream kata-deploy uses, see
+// https://github.com/kata-containers/packaging/blob/f17450317563b6e4d6b1a71f0559360b37783e19/kata-deploy/k8s-1.18/kata-runtimeClasses.yaml#L7
+func DefaultRuntimeClassOverhead() corev1.ResourceList {
+	return corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("250m"),
+		corev1.ResourceMemory: resource.MustParse("350Mi"),
+	}
+}
+
+// GetRuntimeClassName returns the RuntimeClass name requested in the spec, or the default one
+func (s *KataConfigSpec) GetRuntimeClassName() string {
+	if s.RuntimeClassName == "" {
+		return DefaultRuntimeClassName
+	}
+	return s.RuntimeClassName
+}
+
+// GetRuntimeClassHandler returns the CRI-O runtime handler requested in the spec, or the default one
+func (s *KataConfigSpec) GetRuntimeClassHandler() string {
+	if s.RuntimeClassHandler == "" {
+		return DefaultRuntimeClassHandler
+	}
+	return s.RuntimeClassHandler
+}
+
+// GetRuntimeClassOverhead returns the pod overhead requested in the spec, or the default one
+func (s *KataConfigSpec) GetRuntimeClassOverhead() corev1.ResourceList {
+	if len(s.RuntimeClassOverhead) == 0 {
+		return DefaultRuntimeClassOverhead()
+	}
+	return s.RuntimeClassOverhead
 }
 
 // KataConfigStatus defines the observed state of KataConfig
@@ -52,6 +162,61 @@ type KataConfigStatus struct {
 	Upgradestatus KataUpgradeStatus `json:"upgradeStatus,omitempty"`
 
 	BaseMcpGeneration int64 `json:"prevMcpGeneration"`
+
+	// InstalledVersion is the operator version the kata runtime is installed with
+	// +optional
+	InstalledVersion string `json:"installedVersion,omitempty"`
+
+	// RuntimeClasses reflects the readiness of the additional RuntimeClasses
+	// +optional
+	RuntimeClasses []RuntimeClassStatus `json:"runtimeClasses,omitempty"`
+
+	// Conditions represent the latest available observations of the KataConfig state
+	// +optional
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+
+	// ObservedGeneration is the KataConfig generation the conditions were computed for
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+// Condition types reported in the KataConfig status
+const (
+	// KataConfigReady is true when the kata runtime is installed on all the selected nodes
+	// and all the RuntimeClasses are usable
+	KataConfigReady = "Ready"
+
+	// KataConfigInstalling is true while the kata runtime is being installed
+	KataConfigInstalling = "Installing"
+
+	// KataConfigUninstalling is true while the kata runtime is being uninstalled
+	KataConfigUninstalling = "Uninstalling"
+
+	// KataConfigDegraded is true when nodes failed to install or uninstall the kata runtime
+	KataConfigDegraded = "Degraded"
+
+	// KataConfigUpgrading is true while the kata runtime is being upgraded
+	KataConfigUpgrading = "Upgrading"
+)
+
+// RuntimeClassStatus reflects the readiness of an additional RuntimeClass
+type RuntimeClassStatus struct {
+	// Name of the RuntimeClass
+	Name string `json:"name"`
+
+	// Handler is the CRI-O runtime handler of the RuntimeClass
+	Handler string `json:"handler"`
+
+	// Ready is true once the runtime handler is rolled out and the RuntimeClass exists
+	Ready bool `json:"ready"`
+
+	// Message explains why the RuntimeClass is not ready
+	// +optional
+	Message string `json:"message,omitempty"`
 }
 
 // +genclient
@@ -61,6 +226,8 @@ type KataConfigStatus struct {
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 // +kubebuilder:resource:path=kataconfigs,scope=Cluster
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=".metadata.creationTimestamp"
 type KataConfig struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
@@ -159,6 +326,29 @@ type KataUnInstallationInProgressStatus struct {
 
 // KataUpgradeStatus reflects the status of the ongoing kata upgrade
 type KataUpgradeStatus struct {
+	// FromVersion is the operator version the kata runtime was installed with
+	// +optional
+	FromVersion string `json:"fromVersion,omitempty"`
+
+	// ToVersion is the operator version the kata runtime is upgraded to
+	// +optional
+	ToVersion string `json:"toVersion,omitempty"`
+
+	// IsInProgress reflects the current state of upgrading or not upgrading
+	// +optional
+	IsInProgress corev1.ConditionStatus `json:"isInProgress,omitempty"`
+
+	// InProgress reflects the status of nodes that are in the process of kata upgrade
+	// +optional
+	InProgress KataInstallationInProgressStatus `json:"inProgress,omitempty"`
+
+	// Completed reflects the status of nodes that have completed kata upgrade
+	// +optional
+	Completed KataConfigCompletedStatus `json:"completed,omitempty"`
+
+	// Failed reflects the status of nodes that have failed kata upgrade
+	// +optional
+	Failed KataFailedNodeStatus `json:"failed,omitempty"`
 }
 
 // FailedNodeStatus holds the name and the error message of the failed node
@@ -21,6 +21,7 @@ limitations under the License.
 package v1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
@@ -127,6 +128,20 @@ func (in *KataConfigSpec) DeepCopyInto(out *KataConfigSpec) {
 		*out = new(metav1.LabelSelector)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.RuntimeClassOverhead != nil {
+		in, out := &in.RuntimeClassOverhead, &out.RuntimeClassOverhead
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.RuntimeClasses != nil {
+		in, out := &in.RuntimeClasses, &out.RuntimeClasses
+		*out = make([]RuntimeClassVariant, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataConfigSpec.
@@ -144,7 +159,19 @@ func (in *KataConfigStatus) DeepCopyInto(out *KataConfigStatus) {
 	*out = *in
 	in.InstallationStatus.DeepCopyInto(&out.InstallationStatus)
 	in.UnInstallationStatus.DeepCopyInto(&out.UnInstallationStatus)
-	out.Upgradestatus = in.Upgradestatus
+	in.Upgradestatus.DeepCopyInto(&out.Upgradestatus)
+	if in.RuntimeClasses != nil {
+		in, out := &in.RuntimeClasses, &out.RuntimeClasses
+		*out = make([]RuntimeClassStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataConfigStatus.
@@ -256,6 +283,9 @@ func (in *KataUnInstallationStatus) DeepCopy() *KataUnInstallationStatus {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *KataUpgradeStatus) DeepCopyInto(out *KataUpgradeStatus) {
 	*out = *in
+	in.InProgress.DeepCopyInto(&out.InProgress)
+	in.Completed.DeepCopyInto(&out.Completed)
+	in.Failed.DeepCopyInto(&out.Failed)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataUpgradeStatus.
@@ -267,3 +297,59 @@ func (in *KataUpgradeStatus) DeepCopy() *KataUpgradeStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RuntimeClassStatus) DeepCopyInto(out *RuntimeClassStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuntimeClassStatus.
+func (in *RuntimeClassStatus) DeepCopy() *RuntimeClassStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RuntimeClassStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RuntimeClassVariant) DeepCopyInto(out *RuntimeClassVariant) {
+	*out = *in
+	if in.AllowedAnnotations != nil {
+		in, out := &in.AllowedAnnotations, &out.AllowedAnnotations
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Overhead != nil {
+		in, out := &in.Overhead, &out.Overhead
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]corev1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuntimeClassVariant.
+func (in *RuntimeClassVariant) DeepCopy() *RuntimeClassVariant {
+	if in == nil {
+		return nil
+	}
+	out := new(RuntimeClassVariant)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,987 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// KataConfigSpec defines the desired state of KataConfig
+type KataConfigSpec struct {
+	// KataConfigPoolSelector is used to filter the worker nodes
+	// if not specified, all worker nodes are selected
+	// +optional
+	// +nullable
+	KataConfigPoolSelector *metav1.LabelSelector `json:"kataConfigPoolSelector"`
+
+	// MachineConfigPoolNames lists existing MachineConfigPools to install the kata runtime on,
+	// instead of moving the nodes selected by the kataConfigPoolSelector to a custom pool. The
+	// pools must select the MachineConfigs whose role label is their name
+	// +optional
+	MachineConfigPoolNames []string `json:"machineConfigPoolNames,omitempty"`
+
+	// RuntimeClassName is the name of the RuntimeClass created for the kata runtime
+	// if not specified, "kata" is used, suffixed for an alternative hypervisor, e.g. "kata-clh"
+	// +optional
+	RuntimeClassName string `json:"runtimeClassName,omitempty"`
+
+	// RuntimeClassHandler is the CRI-O runtime handler referenced by the RuntimeClass.
+	// It must name a runtime configured in CRI-O on the selected nodes
+	// if not specified, "kata" is used, suffixed for an alternative hypervisor, e.g. "kata-clh"
+	// +optional
+	RuntimeClassHandler string `json:"runtimeClassHandler,omitempty"`
+
+	// RuntimeClassOverhead is the fixed pod overhead set on the RuntimeClass, only
+	// cpu and memory are accepted
+	// if not specified, 250m of cpu and 350Mi of memory are used
+	// +optional
+	RuntimeClassOverhead corev1.ResourceList `json:"runtimeClassOverhead,omitempty"`
+
+	// RuntimeClasses is a list of additional RuntimeClasses, each one backed by its own
+	// CRI-O runtime handler
+	// +optional
+	RuntimeClasses []RuntimeClassVariant `json:"runtimeClasses,omitempty"`
+
+	// Hypervisor selects the kata configuration of the hypervisor starting the virtual
+	// machines. The default RuntimeClass and runtime handler are named after it, e.g. kata-clh
+	// for cloud-hypervisor
+	// if not specified, qemu is used
+	// +optional
+	Hypervisor Hypervisor `json:"hypervisor,omitempty"`
+
+	// HypervisorConfig tunes the virtual machines started by the kata runtime
+	// if not specified, the defaults of the kata configuration are used
+	// +optional
+	HypervisorConfig *HypervisorConfig `json:"hypervisorConfig,omitempty"`
+
+	// Debug enables the debug output of the kata runtime, agent and hypervisor, and
+	// the agent debug console
+	// +optional
+	Debug bool `json:"debug,omitempty"`
+
+	// PreflightPolicy tells what to do when the pre-flight check finds no selected node able
+	// to run the kata runtime: Enforce refuses the installation, Warn proceeds with it
+	// if not specified, Warn is used
+	// +optional
+	PreflightPolicy PreflightPolicy `json:"preflightPolicy,omitempty"`
+
+	// MaxUnavailable is the number or percentage of nodes of the kata-oc MachineConfigPool
+	// that can be updated at the same time. It only applies when the kataConfigPoolSelector
+	// selects a custom pool
+	// if not specified, the MachineConfigPool default of 1 is used
+	// +optional
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+
+	// Paused pauses the rollout of the kata-oc MachineConfigPool, e.g. outside of a
+	// maintenance window. It only applies when the kataConfigPoolSelector selects a
+	// custom pool
+	// +optional
+	Paused bool `json:"paused,omitempty"`
+
+	// Canary installs the kata runtime on a subset of the selected nodes first and runs
+	// a smoke test on them before the installation is extended to all the selected nodes.
+	// It requires the kataConfigPoolSelector to select a custom pool and only applies to
+	// the first installation
+	// +optional
+	Canary *CanaryConfig `json:"canary,omitempty"`
+
+	// FailurePolicy tells what to do when the pool degrades during the first installation:
+	// Halt leaves the nodes as they are, Rollback deletes the MachineConfigs and waits for
+	// the pool to recover
+	// if not specified, Halt is used
+	// +optional
+	FailurePolicy FailurePolicy `json:"failurePolicy,omitempty"`
+
+	// RollbackThreshold tells when the Rollback failure policy kicks in
+	// if not specified, the installation is rolled back as soon as a node is degraded
+	// +optional
+	RollbackThreshold *RollbackThreshold `json:"rollbackThreshold,omitempty"`
+
+	// UninstallPolicy tells what to do with the pods using the kata runtime when the
+	// KataConfig is deleted: Block waits for them to be deleted, Evict evicts them honoring
+	// their PodDisruptionBudgets, Force deletes them
+	// if not specified, Block is used
+	// +optional
+	UninstallPolicy UninstallPolicy `json:"uninstallPolicy,omitempty"`
+
+	// UninstallTimeout is how long the Evict uninstall policy waits for the pods to be
+	// evicted before deleting the remaining ones, counted from the deletion of the KataConfig
+	// if not specified, the pods are evicted until they are all gone
+	// +optional
+	UninstallTimeout *metav1.Duration `json:"uninstallTimeout,omitempty"`
+
+	// PeerPods runs the pods using the kata-remote RuntimeClass in virtual machines created
+	// by a cloud provider instead of on the nodes, for nodes without nested virtualization
+	// +optional
+	PeerPods *PeerPodsConfig `json:"peerPods,omitempty"`
+}
+
+// PeerPodsConfig configures the remote hypervisor creating the peer pod virtual machines
+type PeerPodsConfig struct {
+	// Provider is the cloud provider creating the peer pod virtual machines
+	// +kubebuilder:validation:Enum=libvirt
+	Provider string `json:"provider"`
+
+	// CredentialsSecretName is the Secret of the operator namespace holding the settings and
+	// credentials of the provider
+	// +kubebuilder:validation:MinLength=1
+	CredentialsSecretName string `json:"credentialsSecretName"`
+}
+
+// UninstallPolicy tells what to do with the pods using the kata runtime on uninstallation
+// +kubebuilder:validation:Enum=Block;Evict;Force
+type UninstallPolicy string
+
+const (
+	// UninstallPolicyBlock waits for the pods to be deleted by their owners
+	UninstallPolicyBlock UninstallPolicy = "Block"
+
+	// UninstallPolicyEvict evicts the pods through the eviction API
+	UninstallPolicyEvict UninstallPolicy = "Evict"
+
+	// UninstallPolicyForce deletes the pods
+	UninstallPolicyForce UninstallPolicy = "Force"
+)
+
+// GetUninstallPolicy returns the uninstall policy requested in the spec, or the default one
+func (s *KataConfigSpec) GetUninstallPolicy() UninstallPolicy {
+	if s.UninstallPolicy == "" {
+		return UninstallPolicyBlock
+	}
+	return s.UninstallPolicy
+}
+
+// FailurePolicy tells what to do when the pool degrades during the installation
+// +kubebuilder:validation:Enum=Halt;Rollback
+type FailurePolicy string
+
+const (
+	// FailurePolicyHalt leaves the degraded pool as it is
+	FailurePolicyHalt FailurePolicy = "Halt"
+
+	// FailurePolicyRollback deletes the MachineConfigs installing the kata runtime
+	FailurePolicyRollback FailurePolicy = "Rollback"
+)
+
+// GetFailurePolicy returns the failure policy requested in the spec, or the default one
+func (s *KataConfigSpec) GetFailurePolicy() FailurePolicy {
+	if s.FailurePolicy == "" {
+		return FailurePolicyHalt
+	}
+	return s.FailurePolicy
+}
+
+// RollbackThreshold tells when a degraded installation is rolled back. The installation is
+// rolled back as soon as one of the thresholds is reached
+type RollbackThreshold struct {
+	// DegradedNodes is the number of degraded nodes that triggers the rollback
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	DegradedNodes *int32 `json:"degradedNodes,omitempty"`
+
+	// Timeout is how long the pool can stay degraded before the rollback
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// CanaryConfig selects the nodes the kata runtime is installed on first
+type CanaryConfig struct {
+	// NodeSelector selects the canary nodes among the nodes selected by the
+	// kataConfigPoolSelector
+	NodeSelector map[string]string `json:"nodeSelector"`
+}
+
+// PreflightPolicy tells what to do when no selected node is eligible for the kata runtime
+// +kubebuilder:validation:Enum=Enforce;Warn
+type PreflightPolicy string
+
+const (
+	// PreflightPolicyEnforce refuses the installation when no selected node is eligible
+	PreflightPolicyEnforce PreflightPolicy = "Enforce"
+
+	// PreflightPolicyWarn installs the kata runtime even when no selected node is eligible
+	PreflightPolicyWarn PreflightPolicy = "Warn"
+)
+
+// GetPreflightPolicy returns the pre-flight policy requested in the spec, or the default one
+func (s *KataConfigSpec) GetPreflightPolicy() PreflightPolicy {
+	if s.PreflightPolicy == "" {
+		return PreflightPolicyWarn
+	}
+	return s.PreflightPolicy
+}
+
+// Hypervisor is the hypervisor the kata runtime starts the virtual machines with
+// +kubebuilder:validation:Enum=qemu;cloud-hypervisor;firecracker
+type Hypervisor string
+
+const (
+	// HypervisorQEMU is the hypervisor of the default kata configuration
+	HypervisorQEMU Hypervisor = "qemu"
+
+	// HypervisorCloudHypervisor is the hypervisor of the clh kata configuration shipped by kata-deploy
+	HypervisorCloudHypervisor Hypervisor = "cloud-hypervisor"
+
+	// HypervisorFirecracker is the hypervisor of the fc kata configuration shipped by kata-deploy
+	HypervisorFirecracker Hypervisor = "firecracker"
+)
+
+// hypervisorShortNames are the names kata gives the hypervisors in the names of their
+// configuration files
+var hypervisorShortNames = map[Hypervisor]string{
+	HypervisorQEMU:            "qemu",
+	HypervisorCloudHypervisor: "clh",
+	HypervisorFirecracker:     "fc",
+}
+
+// ShortName returns the name of the hypervisor in the name of its kata configuration file,
+// configuration-<name>.toml
+func (h Hypervisor) ShortName() string {
+	return hypervisorShortNames[h]
+}
+
+// GetHypervisor returns the hypervisor requested in the spec, or the default one
+func (s *KataConfigSpec) GetHypervisor() Hypervisor {
+	if s.Hypervisor == "" {
+		return HypervisorQEMU
+	}
+	return s.Hypervisor
+}
+
+// defaultNameSuffix returns the suffix of the default RuntimeClass and runtime handler names
+// telling the alternative hypervisors apart
+func (s *KataConfigSpec) defaultNameSuffix() string {
+	if s.GetHypervisor() == HypervisorQEMU {
+		return ""
+	}
+	return "-" + s.GetHypervisor().ShortName()
+}
+
+// HypervisorConfig holds the hypervisor settings rendered into a kata configuration drop-in
+// on the selected nodes
+type HypervisorConfig struct {
+	// DefaultVCPUs is the number of vCPUs the virtual machines start with
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	DefaultVCPUs *int32 `json:"defaultVCPUs,omitempty"`
+
+	// DefaultMemory is the memory in MiB the virtual machines start with
+	// +optional
+	// +kubebuilder:validation:Minimum=128
+	DefaultMemory *int32 `json:"defaultMemory,omitempty"`
+
+	// DefaultMaxMemory is the memory in MiB the virtual machines can grow up to
+	// by memory hotplug
+	// +optional
+	// +kubebuilder:validation:Minimum=128
+	DefaultMaxMemory *int32 `json:"defaultMaxMemory,omitempty"`
+
+	// KernelParams are additional parameters passed to the guest kernel
+	// +optional
+	KernelParams []string `json:"kernelParams,omitempty"`
+}
+
+// RuntimeClassVariant describes an additional RuntimeClass and the CRI-O runtime handler
+// rendered for it on the selected nodes
+type RuntimeClassVariant struct {
+	// Name is the name of the RuntimeClass
+	Name string `json:"name"`
+
+	// Handler is the name of the CRI-O runtime handler
+	// if not specified, the RuntimeClass name is used
+	// +optional
+	Handler string `json:"handler,omitempty"`
+
+	// ConfigPath is the kata configuration file used by the runtime handler
+	// if not specified, the default kata configuration is used
+	// +optional
+	ConfigPath string `json:"configPath,omitempty"`
+
+	// AllowedAnnotations is the list of kata annotations pods are allowed to set
+	// when using this runtime handler, e.g. io.katacontainers.config.agent.debug_console_enabled
+	// +optional
+	AllowedAnnotations []string `json:"allowedAnnotations,omitempty"`
+
+	// Overhead is the fixed pod overhead set on the RuntimeClass
+	// if not specified, the overhead of the default RuntimeClass is used
+	// +optional
+	Overhead corev1.ResourceList `json:"overhead,omitempty"`
+
+	// NodeSelector restricts the nodes pods using the RuntimeClass are scheduled on
+	// if not specified, the nodes selected by KataConfigPoolSelector are used
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Tolerations are added to pods using the RuntimeClass
+	// +optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+}
+
+// getRuntimeClassVariant returns the additional RuntimeClass with the given name, if any
+func (s *KataConfigSpec) getRuntimeClassVariant(name string) *RuntimeClassVariant {
+	for i := range s.RuntimeClasses {
+		if s.RuntimeClasses[i].Name == name {
+			return &s.RuntimeClasses[i]
+		}
+	}
+	return nil
+}
+
+// GetHandler returns the CRI-O runtime handler of the variant
+func (v *RuntimeClassVariant) GetHandler() string {
+	if v.Handler == "" {
+		return v.Name
+	}
+	return v.Handler
+}
+
+const (
+	// DefaultRuntimeClassName is the RuntimeClass name used when none is specified
+	DefaultRuntimeClassName = "kata"
+
+	// DefaultRuntimeClassHandler is the CRI-O runtime handler shipped by the
+	// sandboxed-containers extension
+	DefaultRuntimeClassHandler = "kata"
+
+	// PeerPodsRuntimeClassHandler is the CRI-O runtime handler of the peer pods mode, the
+	// RuntimeClass running pods as peer pods has the same name
+	PeerPodsRuntimeClassHandler = "kata-remote"
+
+	// DefaultPoolName is the name of the custom MachineConfigPool of the first KataConfig,
+	// the ones of the other KataConfigs are suffixed with their name
+	DefaultPoolName = "kata-oc"
+)
+
+// DefaultRuntimeClassOverhead returns the pod overhead used when none is specified. These are
+// the same values as upstream kata-deploy uses, see
+// https://github.com/kata-containers/packaging/blob/f17450317563b6e4d6b1a71f0559360b37783e19/kata-deploy/k8s-1.18/kata-runtimeClasses.yaml#L7
+func DefaultRuntimeClassOverhead() corev1.ResourceList {
+	return corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("250m"),
+		corev1.ResourceMemory: resource.MustParse("350Mi"),
+	}
+}
+
+// GetRuntimeClassName returns the RuntimeClass name requested in the spec, or the default one
+func (s *KataConfigSpec) GetRuntimeClassName() string {
+	if s.RuntimeClassName == "" {
+		return DefaultRuntimeClassName + s.defaultNameSuffix()
+	}
+	return s.RuntimeClassName
+}
+
+// GetRuntimeClassHandler returns the CRI-O runtime handler requested in the spec, or the default one
+func (s *KataConfigSpec) GetRuntimeClassHandler() string {
+	if s.RuntimeClassHandler == "" {
+		return DefaultRuntimeClassHandler + s.defaultNameSuffix()
+	}
+	return s.RuntimeClassHandler
+}
+
+// GetRuntimeClassOverhead returns the pod overhead requested in the spec, or the default one
+func (s *KataConfigSpec) GetRuntimeClassOverhead() corev1.ResourceList {
+	if len(s.RuntimeClassOverhead) == 0 {
+		return DefaultRuntimeClassOverhead()
+	}
+	return s.RuntimeClassOverhead
+}
+
+// NameSuffix returns the suffix of the names of the MachineConfigPool, MachineConfigs and
+// default RuntimeClass created for the KataConfig. It is empty for the first KataConfig.
+func (k *KataConfig) NameSuffix() string {
+	return strings.TrimPrefix(k.Status.PoolName, DefaultPoolName)
+}
+
+// GetRuntimeClassName returns the RuntimeClass name requested in the spec, or the default
+// one generated for the KataConfig
+func (k *KataConfig) GetRuntimeClassName() string {
+	if k.Spec.RuntimeClassName == "" {
+		return DefaultRuntimeClassName + k.Spec.defaultNameSuffix() + k.NameSuffix()
+	}
+	return k.Spec.RuntimeClassName
+}
+
+// GetPeerPodsRuntimeClassName returns the name of the RuntimeClass running pods as peer pods
+func (k *KataConfig) GetPeerPodsRuntimeClassName() string {
+	return PeerPodsRuntimeClassHandler + k.NameSuffix()
+}
+
+// KataConfigStatus defines the observed state of KataConfig
+type KataConfigStatus struct {
+	// RuntimeClass is the name of the runtime class used in CRIO configuration
+	RuntimeClass string `json:"runtimeClass"`
+
+	// PoolName is the name of the custom MachineConfigPool created for the KataConfig, the
+	// names of the MachineConfigs and default RuntimeClass created for it are derived from it.
+	// On Kubernetes, no MachineConfigPool is created, the name is only used to derive the others
+	// +optional
+	PoolName string `json:"poolName,omitempty"`
+
+	// ClusterTopology is the topology of the OpenShift cluster, which decides the
+	// MachineConfigPool the kata runtime is installed with
+	// +optional
+	ClusterTopology ClusterTopology `json:"clusterTopology,omitempty"`
+
+	// NodePoolConfigMap is the name of the ConfigMap holding the MachineConfig the NodePools
+	// must reference to install the kata runtime, on clusters with a hosted control plane
+	// +optional
+	NodePoolConfigMap string `json:"nodePoolConfigMap,omitempty"`
+
+	// TotalNodesCounts is the total number of worker nodes targeted by this CR
+	TotalNodesCount int `json:"totalNodesCount"`
+
+	// MachineConfigPools reflects the progress of each pool named in machineConfigPoolNames
+	// +optional
+	MachineConfigPools []MachineConfigPoolStatus `json:"machineConfigPools,omitempty"`
+
+	// InstallationStatus reflects the status of the ongoing kata installation
+	// +optional
+	InstallationStatus KataInstallationStatus `json:"installationStatus,omitempty"`
+
+	// UnInstallationStatus reflects the status of the ongoing kata uninstallation
+	// +optional
+	UnInstallationStatus KataUnInstallationStatus `json:"unInstallationStatus,omitempty"`
+
+	// UpgradeStatus reflects the status of the ongoing kata upgrade
+	// +optional
+	UpgradeStatus KataUpgradeStatus `json:"upgradeStatus,omitempty"`
+
+	// BaseMcpGeneration is the generation of the MachineConfigPool observed before
+	// the current operation changed its configuration
+	// +optional
+	BaseMcpGeneration int64 `json:"baseMcpGeneration,omitempty"`
+
+	// InstalledVersion is the operator version the kata runtime is installed with
+	// +optional
+	InstalledVersion string `json:"installedVersion,omitempty"`
+
+	// RuntimeClasses reflects the readiness of the additional RuntimeClasses
+	// +optional
+	RuntimeClasses []RuntimeClassStatus `json:"runtimeClasses,omitempty"`
+
+	// PeerPods reflects the deployment of the peer pods mode
+	// +optional
+	PeerPods *PeerPodsStatus `json:"peerPods,omitempty"`
+
+	// Conditions represent the latest available observations of the KataConfig state
+	// +optional
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+
+	// NodeEligibility reflects the result of the pre-flight check on each selected node
+	// +optional
+	NodeEligibility []NodeEligibilityStatus `json:"nodeEligibility,omitempty"`
+
+	// NodeValidation reflects the result of the validation pod run on each node of the pool
+	// once the kata runtime is installed
+	// +optional
+	NodeValidation []NodeValidationStatus `json:"nodeValidation,omitempty"`
+
+	// Rollback reflects the rollback of a degraded installation
+	// +optional
+	Rollback *RollbackStatus `json:"rollback,omitempty"`
+
+	// Canary reflects the progress of the canary installation
+	// +optional
+	Canary *CanaryStatus `json:"canary,omitempty"`
+
+	// DebugNodes is the list of nodes running the kata runtime with the debug mode enabled
+	// +optional
+	DebugNodes []string `json:"debugNodes,omitempty"`
+
+	// PoolSelector is the kataConfigPoolSelector the kata-oc MachineConfigPool was last
+	// updated with
+	// +optional
+	// +nullable
+	PoolSelector *metav1.LabelSelector `json:"poolSelector,omitempty"`
+
+	// LeavingNodes is the list of nodes that left the kataConfigPoolSelector and are rolling
+	// back to the configuration of their original pool
+	// +optional
+	LeavingNodes []string `json:"leavingNodes,omitempty"`
+
+	// ObservedGeneration is the KataConfig generation the conditions were computed for
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+// Condition types reported in the KataConfig status
+const (
+	// KataConfigReady is true when the kata runtime is installed on all the selected nodes
+	// and all the RuntimeClasses are usable
+	KataConfigReady = "Ready"
+
+	// KataConfigInstalling is true while the kata runtime is being installed
+	KataConfigInstalling = "Installing"
+
+	// KataConfigUninstalling is true while the kata runtime is being uninstalled
+	KataConfigUninstalling = "Uninstalling"
+
+	// KataConfigDegraded is true when nodes failed to install or uninstall the kata runtime
+	KataConfigDegraded = "Degraded"
+
+	// KataConfigUpgrading is true while the kata runtime is being upgraded
+	KataConfigUpgrading = "Upgrading"
+
+	// KataConfigNodesEligible is true when the pre-flight check found all the selected
+	// nodes able to run the kata runtime
+	KataConfigNodesEligible = "NodesEligible"
+
+	// KataConfigPaused is true while the rollout of the kata-oc MachineConfigPool is paused
+	KataConfigPaused = "Paused"
+
+	// KataConfigRolledBack is true when a degraded installation was rolled back
+	KataConfigRolledBack = "RolledBack"
+)
+
+// NodeEligibilityStatus reflects whether a node is able to run the kata runtime
+type NodeEligibilityStatus struct {
+	// Name of the node
+	Name string `json:"name"`
+
+	// Eligible is true when the node is able to run virtual machines
+	Eligible bool `json:"eligible"`
+
+	// KVM is true when /dev/kvm is present on the node
+	KVM bool `json:"kvm"`
+
+	// NestedVirtualization is true when the KVM module of the node allows nested virtualization
+	// +optional
+	NestedVirtualization bool `json:"nestedVirtualization,omitempty"`
+
+	// CPUVirtualizationFlags lists the hardware virtualization flags of the node CPU,
+	// e.g. vmx or svm
+	// +optional
+	CPUVirtualizationFlags []string `json:"cpuVirtualizationFlags,omitempty"`
+
+	// Message explains why the node is not eligible
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// RollbackPhase is a step of the rollback of a degraded installation
+// +kubebuilder:validation:Enum=InProgress;Completed
+type RollbackPhase string
+
+const (
+	// RollbackPhaseInProgress waits for the pool to recover from the deleted MachineConfigs
+	RollbackPhaseInProgress RollbackPhase = "InProgress"
+
+	// RollbackPhaseCompleted halts the installation until the KataConfig is changed
+	RollbackPhaseCompleted RollbackPhase = "Completed"
+)
+
+// RollbackStatus reflects the rollback of a degraded installation
+type RollbackStatus struct {
+	// Phase is the current step of the rollback
+	Phase RollbackPhase `json:"phase"`
+
+	// Reason explains why the installation was rolled back
+	Reason string `json:"reason"`
+
+	// StartTime is when the rollback started
+	StartTime metav1.Time `json:"startTime"`
+
+	// ObservedGeneration is the KataConfig generation that was rolled back, the installation
+	// is retried once the KataConfig changes
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+// NodeValidationStatus reflects whether a pod using the kata RuntimeClass ran in a virtual
+// machine on a node
+type NodeValidationStatus struct {
+	// Name of the node
+	Name string `json:"name"`
+
+	// Validated is true when the validation pod ran on a guest kernel different from the
+	// kernel of the node
+	Validated bool `json:"validated"`
+
+	// StartupLatency is the time from the creation of the validation pod to the start of
+	// its container
+	// +optional
+	StartupLatency *metav1.Duration `json:"startupLatency,omitempty"`
+
+	// Message explains why the node failed the validation
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// Attempts is the number of validation pods that ran on the node since the last rollout.
+	// A failed validation is retried with a delay doubling with each attempt.
+	// +optional
+	Attempts int32 `json:"attempts,omitempty"`
+
+	// LastAttemptTime is when the last validation pod of the node completed
+	// +optional
+	LastAttemptTime *metav1.Time `json:"lastAttemptTime,omitempty"`
+}
+
+// CanaryPhase is a step of the canary installation
+// +kubebuilder:validation:Enum=Installing;Testing;Succeeded;Failed
+type CanaryPhase string
+
+const (
+	// CanaryPhaseInstalling waits for the canary nodes to install the kata runtime
+	CanaryPhaseInstalling CanaryPhase = "Installing"
+
+	// CanaryPhaseTesting runs the smoke test on the canary nodes
+	CanaryPhaseTesting CanaryPhase = "Testing"
+
+	// CanaryPhaseSucceeded extends the installation to all the selected nodes
+	CanaryPhaseSucceeded CanaryPhase = "Succeeded"
+
+	// CanaryPhaseFailed halts the installation until the KataConfig is changed
+	CanaryPhaseFailed CanaryPhase = "Failed"
+)
+
+// CanaryStatus reflects the progress of the canary installation
+type CanaryStatus struct {
+	// Phase is the current step of the canary installation
+	Phase CanaryPhase `json:"phase"`
+
+	// Nodes reflects the result of the smoke test on each canary node
+	// +optional
+	Nodes []CanaryNodeStatus `json:"nodes,omitempty"`
+
+	// Message explains why the canary installation failed
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// ObservedGeneration is the KataConfig generation the smoke test ran for, a failed
+	// canary installation is retried once the KataConfig changes
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+// CanaryNodeStatus reflects the result of the smoke test on a canary node
+type CanaryNodeStatus struct {
+	// Name of the node
+	Name string `json:"name"`
+
+	// Passed is true when the smoke test pod ran in a virtual machine on the node
+	Passed bool `json:"passed"`
+
+	// Message explains why the smoke test failed on the node
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// RuntimeClassStatus reflects the readiness of an additional RuntimeClass
+type RuntimeClassStatus struct {
+	// Name of the RuntimeClass
+	Name string `json:"name"`
+
+	// Handler is the CRI-O runtime handler of the RuntimeClass
+	Handler string `json:"handler"`
+
+	// Ready is true once the runtime handler is rolled out and the RuntimeClass exists
+	Ready bool `json:"ready"`
+
+	// Message explains why the RuntimeClass is not ready
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// PeerPodsStatus reflects the deployment of the peer pods mode
+type PeerPodsStatus struct {
+	// RuntimeClass is the name of the RuntimeClass running pods as peer pods
+	RuntimeClass string `json:"runtimeClass"`
+
+	// Ready is true once cloud-api-adaptor runs on all the selected nodes
+	Ready bool `json:"ready"`
+
+	// Message explains why the peer pods mode is not ready
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// KataConfig is the Schema for the kataconfigs API
+// +kubebuilder:object:root=true
+// +kubebuilder:storageversion
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=kataconfigs,scope=Cluster
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=".metadata.creationTimestamp"
+type KataConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// +optional
+	// +nullable
+	Spec   KataConfigSpec   `json:"spec,omitempty"`
+	Status KataConfigStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// KataConfigList contains a list of KataConfig
+type KataConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KataConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&KataConfig{}, &KataConfigList{})
+}
+
+// KataNodesStatus reflects the progress of a kata operation on the selected nodes
+type KataNodesStatus struct {
+	// IsInProgress tells whether the operation is ongoing
+	// +optional
+	IsInProgress bool `json:"isInProgress,omitempty"`
+
+	// InProgress reflects the status of nodes that are in the process of the operation
+	// +optional
+	InProgress KataInProgressNodesStatus `json:"inProgress,omitempty"`
+
+	// Completed reflects the status of nodes that have completed the operation
+	// +optional
+	Completed KataConfigCompletedStatus `json:"completed,omitempty"`
+
+	// Failed reflects the status of nodes that have failed the operation
+	// +optional
+	Failed KataFailedNodeStatus `json:"failed,omitempty"`
+}
+
+// KataInstallationStatus reflects the status of the ongoing kata installation
+type KataInstallationStatus struct {
+	KataNodesStatus `json:",inline"`
+
+	// ContainerdNodes reflects the configuration of the kata runtime handler in containerd
+	// on the nodes running it, on Kubernetes clusters
+	// +optional
+	ContainerdNodes []ContainerdNodeStatus `json:"containerdNodes,omitempty"`
+
+	// NodePoolNodes records the configuration the nodes ran when the NodePool configuration
+	// last changed, on clusters with a hosted control plane
+	// +optional
+	NodePoolNodes []NodePoolNodeStatus `json:"nodePoolNodes,omitempty"`
+}
+
+// NodePoolNodeStatus records the configuration a node of a NodePool ran before a rollout
+type NodePoolNodeStatus struct {
+	// Name of the node
+	Name string `json:"name"`
+
+	// InitialConfig is the configuration the node ran when the rollout started
+	// +optional
+	InitialConfig string `json:"initialConfig,omitempty"`
+}
+
+// ContainerdNodeStatus reflects the configuration of containerd on a node
+type ContainerdNodeStatus struct {
+	// Name of the node
+	Name string `json:"name"`
+
+	// Phase is the step of the configuration the node is at
+	Phase ContainerdNodePhase `json:"phase"`
+
+	// Message explains why the configuration failed
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// ContainerdNodePhase is a step of the configuration of containerd on a node
+// +kubebuilder:validation:Enum=Pending;Restarting;Configured;Failed
+type ContainerdNodePhase string
+
+const (
+	// ContainerdNodePending waits for the other nodes to restart containerd
+	ContainerdNodePending ContainerdNodePhase = "Pending"
+
+	// ContainerdNodeRestarting is cordoned while its containerd configuration is updated and
+	// containerd restarted
+	ContainerdNodeRestarting ContainerdNodePhase = "Restarting"
+
+	// ContainerdNodeConfigured runs containerd with the kata runtime handler
+	ContainerdNodeConfigured ContainerdNodePhase = "Configured"
+
+	// ContainerdNodeFailed failed to update its configuration or to restart containerd
+	ContainerdNodeFailed ContainerdNodePhase = "Failed"
+)
+
+// KataUnInstallationStatus reflects the status of the ongoing kata uninstallation
+type KataUnInstallationStatus struct {
+	KataNodesStatus `json:",inline"`
+
+	// Phase is the current step of the uninstallation
+	// +optional
+	Phase UninstallPhase `json:"phase,omitempty"`
+
+	// ErrorMessage explains why the uninstallation is blocked, e.g. by existing
+	// kata-based pods
+	// +optional
+	ErrorMessage string `json:"errorMessage,omitempty"`
+
+	// BlockingPods lists the pods using the kata runtime that block the uninstallation,
+	// capped to the first 50 pods
+	// +optional
+	BlockingPods []BlockingPod `json:"blockingPods,omitempty"`
+
+	// BlockingPodsCount is the total number of pods blocking the uninstallation
+	// +optional
+	BlockingPodsCount int `json:"blockingPodsCount,omitempty"`
+}
+
+// BlockingPod is a pod using the kata runtime that blocks the uninstallation
+type BlockingPod struct {
+	// Namespace is the namespace of the pod
+	Namespace string `json:"namespace"`
+
+	// Name is the name of the pod
+	Name string `json:"name"`
+
+	// NodeName is the node the pod runs on
+	// +optional
+	NodeName string `json:"nodeName,omitempty"`
+}
+
+// MachineConfigPoolStatus reflects the progress of a MachineConfigPool the kata runtime is installed on
+type MachineConfigPoolStatus struct {
+	// Name of the MachineConfigPool
+	Name string `json:"name"`
+
+	// MachineCount is the number of nodes in the pool
+	MachineCount int32 `json:"machineCount"`
+
+	// UpdatedMachineCount is the number of nodes running the latest configuration of the pool
+	UpdatedMachineCount int32 `json:"updatedMachineCount"`
+
+	// DegradedMachineCount is the number of nodes that failed to apply the configuration
+	// +optional
+	DegradedMachineCount int32 `json:"degradedMachineCount,omitempty"`
+
+	// ObservedGeneration is the generation of the MachineConfigPool its controller observed
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// BaseGeneration is the generation of the MachineConfigPool observed before the current
+	// operation changed its configuration
+	// +optional
+	BaseGeneration int64 `json:"baseGeneration,omitempty"`
+}
+
+// ClusterTopology is the topology of an OpenShift cluster
+// +kubebuilder:validation:Enum=Standard;Compact;SingleNode;HostedControlPlane
+type ClusterTopology string
+
+const (
+	// ClusterTopologyStandard runs the workloads on dedicated worker nodes
+	ClusterTopologyStandard ClusterTopology = "Standard"
+
+	// ClusterTopologyCompact runs the workloads on its three schedulable master nodes
+	ClusterTopologyCompact ClusterTopology = "Compact"
+
+	// ClusterTopologySingleNode runs the control plane and the workloads on a single node
+	ClusterTopologySingleNode ClusterTopology = "SingleNode"
+
+	// ClusterTopologyHostedControlPlane runs its control plane outside of the cluster, its
+	// nodes are configured through NodePools instead of MachineConfigPools
+	ClusterTopologyHostedControlPlane ClusterTopology = "HostedControlPlane"
+)
+
+// UninstallPhase is a step of the uninstallation of the kata runtime
+// +kubebuilder:validation:Enum=BlockedByPods;MachineConfigDeleted;PoolRolling;Cleanup;Done
+type UninstallPhase string
+
+const (
+	// UninstallPhaseBlockedByPods waits for the pods using the kata runtime to be deleted
+	UninstallPhaseBlockedByPods UninstallPhase = "BlockedByPods"
+
+	// UninstallPhaseMachineConfigDeleted waits for the MachineConfigPool to pick up the
+	// deletion of the MachineConfigs
+	UninstallPhaseMachineConfigDeleted UninstallPhase = "MachineConfigDeleted"
+
+	// UninstallPhasePoolRolling waits for the nodes to roll out the configuration without
+	// the kata runtime
+	UninstallPhasePoolRolling UninstallPhase = "PoolRolling"
+
+	// UninstallPhaseCleanup removes what the operator left on the nodes
+	UninstallPhaseCleanup UninstallPhase = "Cleanup"
+
+	// UninstallPhaseDone lets the KataConfig be deleted
+	UninstallPhaseDone UninstallPhase = "Done"
+)
+
+// KataUpgradeStatus reflects the status of the ongoing kata upgrade
+type KataUpgradeStatus struct {
+	KataNodesStatus `json:",inline"`
+
+	// FromVersion is the operator version the kata runtime was installed with
+	// +optional
+	FromVersion string `json:"fromVersion,omitempty"`
+
+	// ToVersion is the operator version the kata runtime is upgraded to
+	// +optional
+	ToVersion string `json:"toVersion,omitempty"`
+}
+
+// KataInProgressNodesStatus reflects the status of nodes that are in the process of a kata operation
+type KataInProgressNodesStatus struct {
+	// InProgressNodesCount reflects the number of nodes that are in the process of the operation
+	// +optional
+	InProgressNodesCount int `json:"inProgressNodesCount,omitempty"`
+
+	// InProgressNodesList reflects the list of nodes that are in the process of the operation
+	// +optional
+	InProgressNodesList []string `json:"inProgressNodesList,omitempty"`
+}
+
+// KataConfigCompletedStatus reflects the status of nodes that have completed kata operation
+type KataConfigCompletedStatus struct {
+	// CompletedNodesCount reflects the number of nodes that have completed kata operation
+	// +optional
+	CompletedNodesCount int `json:"completedNodesCount,omitempty"`
+
+	// CompletedNodesList reflects the list of nodes that have completed kata operation
+	// +optional
+	CompletedNodesList []string `json:"completedNodesList,omitempty"`
+}
+
+// KataFailedNodeStatus reflects the status of nodes that have failed kata operation
+type KataFailedNodeStatus struct {
+	// FailedNodesCount reflects the number of nodes that have failed kata operation
+	// +optional
+	FailedNodesCount int `json:"failedNodesCount,omitempty"`
+
+	// FailedReason is the reason reported by the MachineConfigPool for the failure
+	// +optional
+	FailedReason string `json:"failedReason,omitempty"`
+
+	// FailedNodesList reflects the list of nodes that have failed kata operation
+	// +optional
+	FailedNodesList []FailedNodeStatus `json:"failedNodesList,omitempty"`
+}
+
+// FailedNodeStatus holds the name and the error message of the failed node
+type FailedNodeStatus struct {
+	// Name of the failed node
+	Name string `json:"name"`
+	// Error message of the failed node reported by the installation daemon
+	Error string `json:"error"`
+}
@@ -0,0 +1,619 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+var (
+	// log is for logging in this package.
+	kataconfiglog = logf.Log.WithName("kataconfig-resource")
+	clientInst    client.Client
+
+	// SupportedHypervisors are the hypervisors the kata runtime installed on the cluster ships
+	// a configuration for. The sandboxed-containers extension only ships the one of qemu, the
+	// manager sets the ones of kata-deploy on Kubernetes clusters.
+	SupportedHypervisors = []Hypervisor{HypervisorQEMU}
+)
+
+// SetupWebhookWithManager registers the validating webhook of the KataConfig and,
+// as v2 is the conversion hub, the conversion webhook serving all its versions
+func (r *KataConfig) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	clientInst = mgr.GetClient()
+
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
+
+// TODO(user): change verbs to "verbs=create;update;delete" if you want to enable deletion validation.
+//+kubebuilder:webhook:verbs=create;update,path=/validate-kataconfiguration-openshift-io-v2-kataconfig,mutating=false,failurePolicy=fail,matchPolicy=Equivalent,groups=kataconfiguration.openshift.io,resources=kataconfigs,versions=v2,name=vkataconfig.kb.io,sideEffects=none,admissionReviewVersions={v1}
+
+var _ webhook.Validator = &KataConfig{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *KataConfig) ValidateCreate() error {
+	kataconfiglog.Info("validate create", "name", r.Name)
+
+	if err := r.validateRuntimeClass(); err != nil {
+		return err
+	}
+	if err := r.validateHypervisor(); err != nil {
+		return err
+	}
+	if err := r.validateHypervisorConfig(); err != nil {
+		return err
+	}
+	if err := r.validateMaxUnavailable(); err != nil {
+		return err
+	}
+	if err := r.validateCanary(); err != nil {
+		return err
+	}
+	if err := r.validateMachineConfigPoolNames(); err != nil {
+		return err
+	}
+
+	otherKataConfigs, err := r.listOtherKataConfigs()
+	if err != nil {
+		return err
+	}
+	// The controller names the resources of a KataConfig created next to others after it
+	created := r.DeepCopy()
+	if len(otherKataConfigs) > 0 {
+		created.Status.PoolName = DefaultPoolName + "-" + r.Name
+	}
+	return created.validateDisjointFrom(otherKataConfigs)
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *KataConfig) ValidateUpdate(old runtime.Object) error {
+	kataconfiglog.Info("validate update", "name", r.Name)
+
+	// The uninstallation only removes the finalizer of a KataConfig being deleted
+	if r.GetDeletionTimestamp() != nil {
+		return nil
+	}
+
+	if err := r.validateRuntimeClass(); err != nil {
+		return err
+	}
+	if err := r.validateHypervisor(); err != nil {
+		return err
+	}
+	if err := r.validateHypervisorConfig(); err != nil {
+		return err
+	}
+	if err := r.validateMaxUnavailable(); err != nil {
+		return err
+	}
+	if err := r.validateCanary(); err != nil {
+		return err
+	}
+	if err := r.validateMachineConfigPoolNames(); err != nil {
+		return err
+	}
+
+	oldKataConfig, ok := old.(*KataConfig)
+	if !ok {
+		return fmt.Errorf("Unexpected object type %T, expected KataConfig", old)
+	}
+
+	otherKataConfigs, err := r.listOtherKataConfigs()
+	if err != nil {
+		return err
+	}
+	updated := r.DeepCopy()
+	updated.Status = *oldKataConfig.Status.DeepCopy()
+	if err := updated.validateDisjointFrom(otherKataConfigs); err != nil {
+		return err
+	}
+
+	// Renaming the RuntimeClass would leave the pods using the current one
+	// without a RuntimeClass, so refuse it until these pods are gone
+	currentRuntimeClass := oldKataConfig.Status.RuntimeClass
+	if currentRuntimeClass != "" && updated.GetRuntimeClassName() != oldKataConfig.GetRuntimeClassName() &&
+		updated.GetRuntimeClassName() != currentRuntimeClass {
+		if err := checkRuntimeClassUnused(currentRuntimeClass, "rename"); err != nil {
+			return err
+		}
+	}
+
+	// Same for the additional RuntimeClasses removed from the spec
+	for _, rcStatus := range oldKataConfig.Status.RuntimeClasses {
+		if r.Spec.getRuntimeClassVariant(rcStatus.Name) == nil {
+			if err := checkRuntimeClassUnused(rcStatus.Name, "remove"); err != nil {
+				return err
+			}
+		}
+	}
+
+	// And for the peer pods RuntimeClass when peer pods are disabled
+	if oldKataConfig.Status.PeerPods != nil && r.Spec.PeerPods == nil {
+		if err := checkRuntimeClassUnused(oldKataConfig.Status.PeerPods.RuntimeClass, "remove"); err != nil {
+			return err
+		}
+	}
+
+	// Nodes leaving the pool lose the kata runtime, so refuse it while they run pods using it
+	if !equality.Semantic.DeepEqual(r.Spec.KataConfigPoolSelector, oldKataConfig.Spec.KataConfigPoolSelector) {
+		if err := checkLeavingNodesUnused(oldKataConfig, r); err != nil {
+			return err
+		}
+	}
+
+	// Same for the nodes of the pools removed from machineConfigPoolNames
+	if err := checkRemovedPoolsUnused(oldKataConfig, r); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *KataConfig) ValidateDelete() error {
+	kataconfiglog.Info("validate delete", "name", r.Name)
+
+	// TODO(user): fill in your validation logic upon object deletion.
+	return nil
+}
+
+// validateRuntimeClass checks that the requested RuntimeClass name and handler are valid
+// Kubernetes names
+func (r *KataConfig) validateRuntimeClass() error {
+	if errs := validation.IsDNS1123Subdomain(r.Spec.GetRuntimeClassName()); len(errs) > 0 {
+		return fmt.Errorf("Invalid runtimeClassName %s: %s", r.Spec.GetRuntimeClassName(), strings.Join(errs, ", "))
+	}
+	if errs := validation.IsDNS1123Label(r.Spec.GetRuntimeClassHandler()); len(errs) > 0 {
+		return fmt.Errorf("Invalid runtimeClassHandler %s: %s", r.Spec.GetRuntimeClassHandler(), strings.Join(errs, ", "))
+	}
+	if err := validateOverhead(r.Spec.RuntimeClassOverhead); err != nil {
+		return err
+	}
+
+	names := map[string]bool{r.Spec.GetRuntimeClassName(): true}
+	handlers := map[string]bool{r.Spec.GetRuntimeClassHandler(): true}
+	if r.Spec.PeerPods != nil {
+		if names[r.GetPeerPodsRuntimeClassName()] || handlers[PeerPodsRuntimeClassHandler] {
+			return fmt.Errorf("RuntimeClass %s and runtime handler %s are reserved for peer pods",
+				r.GetPeerPodsRuntimeClassName(), PeerPodsRuntimeClassHandler)
+		}
+		names[r.GetPeerPodsRuntimeClassName()] = true
+		handlers[PeerPodsRuntimeClassHandler] = true
+	}
+	for _, variant := range r.Spec.RuntimeClasses {
+		if errs := validation.IsDNS1123Subdomain(variant.Name); len(errs) > 0 {
+			return fmt.Errorf("Invalid runtimeClasses name %s: %s", variant.Name, strings.Join(errs, ", "))
+		}
+		if errs := validation.IsDNS1123Label(variant.GetHandler()); len(errs) > 0 {
+			return fmt.Errorf("Invalid runtimeClasses handler %s: %s", variant.GetHandler(), strings.Join(errs, ", "))
+		}
+		if names[variant.Name] {
+			return fmt.Errorf("RuntimeClass %s is defined more than once", variant.Name)
+		}
+		if handlers[variant.GetHandler()] {
+			return fmt.Errorf("Runtime handler %s of RuntimeClass %s is already used", variant.GetHandler(), variant.Name)
+		}
+		if variant.ConfigPath != "" && !path.IsAbs(variant.ConfigPath) {
+			return fmt.Errorf("Invalid configPath %s of RuntimeClass %s: must be an absolute path", variant.ConfigPath, variant.Name)
+		}
+		if err := validateOverhead(variant.Overhead); err != nil {
+			return err
+		}
+		names[variant.Name] = true
+		handlers[variant.GetHandler()] = true
+	}
+	return nil
+}
+
+// validateHypervisor checks that the kata runtime installed on the cluster ships the kata
+// configuration of the hypervisor, and that the default kata runtime handler is not reused for
+// another hypervisor than qemu
+func (r *KataConfig) validateHypervisor() error {
+	hypervisor := r.Spec.GetHypervisor()
+	if hypervisor == HypervisorQEMU {
+		return nil
+	}
+
+	supported := false
+	var names []string
+	for _, h := range SupportedHypervisors {
+		supported = supported || h == hypervisor
+		names = append(names, string(h))
+	}
+	if !supported {
+		return fmt.Errorf("Hypervisor %s is not shipped by the kata runtime installed on this cluster, supported: %s",
+			hypervisor, strings.Join(names, ", "))
+	}
+
+	if r.Spec.RuntimeClassHandler == DefaultRuntimeClassHandler {
+		return fmt.Errorf("Invalid runtimeClassHandler %s: the default runtime handler runs qemu, not %s",
+			DefaultRuntimeClassHandler, hypervisor)
+	}
+	return nil
+}
+
+// validateHypervisorConfig checks that the hypervisor settings can be rendered into a
+// kata configuration drop-in
+func (r *KataConfig) validateHypervisorConfig() error {
+	config := r.Spec.HypervisorConfig
+	if config == nil {
+		return nil
+	}
+
+	if config.DefaultMemory != nil && config.DefaultMaxMemory != nil &&
+		*config.DefaultMaxMemory < *config.DefaultMemory {
+		return fmt.Errorf("Invalid hypervisorConfig: defaultMaxMemory %d is lower than defaultMemory %d",
+			*config.DefaultMaxMemory, *config.DefaultMemory)
+	}
+	for _, param := range config.KernelParams {
+		if param == "" || strings.ContainsAny(param, " \t\n\"\\") {
+			return fmt.Errorf("Invalid hypervisorConfig kernel parameter %q: must be a single word without quotes", param)
+		}
+	}
+	return nil
+}
+
+// validateMaxUnavailable checks that maxUnavailable lets at least one node be updated
+func (r *KataConfig) validateMaxUnavailable() error {
+	maxUnavailable := r.Spec.MaxUnavailable
+	if maxUnavailable == nil {
+		return nil
+	}
+
+	if maxUnavailable.Type == intstr.String {
+		if !strings.HasSuffix(maxUnavailable.StrVal, "%") {
+			return fmt.Errorf("Invalid maxUnavailable %s: must be an integer or a percentage", maxUnavailable.StrVal)
+		}
+		percent, err := strconv.Atoi(strings.TrimSuffix(maxUnavailable.StrVal, "%"))
+		if err != nil || percent <= 0 || percent > 100 {
+			return fmt.Errorf("Invalid maxUnavailable %s: must be a percentage between 1%% and 100%%", maxUnavailable.StrVal)
+		}
+		return nil
+	}
+	if maxUnavailable.IntVal <= 0 {
+		return fmt.Errorf("Invalid maxUnavailable %d: must be greater than 0", maxUnavailable.IntVal)
+	}
+	return nil
+}
+
+// validateCanary checks that the canary nodes can be put in a custom pool
+func (r *KataConfig) validateCanary() error {
+	if r.Spec.Canary == nil {
+		return nil
+	}
+
+	if r.Spec.KataConfigPoolSelector == nil {
+		return fmt.Errorf("Invalid canary: a kataConfigPoolSelector is required")
+	}
+	if len(r.Spec.Canary.NodeSelector) == 0 {
+		return fmt.Errorf("Invalid canary: the nodeSelector must not be empty")
+	}
+	return nil
+}
+
+// validateMachineConfigPoolNames checks that the named pools are listed once, and are not
+// combined with a kataConfigPoolSelector
+func (r *KataConfig) validateMachineConfigPoolNames() error {
+	if len(r.Spec.MachineConfigPoolNames) == 0 {
+		return nil
+	}
+
+	if r.Spec.KataConfigPoolSelector != nil {
+		return fmt.Errorf("Invalid machineConfigPoolNames: the kataConfigPoolSelector must not be set with them")
+	}
+	var names []string
+	for _, name := range r.Spec.MachineConfigPoolNames {
+		if name == "" {
+			return fmt.Errorf("Invalid machineConfigPoolNames: the names must not be empty")
+		}
+		if contains(names, name) {
+			return fmt.Errorf("Invalid machineConfigPoolNames: MachineConfigPool %s is listed twice", name)
+		}
+		names = append(names, name)
+	}
+	return nil
+}
+
+// validateOverhead checks that a RuntimeClass pod overhead only holds non-negative
+// cpu and memory quantities
+func validateOverhead(overhead corev1.ResourceList) error {
+	for name, quantity := range overhead {
+		if name != corev1.ResourceCPU && name != corev1.ResourceMemory {
+			return fmt.Errorf("Invalid overhead resource %s: only %s and %s are supported",
+				name, corev1.ResourceCPU, corev1.ResourceMemory)
+		}
+		if quantity.Sign() < 0 {
+			return fmt.Errorf("Invalid overhead for %s: %s must not be negative", name, quantity.String())
+		}
+	}
+	return nil
+}
+
+// checkRuntimeClassUnused refuses the given operation on a RuntimeClass used by pods
+func checkRuntimeClassUnused(runtimeClassName string, operation string) error {
+	pods, err := listPodsUsingRuntimeClass(runtimeClassName)
+	if err != nil {
+		return err
+	}
+	if len(pods) > 0 {
+		return fmt.Errorf("Cannot %s RuntimeClass %s while it is used by pods: %s",
+			operation, runtimeClassName, strings.Join(pods, ", "))
+	}
+	return nil
+}
+
+// listOtherKataConfigs returns the KataConfigs of the cluster other than this one
+func (r *KataConfig) listOtherKataConfigs() ([]KataConfig, error) {
+	kataConfigList := &KataConfigList{}
+	listOpts := []client.ListOption{
+		client.InNamespace(corev1.NamespaceAll),
+	}
+	if err := clientInst.List(context.TODO(), kataConfigList, listOpts...); err != nil {
+		return nil, fmt.Errorf("Failed to list KataConfig custom resources: %v", err)
+	}
+
+	var kataConfigs []KataConfig
+	for _, kataConfig := range kataConfigList.Items {
+		if kataConfig.Name != r.Name {
+			kataConfigs = append(kataConfigs, kataConfig)
+		}
+	}
+	return kataConfigs, nil
+}
+
+// validateDisjointFrom checks that the KataConfig neither selects the nodes nor creates the
+// RuntimeClasses of another KataConfig
+func (r *KataConfig) validateDisjointFrom(otherKataConfigs []KataConfig) error {
+	if len(otherKataConfigs) == 0 {
+		return nil
+	}
+
+	poolSelector := poolSelectorOrDefault(r.Spec.KataConfigPoolSelector)
+	selector, err := metav1.LabelSelectorAsSelector(poolSelector)
+	if err != nil {
+		return fmt.Errorf("Invalid kataConfigPoolSelector: %v", err)
+	}
+	nodeList := &corev1.NodeList{}
+	if err := clientInst.List(context.TODO(), nodeList); err != nil {
+		return fmt.Errorf("Failed to list nodes: %v", err)
+	}
+
+	runtimeClassNames := r.runtimeClassNames()
+	for _, other := range otherKataConfigs {
+		for _, name := range other.Spec.MachineConfigPoolNames {
+			if contains(r.Spec.MachineConfigPoolNames, name) {
+				return fmt.Errorf("MachineConfigPool %s is already named by KataConfig %s", name, other.Name)
+			}
+		}
+
+		// The KataConfigs naming pools do not install the kata runtime on the selected nodes
+		if len(r.Spec.MachineConfigPoolNames) == 0 && len(other.Spec.MachineConfigPoolNames) == 0 {
+			otherPoolSelector := poolSelectorOrDefault(other.Spec.KataConfigPoolSelector)
+			if equality.Semantic.DeepEqual(poolSelector, otherPoolSelector) {
+				return fmt.Errorf("The kataConfigPoolSelector selects the same nodes as KataConfig %s", other.Name)
+			}
+			otherSelector, err := metav1.LabelSelectorAsSelector(otherPoolSelector)
+			if err != nil {
+				return err
+			}
+			var overlap []string
+			for _, node := range nodeList.Items {
+				if selector.Matches(labels.Set(node.Labels)) && otherSelector.Matches(labels.Set(node.Labels)) {
+					overlap = append(overlap, node.Name)
+				}
+			}
+			if len(overlap) > 0 {
+				return fmt.Errorf("The kataConfigPoolSelector overlaps the one of KataConfig %s on nodes: %s",
+					other.Name, strings.Join(overlap, ", "))
+			}
+		}
+
+		for _, name := range other.runtimeClassNames() {
+			if contains(runtimeClassNames, name) {
+				return fmt.Errorf("RuntimeClass %s is already created by KataConfig %s, set a different runtimeClassName",
+					name, other.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// runtimeClassNames returns the names of the RuntimeClasses the KataConfig creates, or created
+// before they were renamed
+func (r *KataConfig) runtimeClassNames() []string {
+	names := []string{r.GetRuntimeClassName()}
+	if r.Status.RuntimeClass != "" && r.Status.RuntimeClass != r.GetRuntimeClassName() {
+		names = append(names, r.Status.RuntimeClass)
+	}
+	for _, variant := range r.Spec.RuntimeClasses {
+		names = append(names, variant.Name)
+	}
+	if r.Spec.PeerPods != nil {
+		names = append(names, r.GetPeerPodsRuntimeClassName())
+	}
+	return names
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// checkLeavingNodesUnused refuses a kataConfigPoolSelector change removing nodes that run
+// pods using the kata runtime
+func checkLeavingNodesUnused(oldKataConfig *KataConfig, kataConfig *KataConfig) error {
+	if oldKataConfig.Status.RuntimeClass == "" && len(oldKataConfig.Status.RuntimeClasses) == 0 {
+		return nil
+	}
+
+	previousSelector, err := metav1.LabelSelectorAsSelector(poolSelectorOrDefault(oldKataConfig.Spec.KataConfigPoolSelector))
+	if err != nil {
+		return err
+	}
+	currentSelector, err := metav1.LabelSelectorAsSelector(poolSelectorOrDefault(kataConfig.Spec.KataConfigPoolSelector))
+	if err != nil {
+		return fmt.Errorf("Invalid kataConfigPoolSelector: %v", err)
+	}
+
+	nodeList := &corev1.NodeList{}
+	if err := clientInst.List(context.TODO(), nodeList); err != nil {
+		return fmt.Errorf("Failed to list nodes: %v", err)
+	}
+	leavingNodes := map[string]bool{}
+	for _, node := range nodeList.Items {
+		if previousSelector.Matches(labels.Set(node.Labels)) && !currentSelector.Matches(labels.Set(node.Labels)) {
+			leavingNodes[node.Name] = true
+		}
+	}
+	pods, err := listKataPodsOnNodes(oldKataConfig, leavingNodes)
+	if err != nil {
+		return err
+	}
+	if len(pods) > 0 {
+		return fmt.Errorf("Cannot remove nodes from the kataConfigPoolSelector while they run pods using the kata runtime: %s",
+			strings.Join(pods, ", "))
+	}
+	return nil
+}
+
+// checkRemovedPoolsUnused refuses removing MachineConfigPools from machineConfigPoolNames while
+// their nodes run pods using the kata runtime
+func checkRemovedPoolsUnused(oldKataConfig *KataConfig, kataConfig *KataConfig) error {
+	if oldKataConfig.Status.RuntimeClass == "" && len(oldKataConfig.Status.RuntimeClasses) == 0 {
+		return nil
+	}
+
+	leavingNodes := map[string]bool{}
+	for _, name := range oldKataConfig.Spec.MachineConfigPoolNames {
+		if contains(kataConfig.Spec.MachineConfigPoolNames, name) {
+			continue
+		}
+		pool := &mcfgv1.MachineConfigPool{}
+		err := clientInst.Get(context.TODO(), types.NamespacedName{Name: name}, pool)
+		if err != nil && apierrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("Failed to get MachineConfigPool %s: %v", name, err)
+		}
+		selector, err := metav1.LabelSelectorAsSelector(pool.Spec.NodeSelector)
+		if err != nil {
+			return err
+		}
+		nodeList := &corev1.NodeList{}
+		if err := clientInst.List(context.TODO(), nodeList, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+			return fmt.Errorf("Failed to list nodes: %v", err)
+		}
+		for _, node := range nodeList.Items {
+			leavingNodes[node.Name] = true
+		}
+	}
+
+	pods, err := listKataPodsOnNodes(oldKataConfig, leavingNodes)
+	if err != nil {
+		return err
+	}
+	if len(pods) > 0 {
+		return fmt.Errorf("Cannot remove MachineConfigPools from machineConfigPoolNames while their nodes run pods using the kata runtime: %s",
+			strings.Join(pods, ", "))
+	}
+	return nil
+}
+
+// listKataPodsOnNodes returns the pods running on the given nodes with one of the RuntimeClasses
+// the KataConfig created
+func listKataPodsOnNodes(kataConfig *KataConfig, nodes map[string]bool) ([]string, error) {
+	if len(nodes) == 0 {
+		return nil, nil
+	}
+	runtimeClassNames := map[string]bool{}
+	if kataConfig.Status.RuntimeClass != "" {
+		runtimeClassNames[kataConfig.Status.RuntimeClass] = true
+	}
+	for _, rcStatus := range kataConfig.Status.RuntimeClasses {
+		runtimeClassNames[rcStatus.Name] = true
+	}
+
+	podList := &corev1.PodList{}
+	if err := clientInst.List(context.TODO(), podList, client.InNamespace(corev1.NamespaceAll)); err != nil {
+		return nil, fmt.Errorf("Failed to list pods: %v", err)
+	}
+	var pods []string
+	for _, pod := range podList.Items {
+		if pod.Spec.RuntimeClassName != nil && runtimeClassNames[*pod.Spec.RuntimeClassName] &&
+			nodes[pod.Spec.NodeName] {
+			pods = append(pods, pod.Namespace+"/"+pod.Name+" on "+pod.Spec.NodeName)
+		}
+	}
+	return pods, nil
+}
+
+// poolSelectorOrDefault returns the kataConfigPoolSelector, or the selector of the worker
+// nodes used when it is not specified
+func poolSelectorOrDefault(selector *metav1.LabelSelector) *metav1.LabelSelector {
+	if selector == nil {
+		return &metav1.LabelSelector{
+			MatchLabels: map[string]string{"node-role.kubernetes.io/worker": ""},
+		}
+	}
+	return selector
+}
+
+// listPodsUsingRuntimeClass returns the namespace/name of the pods using the given RuntimeClass
+func listPodsUsingRuntimeClass(runtimeClassName string) ([]string, error) {
+	podList := &corev1.PodList{}
+	listOpts := []client.ListOption{
+		client.InNamespace(corev1.NamespaceAll),
+	}
+	if err := clientInst.List(context.TODO(), podList, listOpts...); err != nil {
+		return nil, fmt.Errorf("Failed to list pods: %v", err)
+	}
+
+	var pods []string
+	for _, pod := range podList.Items {
+		if pod.Spec.RuntimeClassName != nil && *pod.Spec.RuntimeClassName == runtimeClassName {
+			pods = append(pods, pod.Namespace+"/"+pod.Name)
+		}
+	}
+	return pods, nil
+}
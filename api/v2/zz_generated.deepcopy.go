@@ -0,0 +1,714 @@
+// +build !ignore_autogenerated
+
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by controller-gen. DO NOT EDIT.
+
+package v2
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BlockingPod) DeepCopyInto(out *BlockingPod) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BlockingPod.
+func (in *BlockingPod) DeepCopy() *BlockingPod {
+	if in == nil {
+		return nil
+	}
+	out := new(BlockingPod)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryConfig) DeepCopyInto(out *CanaryConfig) {
+	*out = *in
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryConfig.
+func (in *CanaryConfig) DeepCopy() *CanaryConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryNodeStatus) DeepCopyInto(out *CanaryNodeStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryNodeStatus.
+func (in *CanaryNodeStatus) DeepCopy() *CanaryNodeStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryNodeStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryStatus) DeepCopyInto(out *CanaryStatus) {
+	*out = *in
+	if in.Nodes != nil {
+		in, out := &in.Nodes, &out.Nodes
+		*out = make([]CanaryNodeStatus, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryStatus.
+func (in *CanaryStatus) DeepCopy() *CanaryStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ContainerdNodeStatus) DeepCopyInto(out *ContainerdNodeStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContainerdNodeStatus.
+func (in *ContainerdNodeStatus) DeepCopy() *ContainerdNodeStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ContainerdNodeStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FailedNodeStatus) DeepCopyInto(out *FailedNodeStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FailedNodeStatus.
+func (in *FailedNodeStatus) DeepCopy() *FailedNodeStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(FailedNodeStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HypervisorConfig) DeepCopyInto(out *HypervisorConfig) {
+	*out = *in
+	if in.DefaultVCPUs != nil {
+		in, out := &in.DefaultVCPUs, &out.DefaultVCPUs
+		*out = new(int32)
+		**out = **in
+	}
+	if in.DefaultMemory != nil {
+		in, out := &in.DefaultMemory, &out.DefaultMemory
+		*out = new(int32)
+		**out = **in
+	}
+	if in.DefaultMaxMemory != nil {
+		in, out := &in.DefaultMaxMemory, &out.DefaultMaxMemory
+		*out = new(int32)
+		**out = **in
+	}
+	if in.KernelParams != nil {
+		in, out := &in.KernelParams, &out.KernelParams
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HypervisorConfig.
+func (in *HypervisorConfig) DeepCopy() *HypervisorConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(HypervisorConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataConfig) DeepCopyInto(out *KataConfig) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataConfig.
+func (in *KataConfig) DeepCopy() *KataConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(KataConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KataConfig) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataConfigCompletedStatus) DeepCopyInto(out *KataConfigCompletedStatus) {
+	*out = *in
+	if in.CompletedNodesList != nil {
+		in, out := &in.CompletedNodesList, &out.CompletedNodesList
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataConfigCompletedStatus.
+func (in *KataConfigCompletedStatus) DeepCopy() *KataConfigCompletedStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KataConfigCompletedStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataConfigList) DeepCopyInto(out *KataConfigList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]KataConfig, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataConfigList.
+func (in *KataConfigList) DeepCopy() *KataConfigList {
+	if in == nil {
+		return nil
+	}
+	out := new(KataConfigList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KataConfigList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataConfigSpec) DeepCopyInto(out *KataConfigSpec) {
+	*out = *in
+	if in.KataConfigPoolSelector != nil {
+		in, out := &in.KataConfigPoolSelector, &out.KataConfigPoolSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MachineConfigPoolNames != nil {
+		in, out := &in.MachineConfigPoolNames, &out.MachineConfigPoolNames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.RuntimeClassOverhead != nil {
+		in, out := &in.RuntimeClassOverhead, &out.RuntimeClassOverhead
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.RuntimeClasses != nil {
+		in, out := &in.RuntimeClasses, &out.RuntimeClasses
+		*out = make([]RuntimeClassVariant, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.HypervisorConfig != nil {
+		in, out := &in.HypervisorConfig, &out.HypervisorConfig
+		*out = new(HypervisorConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MaxUnavailable != nil {
+		in, out := &in.MaxUnavailable, &out.MaxUnavailable
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	if in.Canary != nil {
+		in, out := &in.Canary, &out.Canary
+		*out = new(CanaryConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RollbackThreshold != nil {
+		in, out := &in.RollbackThreshold, &out.RollbackThreshold
+		*out = new(RollbackThreshold)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.UninstallTimeout != nil {
+		in, out := &in.UninstallTimeout, &out.UninstallTimeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.PeerPods != nil {
+		in, out := &in.PeerPods, &out.PeerPods
+		*out = new(PeerPodsConfig)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataConfigSpec.
+func (in *KataConfigSpec) DeepCopy() *KataConfigSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(KataConfigSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataConfigStatus) DeepCopyInto(out *KataConfigStatus) {
+	*out = *in
+	if in.MachineConfigPools != nil {
+		in, out := &in.MachineConfigPools, &out.MachineConfigPools
+		*out = make([]MachineConfigPoolStatus, len(*in))
+		copy(*out, *in)
+	}
+	in.InstallationStatus.DeepCopyInto(&out.InstallationStatus)
+	in.UnInstallationStatus.DeepCopyInto(&out.UnInstallationStatus)
+	in.UpgradeStatus.DeepCopyInto(&out.UpgradeStatus)
+	if in.RuntimeClasses != nil {
+		in, out := &in.RuntimeClasses, &out.RuntimeClasses
+		*out = make([]RuntimeClassStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.PeerPods != nil {
+		in, out := &in.PeerPods, &out.PeerPods
+		*out = new(PeerPodsStatus)
+		**out = **in
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.NodeEligibility != nil {
+		in, out := &in.NodeEligibility, &out.NodeEligibility
+		*out = make([]NodeEligibilityStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.NodeValidation != nil {
+		in, out := &in.NodeValidation, &out.NodeValidation
+		*out = make([]NodeValidationStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Rollback != nil {
+		in, out := &in.Rollback, &out.Rollback
+		*out = new(RollbackStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Canary != nil {
+		in, out := &in.Canary, &out.Canary
+		*out = new(CanaryStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DebugNodes != nil {
+		in, out := &in.DebugNodes, &out.DebugNodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PoolSelector != nil {
+		in, out := &in.PoolSelector, &out.PoolSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LeavingNodes != nil {
+		in, out := &in.LeavingNodes, &out.LeavingNodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataConfigStatus.
+func (in *KataConfigStatus) DeepCopy() *KataConfigStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KataConfigStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataFailedNodeStatus) DeepCopyInto(out *KataFailedNodeStatus) {
+	*out = *in
+	if in.FailedNodesList != nil {
+		in, out := &in.FailedNodesList, &out.FailedNodesList
+		*out = make([]FailedNodeStatus, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataFailedNodeStatus.
+func (in *KataFailedNodeStatus) DeepCopy() *KataFailedNodeStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KataFailedNodeStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataInProgressNodesStatus) DeepCopyInto(out *KataInProgressNodesStatus) {
+	*out = *in
+	if in.InProgressNodesList != nil {
+		in, out := &in.InProgressNodesList, &out.InProgressNodesList
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataInProgressNodesStatus.
+func (in *KataInProgressNodesStatus) DeepCopy() *KataInProgressNodesStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KataInProgressNodesStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataInstallationStatus) DeepCopyInto(out *KataInstallationStatus) {
+	*out = *in
+	in.KataNodesStatus.DeepCopyInto(&out.KataNodesStatus)
+	if in.ContainerdNodes != nil {
+		in, out := &in.ContainerdNodes, &out.ContainerdNodes
+		*out = make([]ContainerdNodeStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.NodePoolNodes != nil {
+		in, out := &in.NodePoolNodes, &out.NodePoolNodes
+		*out = make([]NodePoolNodeStatus, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataInstallationStatus.
+func (in *KataInstallationStatus) DeepCopy() *KataInstallationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KataInstallationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataNodesStatus) DeepCopyInto(out *KataNodesStatus) {
+	*out = *in
+	in.InProgress.DeepCopyInto(&out.InProgress)
+	in.Completed.DeepCopyInto(&out.Completed)
+	in.Failed.DeepCopyInto(&out.Failed)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataNodesStatus.
+func (in *KataNodesStatus) DeepCopy() *KataNodesStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KataNodesStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataUnInstallationStatus) DeepCopyInto(out *KataUnInstallationStatus) {
+	*out = *in
+	in.KataNodesStatus.DeepCopyInto(&out.KataNodesStatus)
+	if in.BlockingPods != nil {
+		in, out := &in.BlockingPods, &out.BlockingPods
+		*out = make([]BlockingPod, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataUnInstallationStatus.
+func (in *KataUnInstallationStatus) DeepCopy() *KataUnInstallationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KataUnInstallationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataUpgradeStatus) DeepCopyInto(out *KataUpgradeStatus) {
+	*out = *in
+	in.KataNodesStatus.DeepCopyInto(&out.KataNodesStatus)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataUpgradeStatus.
+func (in *KataUpgradeStatus) DeepCopy() *KataUpgradeStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KataUpgradeStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineConfigPoolStatus) DeepCopyInto(out *MachineConfigPoolStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineConfigPoolStatus.
+func (in *MachineConfigPoolStatus) DeepCopy() *MachineConfigPoolStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineConfigPoolStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeEligibilityStatus) DeepCopyInto(out *NodeEligibilityStatus) {
+	*out = *in
+	if in.CPUVirtualizationFlags != nil {
+		in, out := &in.CPUVirtualizationFlags, &out.CPUVirtualizationFlags
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeEligibilityStatus.
+func (in *NodeEligibilityStatus) DeepCopy() *NodeEligibilityStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeEligibilityStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodePoolNodeStatus) DeepCopyInto(out *NodePoolNodeStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodePoolNodeStatus.
+func (in *NodePoolNodeStatus) DeepCopy() *NodePoolNodeStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NodePoolNodeStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeValidationStatus) DeepCopyInto(out *NodeValidationStatus) {
+	*out = *in
+	if in.StartupLatency != nil {
+		in, out := &in.StartupLatency, &out.StartupLatency
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.LastAttemptTime != nil {
+		in, out := &in.LastAttemptTime, &out.LastAttemptTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeValidationStatus.
+func (in *NodeValidationStatus) DeepCopy() *NodeValidationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeValidationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PeerPodsConfig) DeepCopyInto(out *PeerPodsConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PeerPodsConfig.
+func (in *PeerPodsConfig) DeepCopy() *PeerPodsConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(PeerPodsConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PeerPodsStatus) DeepCopyInto(out *PeerPodsStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PeerPodsStatus.
+func (in *PeerPodsStatus) DeepCopy() *PeerPodsStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PeerPodsStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RollbackStatus) DeepCopyInto(out *RollbackStatus) {
+	*out = *in
+	in.StartTime.DeepCopyInto(&out.StartTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RollbackStatus.
+func (in *RollbackStatus) DeepCopy() *RollbackStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RollbackStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RollbackThreshold) DeepCopyInto(out *RollbackThreshold) {
+	*out = *in
+	if in.DegradedNodes != nil {
+		in, out := &in.DegradedNodes, &out.DegradedNodes
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RollbackThreshold.
+func (in *RollbackThreshold) DeepCopy() *RollbackThreshold {
+	if in == nil {
+		return nil
+	}
+	out := new(RollbackThreshold)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RuntimeClassStatus) DeepCopyInto(out *RuntimeClassStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuntimeClassStatus.
+func (in *RuntimeClassStatus) DeepCopy() *RuntimeClassStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RuntimeClassStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RuntimeClassVariant) DeepCopyInto(out *RuntimeClassVariant) {
+	*out = *in
+	if in.AllowedAnnotations != nil {
+		in, out := &in.AllowedAnnotations, &out.AllowedAnnotations
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Overhead != nil {
+		in, out := &in.Overhead, &out.Overhead
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]corev1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuntimeClassVariant.
+func (in *RuntimeClassVariant) DeepCopy() *RuntimeClassVariant {
+	if in == nil {
+		return nil
+	}
+	out := new(RuntimeClassVariant)
+	in.DeepCopyInto(out)
+	return out
+}
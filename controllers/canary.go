@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	kataconfigurationv2 "github.com/openshift/sandboxed-containers-operator/api/v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const smokeTestName = "sandboxed-containers-smoke-test"
+
+// initCanaryStatus starts the canary installation when it is requested for the first installation
+func (r *KataConfigOpenShiftReconciler) initCanaryStatus() {
+	if r.kataConfig.Spec.Canary == nil || r.kataConfig.Status.Canary != nil ||
+		r.kataConfig.Status.RuntimeClass != "" || r.kataConfig.Status.InstallationStatus.IsInProgress {
+		return
+	}
+	r.Log.Info("Starting the canary installation", "nodeSelector", r.kataConfig.Spec.Canary.NodeSelector)
+	r.kataConfig.Status.Canary = &kataconfigurationv2.CanaryStatus{
+		Phase:              kataconfigurationv2.CanaryPhaseInstalling,
+		ObservedGeneration: r.kataConfig.Generation,
+	}
+}
+
+// isCanaryInProgress tells whether the installation is restricted to the canary nodes
+func (r *KataConfigOpenShiftReconciler) isCanaryInProgress() bool {
+	return r.kataConfig.Spec.Canary != nil && r.kataConfig.Status.Canary != nil &&
+		r.kataConfig.Status.Canary.Phase != kataconfigurationv2.CanaryPhaseSucceeded
+}
+
+// poolNodeSelector returns the node selector of the kata-oc MachineConfigPool, restricted to the
+// canary nodes while the canary installation is in progress
+func (r *KataConfigOpenShiftReconciler) poolNodeSelector() *metav1.LabelSelector {
+	selector := r.kataNodeSelector()
+	if !r.isCanaryInProgress() || selector == nil {
+		return selector
+	}
+
+	selector = selector.DeepCopy()
+	for key, value := range r.kataConfig.Spec.Canary.NodeSelector {
+		selector = metav1.AddLabelToSelector(selector, key, value)
+	}
+	return selector
+}
+
+// processCanary runs the smoke test on the canary nodes once they installed the kata runtime, and
+// extends the installation to all the selected nodes when it passed on all of them
+func (r *KataConfigOpenShiftReconciler) processCanary() (ctrl.Result, error) {
+	canary := r.kataConfig.Status.Canary
+	if canary.Phase == kataconfigurationv2.CanaryPhaseFailed {
+		if canary.ObservedGeneration == r.kataConfig.Generation {
+			r.Log.Info("The canary installation failed, halting the installation", "message", canary.Message)
+			return ctrl.Result{}, nil
+		}
+		r.Log.Info("The KataConfig changed, retrying the canary installation")
+		canary.ObservedGeneration = r.kataConfig.Generation
+	}
+	canary.Phase = kataconfigurationv2.CanaryPhaseTesting
+	canary.Message = ""
+
+	selector, err := metav1.LabelSelectorAsSelector(r.poolNodeSelector())
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	nodes := &corev1.NodeList{}
+	if err = r.Client.List(context.TODO(), nodes, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return ctrl.Result{}, err
+	}
+	if len(nodes.Items) == 0 {
+		canary.Message = "No node matches the canary node selector"
+		return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, nil
+	}
+
+	results, isTestRunning, err := runSmokeTestPods(r.Client, r.Scheme, r.Log, r.kataConfig,
+		r.kataConfig.GetRuntimeClassName(), r.instanceName(smokeTestName), nodes.Items)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if isTestRunning {
+		r.Log.Info("Waiting for the smoke test on the canary nodes")
+		return ctrl.Result{Requeue: true, RequeueAfter: 10 * time.Second}, nil
+	}
+
+	canary.Nodes = nil
+	var failed []string
+	for _, node := range nodes.Items {
+		result := results[node.Name]
+		canary.Nodes = append(canary.Nodes, kataconfigurationv2.CanaryNodeStatus{
+			Name:    node.Name,
+			Passed:  result.passed,
+			Message: result.message,
+		})
+		if !result.passed {
+			failed = append(failed, node.Name)
+		}
+	}
+
+	if err = deleteSmokeTestPods(r.Client, r.instanceName(smokeTestName)); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if len(failed) > 0 {
+		canary.Phase = kataconfigurationv2.CanaryPhaseFailed
+		canary.Message = fmt.Sprintf("The smoke test failed on the canary nodes: %s", strings.Join(failed, ", "))
+		r.Log.Info("The canary installation failed, halting the installation", "message", canary.Message)
+		return ctrl.Result{}, nil
+	}
+
+	r.Log.Info("The smoke test passed on the canary nodes, extending the installation to all the selected nodes")
+	canary.Phase = kataconfigurationv2.CanaryPhaseSucceeded
+	return ctrl.Result{Requeue: true}, nil
+}
@@ -0,0 +1,54 @@
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	kataconfigurationv2 "github.com/openshift/sandboxed-containers-operator/api/v2"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Canary installation", func() {
+	It("Should restrict the pool to the canary nodes until the canary succeeded", func() {
+		r := &KataConfigOpenShiftReconciler{
+			kataConfig: &kataconfigurationv2.KataConfig{
+				Spec: kataconfigurationv2.KataConfigSpec{
+					KataConfigPoolSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{"custom-kata1": "test"},
+					},
+					Canary: &kataconfigurationv2.CanaryConfig{
+						NodeSelector: map[string]string{"kata-canary": "true"},
+					},
+				},
+				Status: kataconfigurationv2.KataConfigStatus{
+					Canary: &kataconfigurationv2.CanaryStatus{Phase: kataconfigurationv2.CanaryPhaseTesting},
+				},
+			},
+		}
+		Expect(r.poolNodeSelector().MatchLabels).Should(Equal(map[string]string{
+			"custom-kata1": "test", "kata-canary": "true"}))
+		Expect(r.kataConfig.Spec.KataConfigPoolSelector.MatchLabels).Should(HaveLen(1))
+
+		r.kataConfig.Status.Canary.Phase = kataconfigurationv2.CanaryPhaseSucceeded
+		Expect(r.poolNodeSelector().MatchLabels).Should(Equal(map[string]string{"custom-kata1": "test"}))
+	})
+
+	It("Should not be Ready when the canary failed", func() {
+		kataConfig := &kataconfigurationv2.KataConfig{
+			Spec: kataconfigurationv2.KataConfigSpec{
+				Canary: &kataconfigurationv2.CanaryConfig{NodeSelector: map[string]string{"kata-canary": "true"}},
+			},
+			Status: kataconfigurationv2.KataConfigStatus{
+				RuntimeClass: "kata",
+				Canary: &kataconfigurationv2.CanaryStatus{
+					Phase:   kataconfigurationv2.CanaryPhaseFailed,
+					Message: "The smoke test failed on the canary nodes: worker0",
+				},
+			},
+		}
+		updateConditions(kataConfig)
+
+		Expect(meta.FindStatusCondition(kataConfig.Status.Conditions,
+			kataconfigurationv2.KataConfigReady).Reason).Should(Equal("CanaryFailed"))
+	})
+})
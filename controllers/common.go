@@ -4,6 +4,9 @@ const (
 	// https://sdk.operatorframework.io/docs/upgrading-sdk-version/v1.4.0/
 	// https://kubernetes.io/docs/tasks/extend-kubernetes/custom-resources/custom-resource-definitions/#finalizers
 	kataConfigFinalizer = "kataconfiguration.openshift.io/finalizer"
+
+	// extensionMcName is the MachineConfig enabling the sandboxed containers RHCOS extension
+	extensionMcName = "50-enable-sandboxed-containers-extension"
 )
 
 func contains(list []string, s string) bool {
@@ -0,0 +1,192 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	kataconfigurationv2 "github.com/openshift/sandboxed-containers-operator/api/v2"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// setCondition sets a condition of the KataConfig status for the current generation
+func setCondition(kataConfig *kataconfigurationv2.KataConfig, conditionType string, status metav1.ConditionStatus,
+	reason string, message string) {
+	meta.SetStatusCondition(&kataConfig.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: kataConfig.Generation,
+	})
+}
+
+// isConditionTrue tells whether a condition of the KataConfig status is true
+func isConditionTrue(kataConfig *kataconfigurationv2.KataConfig, conditionType string) bool {
+	return meta.IsStatusConditionTrue(kataConfig.Status.Conditions, conditionType)
+}
+
+// updateConditions derives the conditions of the KataConfig from the rest of its status
+func updateConditions(kataConfig *kataconfigurationv2.KataConfig) {
+	status := &kataConfig.Status
+
+	installing := status.InstallationStatus.IsInProgress
+	if installing {
+		setCondition(kataConfig, kataconfigurationv2.KataConfigInstalling, metav1.ConditionTrue,
+			"InstallationInProgress", fmt.Sprintf("%d of %d nodes completed installation",
+				status.InstallationStatus.Completed.CompletedNodesCount, status.TotalNodesCount))
+	} else {
+		setCondition(kataConfig, kataconfigurationv2.KataConfigInstalling, metav1.ConditionFalse,
+			"InstallationNotInProgress", "")
+	}
+
+	uninstalling := kataConfig.GetDeletionTimestamp() != nil
+	if uninstalling {
+		message := status.UnInstallationStatus.ErrorMessage
+		if message == "" {
+			message = fmt.Sprintf("%d nodes completed uninstallation",
+				status.UnInstallationStatus.Completed.CompletedNodesCount)
+		}
+		if phase := status.UnInstallationStatus.Phase; phase != "" {
+			message = fmt.Sprintf("%s: %s", phase, message)
+		}
+		setCondition(kataConfig, kataconfigurationv2.KataConfigUninstalling, metav1.ConditionTrue,
+			"UninstallationInProgress", message)
+	} else {
+		setCondition(kataConfig, kataconfigurationv2.KataConfigUninstalling, metav1.ConditionFalse,
+			"UninstallationNotInProgress", "")
+	}
+
+	degraded := false
+	for _, failed := range []kataconfigurationv2.KataFailedNodeStatus{
+		status.InstallationStatus.Failed, status.UnInstallationStatus.Failed, status.UpgradeStatus.Failed} {
+		if failed.FailedReason != "" || len(failed.FailedNodesList) > 0 {
+			message := failed.FailedReason
+			if message == "" {
+				message = fmt.Sprintf("%d nodes failed", len(failed.FailedNodesList))
+			}
+			setCondition(kataConfig, kataconfigurationv2.KataConfigDegraded, metav1.ConditionTrue,
+				"NodesFailed", message)
+			degraded = true
+			break
+		}
+	}
+	if !degraded {
+		setCondition(kataConfig, kataconfigurationv2.KataConfigDegraded, metav1.ConditionFalse,
+			"NoNodesFailed", "")
+	}
+
+	upgrading := status.UpgradeStatus.IsInProgress
+	if upgrading {
+		setCondition(kataConfig, kataconfigurationv2.KataConfigUpgrading, metav1.ConditionTrue,
+			"UpgradeInProgress", fmt.Sprintf("Upgrading from %s to %s: %d of %d nodes completed",
+				status.UpgradeStatus.FromVersion, status.UpgradeStatus.ToVersion,
+				status.UpgradeStatus.Completed.CompletedNodesCount, status.TotalNodesCount))
+	} else {
+		setCondition(kataConfig, kataconfigurationv2.KataConfigUpgrading, metav1.ConditionFalse,
+			"NoUpgradeInProgress", "")
+	}
+
+	if kataConfig.Spec.Paused {
+		converted := status.TotalNodesCount
+		if installing {
+			converted = status.InstallationStatus.Completed.CompletedNodesCount
+		} else if upgrading {
+			converted = status.UpgradeStatus.Completed.CompletedNodesCount
+		}
+		setCondition(kataConfig, kataconfigurationv2.KataConfigPaused, metav1.ConditionTrue,
+			"RolloutPaused", fmt.Sprintf("Paused with %d of %d nodes converted", converted, status.TotalNodesCount))
+	} else {
+		setCondition(kataConfig, kataconfigurationv2.KataConfigPaused, metav1.ConditionFalse,
+			"RolloutNotPaused", "")
+	}
+
+	if status.Rollback != nil {
+		reason := "RollbackInProgress"
+		if status.Rollback.Phase == kataconfigurationv2.RollbackPhaseCompleted {
+			reason = "RollbackCompleted"
+		}
+		setCondition(kataConfig, kataconfigurationv2.KataConfigRolledBack, metav1.ConditionTrue,
+			reason, status.Rollback.Reason)
+	} else {
+		setCondition(kataConfig, kataconfigurationv2.KataConfigRolledBack, metav1.ConditionFalse,
+			"NotRolledBack", "")
+	}
+
+	eligibleCount := countEligibleNodes(status.NodeEligibility)
+	switch {
+	case len(status.NodeEligibility) == 0:
+		setCondition(kataConfig, kataconfigurationv2.KataConfigNodesEligible, metav1.ConditionUnknown,
+			"PreflightNotRun", "The pre-flight check did not run")
+	case eligibleCount == len(status.NodeEligibility):
+		setCondition(kataConfig, kataconfigurationv2.KataConfigNodesEligible, metav1.ConditionTrue,
+			"AllNodesEligible", "All the selected nodes are able to run the kata runtime")
+	default:
+		var notEligible []string
+		for _, result := range status.NodeEligibility {
+			if !result.Eligible {
+				notEligible = append(notEligible, result.Name)
+			}
+		}
+		setCondition(kataConfig, kataconfigurationv2.KataConfigNodesEligible, metav1.ConditionFalse,
+			"NodesNotEligible", fmt.Sprintf("%d of %d selected nodes are not able to run the kata runtime: %s",
+				len(notEligible), len(status.NodeEligibility), strings.Join(notEligible, ", ")))
+	}
+	preflightRefused := len(status.NodeEligibility) > 0 && eligibleCount == 0 &&
+		kataConfig.Spec.GetPreflightPolicy() == kataconfigurationv2.PreflightPolicyEnforce
+
+	var notValidated []string
+	for _, validation := range status.NodeValidation {
+		if !validation.Validated {
+			notValidated = append(notValidated, validation.Name)
+		}
+	}
+
+	readyStatus, readyReason, readyMessage := metav1.ConditionTrue, "Installed", "The kata runtime is installed"
+	switch {
+	case uninstalling:
+		readyStatus, readyReason, readyMessage = metav1.ConditionFalse, "Uninstalling", "The kata runtime is being uninstalled"
+	case status.Rollback != nil:
+		readyStatus, readyReason = metav1.ConditionFalse, "RolledBack"
+		readyMessage = fmt.Sprintf("The installation was rolled back: %s", status.Rollback.Reason)
+	case degraded:
+		readyStatus, readyReason, readyMessage = metav1.ConditionFalse, "Degraded", "Nodes failed to install the kata runtime"
+	case preflightRefused && !installing && status.RuntimeClass == "":
+		readyStatus, readyReason, readyMessage = metav1.ConditionFalse, "NoEligibleNodes", "No selected node is able to run the kata runtime"
+	case kataConfig.Spec.Canary != nil && status.Canary != nil && status.Canary.Phase == kataconfigurationv2.CanaryPhaseFailed:
+		readyStatus, readyReason, readyMessage = metav1.ConditionFalse, "CanaryFailed", status.Canary.Message
+	case kataConfig.Spec.Canary != nil && status.Canary != nil && status.Canary.Phase != kataconfigurationv2.CanaryPhaseSucceeded:
+		readyStatus, readyReason = metav1.ConditionFalse, "CanaryInProgress"
+		readyMessage = fmt.Sprintf("The kata runtime is being installed on the canary nodes: %s", status.Canary.Phase)
+	case status.ClusterTopology == kataconfigurationv2.ClusterTopologyHostedControlPlane && status.NodePoolConfigMap != "" &&
+		status.InstallationStatus.Completed.CompletedNodesCount == 0:
+		readyStatus, readyReason = metav1.ConditionFalse, "WaitingForNodePools"
+		readyMessage = nodePoolConfigMessage(status.NodePoolConfigMap)
+	case installing || status.RuntimeClass == "":
+		readyStatus, readyReason, readyMessage = metav1.ConditionFalse, "Installing", "The kata runtime is being installed"
+	case upgrading:
+		readyStatus, readyReason, readyMessage = metav1.ConditionFalse, "Upgrading", "The kata runtime is being upgraded"
+	case len(status.LeavingNodes) > 0:
+		readyStatus, readyReason = metav1.ConditionFalse, "NodesLeavingPool"
+		readyMessage = fmt.Sprintf("The kata runtime is being removed from the nodes that left the pool: %s",
+			strings.Join(status.LeavingNodes, ", "))
+	case len(notValidated) > 0:
+		readyStatus, readyReason = metav1.ConditionFalse, "ValidationFailed"
+		readyMessage = fmt.Sprintf("Pods using the kata runtime do not run in a virtual machine on: %s, the validation is retried",
+			strings.Join(notValidated, ", "))
+	case len(status.NodeValidation) > 0 && len(status.NodeValidation) < status.TotalNodesCount:
+		readyStatus, readyReason = metav1.ConditionFalse, "Validating"
+		readyMessage = fmt.Sprintf("%d of %d nodes validated", len(status.NodeValidation), status.TotalNodesCount)
+	default:
+		for _, rcStatus := range status.RuntimeClasses {
+			if !rcStatus.Ready {
+				readyStatus, readyReason = metav1.ConditionFalse, "RuntimeClassNotReady"
+				readyMessage = fmt.Sprintf("RuntimeClass %s is not ready: %s", rcStatus.Name, rcStatus.Message)
+				break
+			}
+		}
+	}
+	setCondition(kataConfig, kataconfigurationv2.KataConfigReady, readyStatus, readyReason, readyMessage)
+
+	status.ObservedGeneration = kataConfig.Generation
+}
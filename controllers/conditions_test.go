@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	kataconfigurationv2 "github.com/openshift/sandboxed-containers-operator/api/v2"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("KataConfig conditions", func() {
+	var kataConfig *kataconfigurationv2.KataConfig
+
+	BeforeEach(func() {
+		kataConfig = &kataconfigurationv2.KataConfig{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       "example-kataconfig",
+				Generation: 3,
+			},
+		}
+	})
+
+	It("Should not be Ready while installing", func() {
+		kataConfig.Status.InstallationStatus.IsInProgress = true
+		updateConditions(kataConfig)
+
+		Expect(isConditionTrue(kataConfig, kataconfigurationv2.KataConfigInstalling)).Should(BeTrue())
+		Expect(isConditionTrue(kataConfig, kataconfigurationv2.KataConfigReady)).Should(BeFalse())
+		Expect(kataConfig.Status.ObservedGeneration).Should(Equal(int64(3)))
+	})
+
+	It("Should be Ready once the RuntimeClass is created", func() {
+		kataConfig.Status.InstallationStatus.IsInProgress = false
+		kataConfig.Status.RuntimeClass = "kata"
+		updateConditions(kataConfig)
+
+		Expect(isConditionTrue(kataConfig, kataconfigurationv2.KataConfigReady)).Should(BeTrue())
+		Expect(isConditionTrue(kataConfig, kataconfigurationv2.KataConfigInstalling)).Should(BeFalse())
+		Expect(isConditionTrue(kataConfig, kataconfigurationv2.KataConfigUpgrading)).Should(BeFalse())
+		Expect(meta.FindStatusCondition(kataConfig.Status.Conditions,
+			kataconfigurationv2.KataConfigReady).ObservedGeneration).Should(Equal(int64(3)))
+	})
+
+	It("Should be Degraded when nodes failed", func() {
+		kataConfig.Status.RuntimeClass = "kata"
+		kataConfig.Status.InstallationStatus.Failed.FailedReason = "Node worker0 is reporting: unexpected on-disk state"
+		updateConditions(kataConfig)
+
+		Expect(isConditionTrue(kataConfig, kataconfigurationv2.KataConfigDegraded)).Should(BeTrue())
+		Expect(isConditionTrue(kataConfig, kataconfigurationv2.KataConfigReady)).Should(BeFalse())
+	})
+
+	It("Should not be Ready while an additional RuntimeClass is not ready", func() {
+		kataConfig.Status.RuntimeClass = "kata"
+		kataConfig.Status.RuntimeClasses = []kataconfigurationv2.RuntimeClassStatus{
+			{Name: "kata-debug", Handler: "kata-debug", Ready: false, Message: "Waiting"},
+		}
+		updateConditions(kataConfig)
+
+		Expect(meta.FindStatusCondition(kataConfig.Status.Conditions,
+			kataconfigurationv2.KataConfigReady).Reason).Should(Equal("RuntimeClassNotReady"))
+	})
+
+	It("Should not be Ready when the pre-flight check found no eligible node", func() {
+		kataConfig.Spec.PreflightPolicy = kataconfigurationv2.PreflightPolicyEnforce
+		kataConfig.Status.NodeEligibility = []kataconfigurationv2.NodeEligibilityStatus{
+			{Name: "worker0", Eligible: false, Message: "/dev/kvm is not present on the node"},
+		}
+		updateConditions(kataConfig)
+
+		Expect(meta.FindStatusCondition(kataConfig.Status.Conditions,
+			kataconfigurationv2.KataConfigNodesEligible).Status).Should(Equal(metav1.ConditionFalse))
+		Expect(meta.FindStatusCondition(kataConfig.Status.Conditions,
+			kataconfigurationv2.KataConfigReady).Reason).Should(Equal("NoEligibleNodes"))
+	})
+
+	It("Should report the uninstallation phase", func() {
+		now := metav1.Now()
+		kataConfig.DeletionTimestamp = &now
+		kataConfig.Status.UnInstallationStatus.Phase = kataconfigurationv2.UninstallPhasePoolRolling
+		updateConditions(kataConfig)
+
+		Expect(isConditionTrue(kataConfig, kataconfigurationv2.KataConfigUninstalling)).Should(BeTrue())
+		Expect(meta.FindStatusCondition(kataConfig.Status.Conditions,
+			kataconfigurationv2.KataConfigUninstalling).Message).Should(HavePrefix("PoolRolling: "))
+	})
+
+	It("Should report how many nodes were converted while paused", func() {
+		kataConfig.Spec.Paused = true
+		kataConfig.Status.TotalNodesCount = 60
+		kataConfig.Status.InstallationStatus.IsInProgress = true
+		kataConfig.Status.InstallationStatus.Completed.CompletedNodesCount = 12
+		updateConditions(kataConfig)
+
+		Expect(isConditionTrue(kataConfig, kataconfigurationv2.KataConfigPaused)).Should(BeTrue())
+		Expect(meta.FindStatusCondition(kataConfig.Status.Conditions,
+			kataconfigurationv2.KataConfigPaused).Message).Should(Equal("Paused with 12 of 60 nodes converted"))
+	})
+
+	It("Should not be Ready while nodes are leaving the pool", func() {
+		kataConfig.Status.RuntimeClass = "kata"
+		kataConfig.Status.LeavingNodes = []string{"worker2"}
+		updateConditions(kataConfig)
+
+		ready := meta.FindStatusCondition(kataConfig.Status.Conditions, kataconfigurationv2.KataConfigReady)
+		Expect(ready.Status).Should(Equal(metav1.ConditionFalse))
+		Expect(ready.Reason).Should(Equal("NodesLeavingPool"))
+	})
+})
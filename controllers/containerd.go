@@ -0,0 +1,252 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	kataconfigurationv2 "github.com/openshift/sandboxed-containers-operator/api/v2"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	containerdConfigurerName = "sandboxed-containers-containerd"
+
+	// containerdRuntimeType is the containerd shim of the kata runtime
+	containerdRuntimeType = "io.containerd.kata.v2"
+
+	// kataArtifactsDefaultsDir holds the kata configurations installed with the kata artifacts
+	kataArtifactsDefaultsDir = "/opt/kata/share/defaults/kata-containers"
+
+	// kataConfigurationPath is the default kata configuration installed with the kata artifacts
+	kataConfigurationPath = kataArtifactsDefaultsDir + "/configuration.toml"
+
+	// containerdHandlerAnnotation is set on the nodes to the runtime handler configured in containerd
+	containerdHandlerAnnotation = "kataconfiguration.openshift.io/containerd-runtime-handler"
+
+	// cordonedAnnotation is set on the nodes cordoned to restart containerd, to true when the
+	// operator cordoned them, so that the nodes cordoned by an administrator stay cordoned
+	cordonedAnnotation = "kataconfiguration.openshift.io/cordoned"
+)
+
+// containerdConfigScript replaces the section of the operator in the containerd configuration
+// with the RUNTIME_ENTRY environment variable and restarts containerd
+const containerdConfigScript = `set -e
+config=/etc/containerd/config.toml
+[ -s "$config" ] || echo 'version = 2' > "$config"
+sed -i '/^# BEGIN sandboxed-containers$/,/^# END sandboxed-containers$/d' "$config"
+if [ -n "$RUNTIME_ENTRY" ]; then
+  printf '%s\n' "$RUNTIME_ENTRY" >> "$config"
+fi
+systemctl restart containerd
+`
+
+// isContainerdNode tells whether the node runs containerd
+func isContainerdNode(node *corev1.Node) bool {
+	return strings.HasPrefix(node.Status.NodeInfo.ContainerRuntimeVersion, "containerd://")
+}
+
+// isNodeReady tells whether the kubelet of the node reports it as ready
+func isNodeReady(node *corev1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// containerdRuntimeEntry returns the section of the containerd configuration declaring the
+// runtime handler of the kata runtime with the given kata configuration, or an empty string to
+// remove it
+func containerdRuntimeEntry(handler string, configPath string) string {
+	if handler == "" {
+		return ""
+	}
+	runtime := fmt.Sprintf(`plugins."io.containerd.grpc.v1.cri".containerd.runtimes.%s`, handler)
+	return fmt.Sprintf(`# BEGIN sandboxed-containers
+[%s]
+  runtime_type = "%s"
+  privileged_without_host_devices = true
+  pod_annotations = ["io.katacontainers.*"]
+[%s.options]
+  ConfigPath = "%s"
+# END sandboxed-containers`, runtime, containerdRuntimeType, runtime, configPath)
+}
+
+// kataConfigPath returns the kata configuration of the hypervisor selected by the KataConfig
+func (r *KataConfigKubernetesReconciler) kataConfigPath() string {
+	hypervisor := r.kataConfig.Spec.GetHypervisor()
+	if hypervisor == kataconfigurationv2.HypervisorQEMU {
+		return kataConfigurationPath
+	}
+	return hypervisorConfigPath(kataArtifactsDefaultsDir, hypervisor)
+}
+
+// newContainerdConfigurerPod returns the pod updating the containerd configuration of a node
+func (r *KataConfigKubernetesReconciler) newContainerdConfigurerPod(nodeName string, handler string) *corev1.Pod {
+	name := containerdConfigurerName + r.kataConfig.NameSuffix()
+	privileged := true
+	automountToken := false
+
+	hostPath := func(name string, path string) corev1.Volume {
+		return corev1.Volume{Name: name, VolumeSource: corev1.VolumeSource{
+			HostPath: &corev1.HostPathVolumeSource{Path: path}}}
+	}
+
+	return &corev1.Pod{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Pod",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name + "-" + nodeName,
+			Namespace: operatorNamespace,
+			Labels:    map[string]string{"app": name},
+		},
+		Spec: corev1.PodSpec{
+			NodeName:      nodeName,
+			RestartPolicy: corev1.RestartPolicyNever,
+			/* The pod only works on the node, it does not need to reach the API server */
+			AutomountServiceAccountToken: &automountToken,
+			Tolerations:                  []corev1.Toleration{{Operator: corev1.TolerationOpExists}},
+			Containers: []corev1.Container{{
+				Name:            "configure",
+				Image:           installerImage(),
+				Command:         []string{"bash", "-c", containerdConfigScript},
+				Env:             []corev1.EnvVar{{Name: "RUNTIME_ENTRY", Value: containerdRuntimeEntry(handler, r.kataConfigPath())}},
+				SecurityContext: &corev1.SecurityContext{Privileged: &privileged},
+				VolumeMounts: []corev1.VolumeMount{
+					{Name: "containerd-conf", MountPath: "/etc/containerd/"},
+					{Name: "dbus", MountPath: "/var/run/dbus/system_bus_socket"},
+					{Name: "systemd", MountPath: "/run/systemd/system"},
+				},
+			}},
+			Volumes: []corev1.Volume{
+				hostPath("containerd-conf", "/etc/containerd/"),
+				hostPath("dbus", "/var/run/dbus/system_bus_socket"),
+				hostPath("systemd", "/run/systemd/system"),
+			},
+		},
+	}
+}
+
+// setCordoned cordons the node to restart containerd, or uncordons it once restarted
+func (r *KataConfigKubernetesReconciler) setCordoned(node *corev1.Node, cordoned bool) error {
+	patch := client.MergeFrom(node.DeepCopy())
+	if cordoned {
+		if node.Annotations == nil {
+			node.Annotations = map[string]string{}
+		}
+		node.Annotations[cordonedAnnotation] = fmt.Sprint(!node.Spec.Unschedulable)
+		node.Spec.Unschedulable = true
+		r.Log.Info("Cordoning the node to restart containerd", "node", node.Name)
+	} else {
+		if node.Annotations[cordonedAnnotation] == "true" {
+			node.Spec.Unschedulable = false
+		}
+		delete(node.Annotations, cordonedAnnotation)
+		r.Log.Info("Uncordoning the node restarted containerd on", "node", node.Name)
+	}
+	return r.Client.Patch(context.TODO(), node, patch)
+}
+
+// setContainerdHandler records the runtime handler configured in containerd on the node
+func (r *KataConfigKubernetesReconciler) setContainerdHandler(node *corev1.Node, handler string) error {
+	patch := client.MergeFrom(node.DeepCopy())
+	if handler == "" {
+		delete(node.Annotations, containerdHandlerAnnotation)
+	} else {
+		if node.Annotations == nil {
+			node.Annotations = map[string]string{}
+		}
+		node.Annotations[containerdHandlerAnnotation] = handler
+	}
+	return r.Client.Patch(context.TODO(), node, patch)
+}
+
+// configureContainerd configures the given runtime handler, or removes it when empty, in
+// containerd on the nodes running it. The nodes are cordoned and containerd restarted one node
+// at a time. It returns the configuration status of each node running containerd.
+func (r *KataConfigKubernetesReconciler) configureContainerd(nodes []corev1.Node, handler string) ([]kataconfigurationv2.ContainerdNodeStatus, error) {
+	pods, err := r.listPodsByNode(containerdConfigurerName + r.kataConfig.NameSuffix())
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []kataconfigurationv2.ContainerdNodeStatus
+	var pending []int
+	isRestarting := false
+	for i := range nodes {
+		node := &nodes[i]
+		if !isContainerdNode(node) {
+			continue
+		}
+		status := kataconfigurationv2.ContainerdNodeStatus{Name: node.Name}
+		pod, hasPod := pods[node.Name]
+		_, isCordoned := node.Annotations[cordonedAnnotation]
+
+		switch {
+		case hasPod && pod.Status.Phase == corev1.PodFailed:
+			status.Phase = kataconfigurationv2.ContainerdNodeFailed
+			status.Message = fmt.Sprintf("Updating the containerd configuration failed, delete pod %s to retry", pod.Name)
+			if isCordoned {
+				if err = r.setCordoned(node, false); err != nil {
+					return nil, err
+				}
+			}
+
+		case isCordoned:
+			status.Phase = kataconfigurationv2.ContainerdNodeRestarting
+			isRestarting = true
+			if !hasPod {
+				pod := r.newContainerdConfigurerPod(node.Name, handler)
+				if err = controllerutil.SetControllerReference(r.kataConfig, pod, r.Scheme); err != nil {
+					return nil, err
+				}
+				r.Log.Info("Updating the containerd configuration", "node", node.Name, "handler", handler)
+				if err = r.Client.Create(context.TODO(), pod); err != nil && !k8serrors.IsAlreadyExists(err) {
+					return nil, err
+				}
+			} else if pod.Status.Phase == corev1.PodSucceeded && isNodeReady(node) {
+				if err = r.setContainerdHandler(node, handler); err != nil {
+					return nil, err
+				}
+				if err = r.setCordoned(node, false); err != nil {
+					return nil, err
+				}
+				if err = r.Client.Delete(context.TODO(), &pod); err != nil && !k8serrors.IsNotFound(err) {
+					return nil, err
+				}
+				status.Phase = kataconfigurationv2.ContainerdNodeConfigured
+				isRestarting = false
+			}
+
+		case node.Annotations[containerdHandlerAnnotation] == handler:
+			status.Phase = kataconfigurationv2.ContainerdNodeConfigured
+
+		default:
+			status.Phase = kataconfigurationv2.ContainerdNodePending
+			pending = append(pending, len(statuses))
+		}
+		statuses = append(statuses, status)
+	}
+
+	/* Restart containerd on the next node once the previous one is back */
+	if !isRestarting && len(pending) > 0 {
+		status := &statuses[pending[0]]
+		for i := range nodes {
+			if nodes[i].Name == status.Name {
+				if err = r.setCordoned(&nodes[i], true); err != nil {
+					return nil, err
+				}
+			}
+		}
+		status.Phase = kataconfigurationv2.ContainerdNodeRestarting
+	}
+	return statuses, nil
+}
@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	kataconfigurationv2 "github.com/openshift/sandboxed-containers-operator/api/v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newContainerdNode(name string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: corev1.NodeStatus{
+			NodeInfo:   corev1.NodeSystemInfo{ContainerRuntimeVersion: "containerd://1.5.2"},
+			Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+		},
+	}
+}
+
+var _ = Describe("containerd configuration", func() {
+	It("Should declare the kata shim for the runtime handler", func() {
+		entry := containerdRuntimeEntry("kata", kataConfigurationPath)
+		Expect(entry).Should(ContainSubstring(`[plugins."io.containerd.grpc.v1.cri".containerd.runtimes.kata]`))
+		Expect(entry).Should(ContainSubstring(`runtime_type = "io.containerd.kata.v2"`))
+		Expect(containerdRuntimeEntry("", "")).Should(BeEmpty())
+	})
+
+	It("Should wait for containerd to be configured on the installed nodes", func() {
+		nodes := []corev1.Node{*newContainerdNode("worker0"), *newContainerdNode("worker1")}
+		nodes[0].Labels = map[string]string{kataDeployLabel: "true"}
+		nodes[1].Labels = map[string]string{kataDeployLabel: "true"}
+		containerdNodes := []kataconfigurationv2.ContainerdNodeStatus{
+			{Name: "worker0", Phase: kataconfigurationv2.ContainerdNodeConfigured},
+			{Name: "worker1", Phase: kataconfigurationv2.ContainerdNodeFailed, Message: "failed"},
+		}
+
+		status := installerNodesStatus(nodes, map[string]corev1.Pod{}, containerdNodes)
+		Expect(status.Completed.CompletedNodesList).Should(Equal([]string{"worker0"}))
+		Expect(status.Failed.FailedNodesList).Should(Equal([]kataconfigurationv2.FailedNodeStatus{{Name: "worker1", Error: "failed"}}))
+	})
+
+	It("Should restart containerd one node at a time", func() {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(kataconfigurationv2.AddToScheme(scheme)).To(Succeed())
+		kataConfig := &kataconfigurationv2.KataConfig{ObjectMeta: metav1.ObjectMeta{Name: "example-kataconfig", UID: "uid"}}
+		c := fake.NewClientBuilder().WithScheme(scheme).
+			WithObjects(newContainerdNode("worker0"), newContainerdNode("worker1")).Build()
+		r := &KataConfigKubernetesReconciler{
+			Client:     c,
+			Log:        ctrl.Log.WithName("test"),
+			Scheme:     scheme,
+			kataConfig: kataConfig,
+		}
+		configure := func() []kataconfigurationv2.ContainerdNodeStatus {
+			nodes := &corev1.NodeList{}
+			Expect(c.List(context.TODO(), nodes)).To(Succeed())
+			statuses, err := r.configureContainerd(nodes.Items, "kata")
+			Expect(err).ToNot(HaveOccurred())
+			return statuses
+		}
+		getNode := func(name string) *corev1.Node {
+			node := &corev1.Node{}
+			Expect(c.Get(context.TODO(), types.NamespacedName{Name: name}, node)).To(Succeed())
+			return node
+		}
+
+		By("Cordoning the first node")
+		statuses := configure()
+		Expect(statuses[0].Phase).Should(Equal(kataconfigurationv2.ContainerdNodeRestarting))
+		Expect(statuses[1].Phase).Should(Equal(kataconfigurationv2.ContainerdNodePending))
+		Expect(getNode("worker0").Spec.Unschedulable).Should(BeTrue())
+
+		By("Updating its configuration")
+		configure()
+		pod := &corev1.Pod{}
+		podName := types.NamespacedName{Name: "sandboxed-containers-containerd-worker0", Namespace: operatorNamespace}
+		Expect(c.Get(context.TODO(), podName, pod)).To(Succeed())
+		Expect(pod.Spec.NodeName).Should(Equal("worker0"))
+		Expect(*pod.Spec.AutomountServiceAccountToken).Should(BeFalse())
+
+		By("Moving to the next node once containerd restarted")
+		pod.Status.Phase = corev1.PodSucceeded
+		Expect(c.Status().Update(context.TODO(), pod)).To(Succeed())
+		statuses = configure()
+		Expect(statuses[0].Phase).Should(Equal(kataconfigurationv2.ContainerdNodeConfigured))
+		Expect(statuses[1].Phase).Should(Equal(kataconfigurationv2.ContainerdNodeRestarting))
+		Expect(getNode("worker0").Spec.Unschedulable).Should(BeFalse())
+		Expect(getNode("worker0").Annotations[containerdHandlerAnnotation]).Should(Equal("kata"))
+		Expect(getNode("worker1").Spec.Unschedulable).Should(BeTrue())
+	})
+
+	It("Should keep the nodes cordoned by an administrator cordoned", func() {
+		node := newContainerdNode("worker0")
+		node.Spec.Unschedulable = true
+		c := fake.NewClientBuilder().WithObjects(node).Build()
+		r := &KataConfigKubernetesReconciler{Client: c, Log: ctrl.Log.WithName("test")}
+
+		Expect(r.setCordoned(node, true)).To(Succeed())
+		Expect(node.Annotations[cordonedAnnotation]).Should(Equal("false"))
+		Expect(r.setCordoned(node, false)).To(Succeed())
+		Expect(node.Spec.Unschedulable).Should(BeTrue())
+	})
+})
@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	ignTypes "github.com/coreos/ignition/v2/config/v3_2/types"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// debugMcName is the MachineConfig holding the kata configuration drop-in enabling
+// the debug mode
+const debugMcName = "50-sandboxed-containers-debug"
+
+// renderDebugConfig returns the kata configuration drop-in enabling the debug output of
+// the runtime, the agent and the hypervisor, and the agent debug console
+func renderDebugConfig(hypervisor string) string {
+	return fmt.Sprintf("[hypervisor.%s]\n", hypervisor) +
+		"enable_debug = true\n" +
+		"\n" +
+		"[agent.kata]\n" +
+		"enable_debug = true\n" +
+		"debug_console_enabled = true\n" +
+		"\n" +
+		"[runtime]\n" +
+		"enable_debug = true\n"
+}
+
+// debugFiles returns the kata configuration drop-ins needed by the debug mode
+func (r *KataConfigOpenShiftReconciler) debugFiles() []ignTypes.File {
+	if !r.kataConfig.Spec.Debug {
+		return nil
+	}
+	return []ignTypes.File{newIgnitionFile(kataDropInDir+"/90-sandboxed-containers-debug.toml",
+		renderDebugConfig(r.hypervisorSection()))}
+}
+
+// reconcileDebugMc enables or disables the debug mode. It returns true if the MachineConfig
+// was changed and the pool has to roll out the change.
+func (r *KataConfigOpenShiftReconciler) reconcileDebugMc(machinePool string) (bool, error) {
+	changed, err := r.applyMcWithFiles(r.mcName(debugMcName, machinePool), machinePool, r.debugFiles())
+	if err != nil {
+		r.Log.Error(err, "Failed to apply the debug MachineConfig")
+		return false, err
+	}
+	return changed, nil
+}
+
+// updateDebugStatus lists the nodes of the pool running with the debug mode enabled, i.e.
+// the nodes running the rendered configuration the debug MachineConfig is part of, along
+// with the runtime handlers MachineConfig making kata load the debug drop-in
+func (r *KataConfigOpenShiftReconciler) updateDebugStatus(mcp *mcfgv1.MachineConfigPool) error {
+	r.kataConfig.Status.DebugNodes = nil
+
+	if !r.hasPoolNames() {
+		return r.addDebugNodes(mcp, r.instanceName(debugMcName), r.instanceName(runtimeHandlersMcName))
+	}
+
+	pools, err := r.listNamedPools()
+	if err != nil {
+		return err
+	}
+	for i := range pools {
+		if err = r.addDebugNodes(&pools[i], r.mcName(debugMcName, pools[i].Name),
+			r.mcName(runtimeHandlersMcName, pools[i].Name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addDebugNodes adds the nodes of the pool running the rendered configuration the named debug
+// and runtime handlers MachineConfigs are part of to the debug nodes
+func (r *KataConfigOpenShiftReconciler) addDebugNodes(mcp *mcfgv1.MachineConfigPool, debugMc string, handlersMc string) error {
+	if !isMcInConfiguration(debugMc, mcp.Status.Configuration) ||
+		!isMcInConfiguration(handlersMc, mcp.Status.Configuration) {
+		return nil
+	}
+
+	nodes := &corev1.NodeList{}
+	var listOpts []client.ListOption
+	if mcp.Spec.NodeSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(mcp.Spec.NodeSelector)
+		if err != nil {
+			return err
+		}
+		listOpts = append(listOpts, client.MatchingLabelsSelector{Selector: selector})
+	}
+	if err := r.Client.List(context.TODO(), nodes, listOpts...); err != nil {
+		r.Log.Error(err, "Getting list of nodes failed")
+		return err
+	}
+
+	for _, node := range nodes.Items {
+		if node.Annotations["machineconfiguration.openshift.io/currentConfig"] == mcp.Status.Configuration.Name {
+			r.kataConfig.Status.DebugNodes = append(r.kataConfig.Status.DebugNodes, node.Name)
+		}
+	}
+	return nil
+}
+
+// isMcInConfiguration tells whether the named MachineConfig is part of a rendered configuration
+func isMcInConfiguration(name string, configuration mcfgv1.MachineConfigPoolStatusConfiguration) bool {
+	for _, source := range configuration.Source {
+		if source.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	kataconfigurationv2 "github.com/openshift/sandboxed-containers-operator/api/v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Debug mode", func() {
+	It("Should enable the debug output of the runtime, agent and hypervisor", func() {
+		dropIn := renderDebugConfig("qemu")
+		Expect(dropIn).Should(ContainSubstring("[hypervisor.qemu]\nenable_debug = true\n"))
+		Expect(dropIn).Should(ContainSubstring("[agent.kata]\nenable_debug = true\ndebug_console_enabled = true\n"))
+		Expect(dropIn).Should(ContainSubstring("[runtime]\nenable_debug = true\n"))
+	})
+
+	It("Should tell whether the debug MachineConfig is part of the rendered configuration", func() {
+		configuration := mcfgv1.MachineConfigPoolStatusConfiguration{
+			ObjectReference: corev1.ObjectReference{Name: "rendered-kata-oc-1234"},
+			Source: []corev1.ObjectReference{
+				{Name: "50-enable-sandboxed-containers-extension"},
+			},
+		}
+		Expect(isMcInConfiguration(debugMcName, configuration)).Should(BeFalse())
+
+		configuration.Source = append(configuration.Source, corev1.ObjectReference{Name: debugMcName})
+		Expect(isMcInConfiguration(debugMcName, configuration)).Should(BeTrue())
+	})
+
+	It("Should only report the nodes loading the debug drop-in", func() {
+		node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+			Name:        "worker0",
+			Annotations: map[string]string{"machineconfiguration.openshift.io/currentConfig": "rendered-kata-oc-1234"},
+		}}
+		r := &KataConfigOpenShiftReconciler{
+			Client:     fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(node).Build(),
+			kataConfig: &kataconfigurationv2.KataConfig{},
+		}
+		mcp := &mcfgv1.MachineConfigPool{Status: mcfgv1.MachineConfigPoolStatus{
+			Configuration: mcfgv1.MachineConfigPoolStatusConfiguration{
+				ObjectReference: corev1.ObjectReference{Name: "rendered-kata-oc-1234"},
+				Source:          []corev1.ObjectReference{{Name: debugMcName}},
+			},
+		}}
+
+		By("Waiting for the runtime handlers to load the copied configuration")
+		Expect(r.updateDebugStatus(mcp)).To(Succeed())
+		Expect(r.kataConfig.Status.DebugNodes).Should(BeEmpty())
+
+		mcp.Status.Configuration.Source = append(mcp.Status.Configuration.Source, corev1.ObjectReference{Name: runtimeHandlersMcName})
+		Expect(r.updateDebugStatus(mcp)).To(Succeed())
+		Expect(r.kataConfig.Status.DebugNodes).Should(Equal([]string{"worker0"}))
+	})
+})
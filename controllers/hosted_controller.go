@@ -0,0 +1,525 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	ignTypes "github.com/coreos/ignition/v2/config/v3_2/types"
+	kataconfigurationv2 "github.com/openshift/sandboxed-containers-operator/api/v2"
+	corev1 "k8s.io/api/core/v1"
+	nodeapi "k8s.io/api/node/v1beta1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// nodePoolConfigName is the ConfigMap holding the MachineConfig the NodePools reference
+	nodePoolConfigName = "sandboxed-containers-nodepool-config"
+
+	// nodePoolConfigKey is the key of the ConfigMap a NodePool reads the MachineConfig from
+	nodePoolConfigKey = "config"
+
+	// The machine config daemon annotations reporting the rollout of a configuration on a node
+	currentConfigAnnotation = "machineconfiguration.openshift.io/currentConfig"
+	desiredConfigAnnotation = "machineconfiguration.openshift.io/desiredConfig"
+	stateAnnotation         = "machineconfiguration.openshift.io/state"
+	reasonAnnotation        = "machineconfiguration.openshift.io/reason"
+)
+
+// KataConfigHostedReconciler reconciles a KataConfig object on OpenShift clusters with a hosted
+// control plane. There is no MachineConfigPool in these clusters, the extension MachineConfig is
+// delivered in a ConfigMap the NodePools reference, and the rollout is tracked on the nodes.
+// The nodes are selected and the RuntimeClass is scheduled like on Kubernetes clusters.
+type KataConfigHostedReconciler struct {
+	KataConfigKubernetesReconciler
+}
+
+func (r *KataConfigHostedReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	_ = r.Log.WithValues("kataconfig", req.NamespacedName)
+	r.Log.Info("Reconciling KataConfig in OpenShift Cluster with a hosted control plane")
+
+	// Fetch the KataConfig instance
+	r.kataConfig = &kataconfigurationv2.KataConfig{}
+	err := r.Client.Get(context.TODO(), req.NamespacedName, r.kataConfig)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		r.Log.Error(err, "Cannot retrieve kataConfig")
+		return ctrl.Result{}, err
+	}
+
+	if r.kataConfig.GetDeletionTimestamp() != nil {
+		res, err := r.processKataConfigDeleteRequest()
+		updateConditions(r.kataConfig)
+		updateErr := r.Client.Status().Update(context.TODO(), r.kataConfig)
+		// the KataConfig is gone once the uninstallation removed the finalizer
+		if updateErr != nil && !k8serrors.IsNotFound(updateErr) {
+			return ctrl.Result{}, updateErr
+		}
+		return res, err
+	}
+
+	res, err := r.processKataConfigInstallRequest()
+	updateConditions(r.kataConfig)
+	if updateErr := r.Client.Status().Update(context.TODO(), r.kataConfig); updateErr != nil {
+		return ctrl.Result{}, updateErr
+	}
+	return res, err
+}
+
+// newNodePoolConfigMap returns the ConfigMap holding the extension MachineConfig of the KataConfig
+func (r *KataConfigHostedReconciler) newNodePoolConfigMap() (*corev1.ConfigMap, error) {
+	mc, err := newExtensionMc(extensionMcName+r.kataConfig.NameSuffix(), "worker", r.kataConfig.Name)
+	if err != nil {
+		return nil, err
+	}
+	mc.Namespace = ""
+
+	/* The runtime handler of an alternative hypervisor is delivered with the extension */
+	if files := defaultRuntimeHandlerFiles(&r.kataConfig.Spec, ""); len(files) > 0 {
+		ic := ignTypes.Config{
+			Ignition: ignTypes.Ignition{Version: "3.2.0"},
+			Storage:  ignTypes.Storage{Files: files},
+		}
+		if mc.Spec.Config.Raw, err = json.Marshal(ic); err != nil {
+			return nil, err
+		}
+	}
+
+	config, err := json.Marshal(mc)
+	if err != nil {
+		return nil, err
+	}
+
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      nodePoolConfigName + r.kataConfig.NameSuffix(),
+			Namespace: operatorNamespace,
+		},
+		Data: map[string]string{nodePoolConfigKey: string(config)},
+	}, nil
+}
+
+// applyNodePoolConfig creates or updates the ConfigMap the NodePools reference. It returns true
+// if the ConfigMap was changed and the NodePools have to roll out the change.
+func (r *KataConfigHostedReconciler) applyNodePoolConfig(cm *corev1.ConfigMap) (bool, error) {
+	if err := controllerutil.SetControllerReference(r.kataConfig, cm, r.Scheme); err != nil {
+		return false, err
+	}
+
+	foundCm := &corev1.ConfigMap{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: cm.Name, Namespace: cm.Namespace}, foundCm)
+	if err != nil && k8serrors.IsNotFound(err) {
+		r.Log.Info("Creating the NodePool configuration", "cm.Name", cm.Name)
+		return true, r.Client.Create(context.TODO(), cm)
+	} else if err != nil {
+		return false, err
+	}
+
+	if foundCm.Data[nodePoolConfigKey] == cm.Data[nodePoolConfigKey] {
+		return false, nil
+	}
+	r.Log.Info("Updating the NodePool configuration", "cm.Name", cm.Name)
+	foundCm.Data = cm.Data
+	return true, r.Client.Update(context.TODO(), foundCm)
+}
+
+// deleteNodePoolConfig deletes the ConfigMap the NodePools reference
+func (r *KataConfigHostedReconciler) deleteNodePoolConfig() error {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      nodePoolConfigName + r.kataConfig.NameSuffix(),
+			Namespace: operatorNamespace,
+		},
+	}
+	r.Log.Info("Deleting the NodePool configuration", "cm.Name", cm.Name)
+	err := r.Client.Delete(context.TODO(), cm)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// initialNodeConfigs records the configuration the nodes run when a rollout starts
+func initialNodeConfigs(nodes []corev1.Node) []kataconfigurationv2.NodePoolNodeStatus {
+	var statuses []kataconfigurationv2.NodePoolNodeStatus
+	for _, node := range nodes {
+		statuses = append(statuses, kataconfigurationv2.NodePoolNodeStatus{
+			Name:          node.Name,
+			InitialConfig: node.Annotations[currentConfigAnnotation],
+		})
+	}
+	return statuses
+}
+
+// nodePoolRolloutStatus returns the progress of a NodePool rollout on the selected nodes. The
+// nodes updated in place rolled it out once they run a configuration other than the initial one.
+// The nodes joining after the rollout started replace former nodes, and rolled it out once ready.
+func nodePoolRolloutStatus(nodes []corev1.Node, initialConfigs []kataconfigurationv2.NodePoolNodeStatus) kataconfigurationv2.KataNodesStatus {
+	initial := map[string]string{}
+	for _, initialConfig := range initialConfigs {
+		initial[initialConfig.Name] = initialConfig.InitialConfig
+	}
+
+	status := kataconfigurationv2.KataNodesStatus{}
+	for _, node := range nodes {
+		initialConfig, isKnown := initial[node.Name]
+		currentConfig := node.Annotations[currentConfigAnnotation]
+		switch {
+		case node.Annotations[stateAnnotation] == "Degraded":
+			status.Failed.FailedNodesList = append(status.Failed.FailedNodesList,
+				kataconfigurationv2.FailedNodeStatus{Name: node.Name, Error: node.Annotations[reasonAnnotation]})
+		case !isKnown && isNodeReady(&node),
+			isKnown && currentConfig != initialConfig && currentConfig == node.Annotations[desiredConfigAnnotation] &&
+				node.Annotations[stateAnnotation] == "Done":
+			status.Completed.CompletedNodesList = append(status.Completed.CompletedNodesList, node.Name)
+		default:
+			status.InProgress.InProgressNodesList = append(status.InProgress.InProgressNodesList, node.Name)
+		}
+	}
+
+	countNodesStatus(&status)
+	return status
+}
+
+// nodePoolNodesStatus returns the progress of the installation on the selected nodes from the
+// progress of the rollout. A node that rolled out the configuration only completed the
+// installation once its validation passed: neither a new node nor a new configuration, which an
+// upgrade of the NodePool also brings, proves that the node runs the extension.
+func nodePoolNodesStatus(rollout kataconfigurationv2.KataNodesStatus, validations []kataconfigurationv2.NodeValidationStatus) kataconfigurationv2.KataNodesStatus {
+	validated := map[string]bool{}
+	for _, validation := range validations {
+		validated[validation.Name] = validation.Validated
+	}
+
+	status := rollout
+	status.Completed = kataconfigurationv2.KataConfigCompletedStatus{}
+	for _, name := range rollout.Completed.CompletedNodesList {
+		if validated[name] {
+			status.Completed.CompletedNodesList = append(status.Completed.CompletedNodesList, name)
+		} else {
+			status.InProgress.InProgressNodesList = append(status.InProgress.InProgressNodesList, name)
+		}
+	}
+
+	countNodesStatus(&status)
+	return status
+}
+
+// countNodesStatus sets the counts and the summary of the node lists of a status
+func countNodesStatus(status *kataconfigurationv2.KataNodesStatus) {
+	status.Completed.CompletedNodesCount = len(status.Completed.CompletedNodesList)
+	status.InProgress.InProgressNodesCount = len(status.InProgress.InProgressNodesList)
+	status.Failed.FailedNodesCount = len(status.Failed.FailedNodesList)
+	if status.Failed.FailedNodesCount > 0 {
+		status.Failed.FailedReason = fmt.Sprintf("%s: %s", status.Failed.FailedNodesList[0].Name,
+			status.Failed.FailedNodesList[0].Error)
+	}
+	status.IsInProgress = status.InProgress.InProgressNodesCount > 0 || status.Failed.FailedNodesCount > 0
+}
+
+// setValidationRuntimeClass creates the RuntimeClass the validation pods use. It runs the handler
+// of the kata RuntimeClass without its node selector, as the nodes are only labelled with it once
+// validated.
+func (r *KataConfigHostedReconciler) setValidationRuntimeClass() error {
+	rc := &nodeapi.RuntimeClass{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "node.k8s.io/v1beta1",
+			Kind:       "RuntimeClass",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: validationName + r.kataConfig.NameSuffix(),
+		},
+		Handler: r.kataConfig.Spec.GetRuntimeClassHandler(),
+		Overhead: &nodeapi.Overhead{
+			PodFixed: r.kataConfig.Spec.GetRuntimeClassOverhead(),
+		},
+	}
+	if err := controllerutil.SetControllerReference(r.kataConfig, rc, r.Scheme); err != nil {
+		return err
+	}
+
+	foundRc := &nodeapi.RuntimeClass{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: rc.Name}, foundRc)
+	if err != nil && k8serrors.IsNotFound(err) {
+		r.Log.Info("Creating the validation RuntimeClass", "rc.Name", rc.Name)
+		return r.Client.Create(context.TODO(), rc)
+	}
+	return err
+}
+
+// validateNodePoolNodes runs a validation pod on each of the given nodes that rolled out the
+// configuration and was not validated yet, or whose validation failed and is due for a retry,
+// and records the results of the selected nodes in the status
+func (r *KataConfigHostedReconciler) validateNodePoolNodes(nodes []corev1.Node, rolledOut []string) error {
+	previous := map[string]kataconfigurationv2.NodeValidationStatus{}
+	for _, validation := range r.kataConfig.Status.NodeValidation {
+		previous[validation.Name] = validation
+	}
+
+	now := time.Now()
+	var pending []corev1.Node
+	for _, node := range nodes {
+		validation, ok := previous[node.Name]
+		if !contains(rolledOut, node.Name) || ok && (validation.Validated || nextValidationAttempt(&validation).After(now)) {
+			continue
+		}
+		pending = append(pending, node)
+	}
+
+	results := map[string]smokeTestResult{}
+	if len(pending) > 0 {
+		if err := r.setValidationRuntimeClass(); err != nil {
+			return err
+		}
+		name := validationName + r.kataConfig.NameSuffix()
+		var isTestRunning bool
+		var err error
+		results, isTestRunning, err = runSmokeTestPods(r.Client, r.Scheme, r.Log, r.kataConfig, name, name, pending)
+		if err != nil {
+			return err
+		}
+		if isTestRunning {
+			r.Log.Info("Waiting for the validation of the nodes")
+			return nil
+		}
+		if err = deleteSmokeTestPods(r.Client, name); err != nil {
+			return err
+		}
+	}
+
+	/* keep the results of the selected nodes only */
+	var validations []kataconfigurationv2.NodeValidationStatus
+	for _, node := range nodes {
+		validation, ok := previous[node.Name]
+		if result, isNew := results[node.Name]; isNew {
+			validation = kataconfigurationv2.NodeValidationStatus{
+				Name:            node.Name,
+				Validated:       result.passed,
+				StartupLatency:  &metav1.Duration{Duration: result.startupLatency},
+				Message:         result.message,
+				Attempts:        validation.Attempts + 1,
+				LastAttemptTime: &metav1.Time{Time: now},
+			}
+			if !result.passed {
+				r.Log.Info("The validation of the node failed, retrying later", "node", node.Name,
+					"attempts", validation.Attempts, "message", result.message)
+			}
+			ok = true
+		}
+		if ok {
+			validations = append(validations, validation)
+		}
+	}
+	r.kataConfig.Status.NodeValidation = validations
+	return nil
+}
+
+// nodePoolConfigMessage tells how to roll out the configuration of the ConfigMap. The operator
+// creates it in the guest cluster, while the NodePools can only reference the ConfigMaps of
+// their namespace in the management cluster.
+func nodePoolConfigMessage(cmName string) string {
+	return fmt.Sprintf("Waiting for the NodePools to roll out the configuration: copy the ConfigMap %s/%s of this cluster "+
+		"to the namespace of the NodePools in the management cluster and add it to their spec.config", operatorNamespace, cmName)
+}
+
+func (r *KataConfigHostedReconciler) processKataConfigInstallRequest() (ctrl.Result, error) {
+	r.Log.Info("Kata installation in progress")
+	if err := assignPoolName(r.Client, r.Log, r.kataConfig); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if !contains(r.kataConfig.GetFinalizers(), kataConfigFinalizer) {
+		r.Log.Info("Adding Finalizer for the KataConfig")
+		controllerutil.AddFinalizer(r.kataConfig, kataConfigFinalizer)
+		if err := r.Client.Update(context.TODO(), r.kataConfig); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	r.kataConfig.Status.ClusterTopology = kataconfigurationv2.ClusterTopologyHostedControlPlane
+
+	cm, err := r.newNodePoolConfigMap()
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	isChanged, err := r.applyNodePoolConfig(cm)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	r.kataConfig.Status.NodePoolConfigMap = cm.Name
+
+	nodes, err := r.listSelectedNodes()
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	/* The rollout of a new configuration is tracked from the configuration the nodes run now */
+	if isChanged {
+		r.kataConfig.Status.InstallationStatus.NodePoolNodes = initialNodeConfigs(nodes)
+		r.kataConfig.Status.NodeValidation = nil
+		r.Recorder.Event(r.kataConfig, corev1.EventTypeNormal, "NodePoolConfigUpdated", nodePoolConfigMessage(cm.Name))
+	}
+
+	r.kataConfig.Status.TotalNodesCount = len(nodes)
+	rollout := nodePoolRolloutStatus(nodes, r.kataConfig.Status.InstallationStatus.NodePoolNodes)
+	if err = r.validateNodePoolNodes(nodes, rollout.Completed.CompletedNodesList); err != nil {
+		return ctrl.Result{}, err
+	}
+	r.kataConfig.Status.InstallationStatus.KataNodesStatus = nodePoolNodesStatus(rollout, r.kataConfig.Status.NodeValidation)
+
+	/* Only let pods using the kata runtime run on the nodes it is validated on */
+	for i := range nodes {
+		value := ""
+		if contains(r.kataConfig.Status.InstallationStatus.Completed.CompletedNodesList, nodes[i].Name) {
+			value = "true"
+		}
+		if err = r.setNodeLabel(&nodes[i], value); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if len(nodes) == 0 || r.kataConfig.Status.InstallationStatus.Completed.CompletedNodesCount == 0 {
+		r.Log.Info(nodePoolConfigMessage(cm.Name))
+		return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, nil
+	}
+
+	if err = r.setRuntimeClass(); err != nil {
+		return ctrl.Result{}, err
+	}
+	if r.kataConfig.Status.InstalledVersion == "" {
+		r.kataConfig.Status.InstalledVersion = OperatorVersion
+	}
+
+	if r.kataConfig.Status.InstallationStatus.IsInProgress {
+		r.Log.Info("Waiting for the NodePools to roll out the configuration on all the selected nodes")
+		return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+// processKataConfigDeleteRequest drives the uninstallation through the phases persisted in the
+// KataConfig status: once no pod uses the kata runtime the ConfigMap is deleted, then the
+// uninstallation waits for the NodePools to roll out a configuration without it
+func (r *KataConfigHostedReconciler) processKataConfigDeleteRequest() (ctrl.Result, error) {
+	r.Log.Info("KataConfig deletion in progress: ", "phase", r.kataConfig.Status.UnInstallationStatus.Phase)
+	if !contains(r.kataConfig.GetFinalizers(), kataConfigFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	status := &r.kataConfig.Status.UnInstallationStatus
+	nodes, err := r.listSelectedNodes()
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	switch status.Phase {
+	case "", kataconfigurationv2.UninstallPhaseBlockedByPods:
+		var runtimeClassNames []string
+		if r.kataConfig.Status.RuntimeClass != "" {
+			runtimeClassNames = append(runtimeClassNames, r.kataConfig.Status.RuntimeClass)
+		}
+		pods, err := listPodsUsingRuntimeClasses(r.Client, runtimeClassNames)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		recordBlockingPods(r.Recorder, r.kataConfig, pods)
+		if len(pods) > 0 {
+			message, err := r.removeKataPods(pods)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			status.ErrorMessage = message
+			status.Phase = kataconfigurationv2.UninstallPhaseBlockedByPods
+			r.Log.Info("Kata PODs are present. Requeue for reconciliation ")
+			return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, nil
+		}
+		status.ErrorMessage = ""
+
+		if err = r.deleteRuntimeClass(r.kataConfig.Status.RuntimeClass); err != nil {
+			return ctrl.Result{}, err
+		}
+		if err = deleteSmokeTestPods(r.Client, validationName+r.kataConfig.NameSuffix()); err != nil {
+			return ctrl.Result{}, err
+		}
+		if err = r.deleteRuntimeClass(validationName + r.kataConfig.NameSuffix()); err != nil {
+			return ctrl.Result{}, err
+		}
+		if err = r.deleteNodePoolConfig(); err != nil {
+			return ctrl.Result{}, err
+		}
+		r.kataConfig.Status.InstallationStatus.NodePoolNodes = initialNodeConfigs(nodes)
+		status.IsInProgress = true
+		status.Phase = kataconfigurationv2.UninstallPhasePoolRolling
+		return ctrl.Result{Requeue: true}, nil
+
+	case kataconfigurationv2.UninstallPhasePoolRolling:
+		nodesStatus := nodePoolRolloutStatus(nodes, r.kataConfig.Status.InstallationStatus.NodePoolNodes)
+		status.Completed = nodesStatus.Completed
+		status.InProgress = nodesStatus.InProgress
+		status.Failed = nodesStatus.Failed
+		if nodesStatus.IsInProgress {
+			status.ErrorMessage = fmt.Sprintf("Waiting for the NodePools to stop referencing ConfigMap %s",
+				r.kataConfig.Status.NodePoolConfigMap)
+			r.Log.Info("Waiting for the NodePools to roll out a configuration without the kata runtime")
+			return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, nil
+		}
+		status.ErrorMessage = ""
+		status.Phase = kataconfigurationv2.UninstallPhaseCleanup
+		return ctrl.Result{Requeue: true}, nil
+
+	case kataconfigurationv2.UninstallPhaseCleanup:
+		for i := range nodes {
+			if err = r.setNodeLabel(&nodes[i], ""); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		status.IsInProgress = false
+		r.kataConfig.Status.InstallationStatus = kataconfigurationv2.KataInstallationStatus{}
+		status.Phase = kataconfigurationv2.UninstallPhaseDone
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	r.Log.Info("Removing finalizer from the KataConfig")
+	controllerutil.RemoveFinalizer(r.kataConfig, kataConfigFinalizer)
+	if err := r.Client.Update(context.TODO(), r.kataConfig); err != nil {
+		r.Log.Error(err, "Unable to update KataConfig")
+		return ctrl.Result{}, err
+	}
+	r.Log.Info("Uninstallation completed. Proceeding with the KataConfig deletion")
+	return ctrl.Result{}, nil
+}
+
+func (r *KataConfigHostedReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	clientset, err := kubernetes.NewForConfig(mgr.GetConfig())
+	if err != nil {
+		return err
+	}
+	r.clientset = clientset
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&kataconfigurationv2.KataConfig{}).
+		Owns(&corev1.ConfigMap{}).
+		Complete(r)
+}
@@ -0,0 +1,184 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+
+	ignTypes "github.com/coreos/ignition/v2/config/v3_2/types"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	kataconfigurationv2 "github.com/openshift/sandboxed-containers-operator/api/v2"
+	corev1 "k8s.io/api/core/v1"
+	nodeapi "k8s.io/api/node/v1beta1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newNodePoolNode(name string, currentConfig string, desiredConfig string, state string) corev1.Node {
+	return corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Annotations: map[string]string{
+				currentConfigAnnotation: currentConfig,
+				desiredConfigAnnotation: desiredConfig,
+				stateAnnotation:         state,
+			},
+		},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+		},
+	}
+}
+
+var _ = Describe("Hosted control plane installation", func() {
+	It("Should track the rollout of the NodePool configuration on the nodes", func() {
+		initialConfigs := []kataconfigurationv2.NodePoolNodeStatus{
+			{Name: "worker0", InitialConfig: "config-1"},
+			{Name: "worker1", InitialConfig: "config-1"},
+			{Name: "worker2", InitialConfig: "config-1"},
+		}
+		nodes := []corev1.Node{
+			newNodePoolNode("worker0", "config-2", "config-2", "Done"),
+			newNodePoolNode("worker1", "config-1", "config-2", "Working"),
+			newNodePoolNode("worker2", "config-1", "config-2", "Degraded"),
+			newNodePoolNode("worker3", "", "", ""),
+		}
+		nodes[2].Annotations[reasonAnnotation] = "failed to apply"
+
+		rollout := nodePoolRolloutStatus(nodes, initialConfigs)
+		Expect(rollout.Completed.CompletedNodesList).Should(Equal([]string{"worker0", "worker3"}))
+		Expect(rollout.InProgress.InProgressNodesList).Should(Equal([]string{"worker1"}))
+		Expect(rollout.Failed.FailedReason).Should(Equal("worker2: failed to apply"))
+
+		By("Only completing the installation on the nodes validated to run the extension")
+		status := nodePoolNodesStatus(rollout, []kataconfigurationv2.NodeValidationStatus{
+			{Name: "worker0", Validated: true},
+			{Name: "worker3", Validated: false},
+		})
+		Expect(status.Completed.CompletedNodesList).Should(Equal([]string{"worker0"}))
+		Expect(status.InProgress.InProgressNodesList).Should(Equal([]string{"worker1", "worker3"}))
+		Expect(status.Failed.FailedReason).Should(Equal("worker2: failed to apply"))
+		Expect(status.IsInProgress).Should(BeTrue())
+	})
+
+	It("Should validate the nodes that rolled out the configuration", func() {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(kataconfigurationv2.AddToScheme(scheme)).To(Succeed())
+		kataConfig := &kataconfigurationv2.KataConfig{ObjectMeta: metav1.ObjectMeta{Name: "example-kataconfig", UID: "uid"}}
+		c := fake.NewClientBuilder().WithScheme(scheme).Build()
+		r := &KataConfigHostedReconciler{KataConfigKubernetesReconciler{
+			Client:     c,
+			Log:        ctrl.Log.WithName("test"),
+			Scheme:     scheme,
+			kataConfig: kataConfig,
+		}}
+		nodes := []corev1.Node{
+			newNodePoolNode("worker0", "config-2", "config-2", "Done"),
+			newNodePoolNode("worker1", "config-1", "config-2", "Working"),
+		}
+		nodes[0].Status.NodeInfo.KernelVersion = "4.18.0-305.el8.x86_64"
+
+		Expect(r.validateNodePoolNodes(nodes, []string{"worker0"})).To(Succeed())
+		Expect(kataConfig.Status.NodeValidation).Should(BeEmpty())
+		rc := &nodeapi.RuntimeClass{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: validationName}, rc)).To(Succeed())
+		Expect(rc.Scheduling).Should(BeNil())
+		pods := &corev1.PodList{}
+		Expect(c.List(context.TODO(), pods)).To(Succeed())
+		Expect(pods.Items).Should(HaveLen(1))
+		Expect(pods.Items[0].Spec.NodeName).Should(Equal("worker0"))
+		Expect(*pods.Items[0].Spec.RuntimeClassName).Should(Equal(validationName))
+
+		By("Recording the result once the validation pod ran in a virtual machine")
+		pod := &pods.Items[0]
+		pod.Status.Phase = corev1.PodSucceeded
+		pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+			State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Message: "5.10.0-kata\n"}},
+		}}
+		Expect(c.Status().Update(context.TODO(), pod)).To(Succeed())
+		Expect(r.validateNodePoolNodes(nodes, []string{"worker0"})).To(Succeed())
+		Expect(kataConfig.Status.NodeValidation).Should(HaveLen(1))
+		Expect(kataConfig.Status.NodeValidation[0].Name).Should(Equal("worker0"))
+		Expect(kataConfig.Status.NodeValidation[0].Validated).Should(BeTrue())
+		Expect(c.List(context.TODO(), pods)).To(Succeed())
+		Expect(pods.Items).Should(BeEmpty())
+	})
+
+	It("Should deliver the extension MachineConfig in a ConfigMap", func() {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(kataconfigurationv2.AddToScheme(scheme)).To(Succeed())
+		kataConfig := &kataconfigurationv2.KataConfig{ObjectMeta: metav1.ObjectMeta{Name: "example-kataconfig", UID: "uid"}}
+		c := fake.NewClientBuilder().WithScheme(scheme).Build()
+		r := &KataConfigHostedReconciler{KataConfigKubernetesReconciler{
+			Client:     c,
+			Log:        ctrl.Log.WithName("test"),
+			Scheme:     scheme,
+			kataConfig: kataConfig,
+		}}
+
+		cm, err := r.newNodePoolConfigMap()
+		Expect(err).ToNot(HaveOccurred())
+		isChanged, err := r.applyNodePoolConfig(cm)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(isChanged).Should(BeTrue())
+
+		foundCm := &corev1.ConfigMap{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: nodePoolConfigName, Namespace: operatorNamespace}, foundCm)).To(Succeed())
+		mc := &mcfgv1.MachineConfig{}
+		Expect(json.Unmarshal([]byte(foundCm.Data[nodePoolConfigKey]), mc)).To(Succeed())
+		Expect(mc.Spec.Extensions).Should(Equal([]string{"sandboxed-containers"}))
+		Expect(mc.Labels["machineconfiguration.openshift.io/role"]).Should(Equal("worker"))
+
+		By("Leaving an unchanged configuration as it is")
+		cm, err = r.newNodePoolConfigMap()
+		Expect(err).ToNot(HaveOccurred())
+		isChanged, err = r.applyNodePoolConfig(cm)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(isChanged).Should(BeFalse())
+	})
+
+	It("Should deliver the runtime handler of an alternative hypervisor with the extension", func() {
+		r := &KataConfigHostedReconciler{KataConfigKubernetesReconciler{
+			kataConfig: &kataconfigurationv2.KataConfig{
+				Spec: kataconfigurationv2.KataConfigSpec{Hypervisor: kataconfigurationv2.HypervisorCloudHypervisor},
+			},
+		}}
+
+		cm, err := r.newNodePoolConfigMap()
+		Expect(err).ToNot(HaveOccurred())
+		mc := &mcfgv1.MachineConfig{}
+		Expect(json.Unmarshal([]byte(cm.Data[nodePoolConfigKey]), mc)).To(Succeed())
+		ic := ignTypes.Config{}
+		Expect(json.Unmarshal(mc.Spec.Config.Raw, &ic)).To(Succeed())
+		Expect(ic.Storage.Files).Should(HaveLen(1))
+		Expect(ic.Storage.Files[0].Path).Should(Equal(crioDropInDir + "/50-kata-kata-clh"))
+	})
+
+	It("Should tell to reference the ConfigMap from the management cluster", func() {
+		kataConfig := &kataconfigurationv2.KataConfig{Status: kataconfigurationv2.KataConfigStatus{
+			ClusterTopology:   kataconfigurationv2.ClusterTopologyHostedControlPlane,
+			NodePoolConfigMap: nodePoolConfigName,
+			TotalNodesCount:   2,
+		}}
+		kataConfig.Status.InstallationStatus.IsInProgress = true
+		updateConditions(kataConfig)
+		ready := meta.FindStatusCondition(kataConfig.Status.Conditions, kataconfigurationv2.KataConfigReady)
+		Expect(ready.Reason).Should(Equal("WaitingForNodePools"))
+		Expect(ready.Message).Should(ContainSubstring("management cluster"))
+		Expect(ready.Message).Should(ContainSubstring(operatorNamespace + "/" + nodePoolConfigName))
+
+		By("Reporting the installation once a node rolled out the configuration")
+		kataConfig.Status.InstallationStatus.Completed.CompletedNodesCount = 1
+		updateConditions(kataConfig)
+		ready = meta.FindStatusCondition(kataConfig.Status.Conditions, kataconfigurationv2.KataConfigReady)
+		Expect(ready.Reason).Should(Equal("Installing"))
+	})
+})
@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	ignTypes "github.com/coreos/ignition/v2/config/v3_2/types"
+	kataconfigurationv2 "github.com/openshift/sandboxed-containers-operator/api/v2"
+)
+
+const (
+	// hypervisorConfigMcName is the MachineConfig holding the kata configuration drop-in
+	// rendered from the hypervisor settings of the KataConfig
+	hypervisorConfigMcName = "50-sandboxed-containers-hypervisor-config"
+
+	// kataDefaultsDir holds the kata configurations shipped by the sandboxed-containers extension
+	kataDefaultsDir = "/usr/share/kata-containers/defaults"
+
+	// kataConfigDir holds the copies of the kata configurations of the extension the runtime
+	// handlers point at when the operator renders drop-ins: kata only reads the config.d
+	// directory next to the configuration it loads, and kataDefaultsDir is read-only
+	kataConfigDir = "/etc/kata-containers/sandboxed-containers"
+
+	kataDropInDir = kataConfigDir + "/config.d"
+
+	// kataConfigUnitName copies the kata configurations of the extension to kataConfigDir on
+	// boot, so that they follow the updates of the extension
+	kataConfigUnitName = "sandboxed-containers-kata-config.service"
+)
+
+// kataConfigUnit is the systemd unit copying the kata configurations of the extension to
+// kataConfigDir before CRI-O starts
+var kataConfigUnit = `[Unit]
+Description=Copy the kata configurations of the sandboxed-containers extension
+Before=crio.service
+
+[Service]
+Type=oneshot
+RemainAfterExit=yes
+ExecStart=/usr/bin/mkdir -p ` + kataConfigDir + `
+ExecStart=/usr/bin/sh -c 'cp -f ` + kataDefaultsDir + `/configuration*.toml ` + kataConfigDir + `/'
+
+[Install]
+WantedBy=multi-user.target
+`
+
+// hypervisorSections are the hypervisor sections of the kata configuration of each hypervisor
+var hypervisorSections = map[kataconfigurationv2.Hypervisor]string{
+	kataconfigurationv2.HypervisorQEMU:            "qemu",
+	kataconfigurationv2.HypervisorCloudHypervisor: "clh",
+	kataconfigurationv2.HypervisorFirecracker:     "firecracker",
+}
+
+// hypervisorConfigPath returns the kata configuration of the hypervisor in the directory
+func hypervisorConfigPath(dir string, hypervisor kataconfigurationv2.Hypervisor) string {
+	return dir + "/configuration-" + hypervisor.ShortName() + ".toml"
+}
+
+// kataConfigPath returns the kata configuration of the hypervisor in the directory, the default
+// configuration for qemu
+func kataConfigPath(dir string, hypervisor kataconfigurationv2.Hypervisor) string {
+	if hypervisor == kataconfigurationv2.HypervisorQEMU {
+		return dir + "/configuration.toml"
+	}
+	return hypervisorConfigPath(dir, hypervisor)
+}
+
+// hasKataDropIns tells whether the operator renders kata configuration drop-ins, which the
+// runtime handlers only load from the copies of the kata configurations
+func (r *KataConfigOpenShiftReconciler) hasKataDropIns() bool {
+	return len(r.hypervisorConfigFiles()) > 0 || len(r.debugFiles()) > 0
+}
+
+// newKataConfigUnit returns the systemd unit copying the kata configurations of the extension
+func newKataConfigUnit() ignTypes.Unit {
+	enabled := true
+	contents := kataConfigUnit
+	return ignTypes.Unit{
+		Name:     kataConfigUnitName,
+		Enabled:  &enabled,
+		Contents: &contents,
+	}
+}
+
+// hypervisorSection returns the hypervisor section of the kata configuration selected by the
+// KataConfig
+func (r *KataConfigOpenShiftReconciler) hypervisorSection() string {
+	return hypervisorSections[r.kataConfig.Spec.GetHypervisor()]
+}
+
+// renderHypervisorConfig returns the kata configuration drop-in of the hypervisor settings,
+// or an empty string when there is nothing to override
+func renderHypervisorConfig(hypervisor string, config *kataconfigurationv2.HypervisorConfig) string {
+	if config == nil {
+		return ""
+	}
+
+	var sb strings.Builder
+	if config.DefaultVCPUs != nil {
+		fmt.Fprintf(&sb, "default_vcpus = %d\n", *config.DefaultVCPUs)
+	}
+	if config.DefaultMemory != nil {
+		fmt.Fprintf(&sb, "default_memory = %d\n", *config.DefaultMemory)
+	}
+	if config.DefaultMaxMemory != nil {
+		fmt.Fprintf(&sb, "default_maxmemory = %d\n", *config.DefaultMaxMemory)
+	}
+	if len(config.KernelParams) > 0 {
+		fmt.Fprintf(&sb, "kernel_params = %s\n", strconv.Quote(strings.Join(config.KernelParams, " ")))
+	}
+	if sb.Len() == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("[hypervisor.%s]\n", hypervisor) + sb.String()
+}
+
+// hypervisorConfigFiles returns the kata configuration drop-ins needed by the hypervisor
+// settings of the KataConfig
+func (r *KataConfigOpenShiftReconciler) hypervisorConfigFiles() []ignTypes.File {
+	dropIn := renderHypervisorConfig(r.hypervisorSection(), r.kataConfig.Spec.HypervisorConfig)
+	if dropIn == "" {
+		return nil
+	}
+	return []ignTypes.File{newIgnitionFile(kataDropInDir+"/50-sandboxed-containers-hypervisor.toml", dropIn)}
+}
+
+// reconcileHypervisorConfigMc renders the hypervisor settings of the KataConfig. It returns
+// true if the MachineConfig was changed and the pool has to roll out the change.
+func (r *KataConfigOpenShiftReconciler) reconcileHypervisorConfigMc(machinePool string) (bool, error) {
+	changed, err := r.applyMcWithFiles(r.mcName(hypervisorConfigMcName, machinePool), machinePool, r.hypervisorConfigFiles())
+	if err != nil {
+		r.Log.Error(err, "Failed to apply the hypervisor configuration MachineConfig")
+		return false, err
+	}
+	return changed, nil
+}
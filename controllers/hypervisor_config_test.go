@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"path/filepath"
+	"regexp"
+
+	ignTypes "github.com/coreos/ignition/v2/config/v3_2/types"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	kataconfigurationv2 "github.com/openshift/sandboxed-containers-operator/api/v2"
+	"github.com/vincent-petithory/dataurl"
+)
+
+// runtimeConfigPath returns the kata configuration a rendered CRI-O runtime handler loads
+func runtimeConfigPath(file ignTypes.File) string {
+	contents, err := dataurl.DecodeString(*file.Contents.Source)
+	Expect(err).ToNot(HaveOccurred())
+	match := regexp.MustCompile(`runtime_config_path = "(.*)"`).FindStringSubmatch(string(contents.Data))
+	Expect(match).Should(HaveLen(2))
+	return match[1]
+}
+
+var _ = Describe("Hypervisor configuration", func() {
+	Context("Rendering the kata configuration drop-in", func() {
+		It("Should render nothing without hypervisor settings", func() {
+			Expect(renderHypervisorConfig("qemu", nil)).Should(BeEmpty())
+			Expect(renderHypervisorConfig("qemu", &kataconfigurationv2.HypervisorConfig{})).Should(BeEmpty())
+		})
+
+		It("Should render the requested settings in the hypervisor section", func() {
+			vcpus, memory, maxMemory := int32(2), int32(4096), int32(8192)
+			Expect(renderHypervisorConfig("qemu", &kataconfigurationv2.HypervisorConfig{
+				DefaultVCPUs:     &vcpus,
+				DefaultMemory:    &memory,
+				DefaultMaxMemory: &maxMemory,
+				KernelParams:     []string{"agent.log=debug", "systemd.unified_cgroup_hierarchy=1"},
+			})).Should(Equal(
+				"[hypervisor.qemu]\n" +
+					"default_vcpus = 2\n" +
+					"default_memory = 4096\n" +
+					"default_maxmemory = 8192\n" +
+					"kernel_params = \"agent.log=debug systemd.unified_cgroup_hierarchy=1\"\n"))
+		})
+	})
+
+	Context("Selecting an alternative hypervisor", func() {
+		var r *KataConfigOpenShiftReconciler
+
+		BeforeEach(func() {
+			r = &KataConfigOpenShiftReconciler{kataConfig: &kataconfigurationv2.KataConfig{
+				Spec: kataconfigurationv2.KataConfigSpec{Hypervisor: kataconfigurationv2.HypervisorCloudHypervisor},
+			}}
+		})
+
+		It("Should name the RuntimeClass and the runtime handler after the hypervisor", func() {
+			Expect(r.kataConfig.GetRuntimeClassName()).Should(Equal("kata-clh"))
+			Expect(r.kataConfig.Spec.GetRuntimeClassHandler()).Should(Equal("kata-clh"))
+
+			r.kataConfig.Spec.Hypervisor = ""
+			Expect(r.kataConfig.GetRuntimeClassName()).Should(Equal("kata"))
+			Expect(r.runtimeHandlerFiles()).Should(BeEmpty())
+		})
+
+		It("Should point the runtime handler at the kata configuration of the hypervisor", func() {
+			files := r.runtimeHandlerFiles()
+			Expect(files).Should(HaveLen(1))
+			Expect(files[0].Path).Should(Equal(crioDropInDir + "/50-kata-kata-clh"))
+			contents, err := dataurl.DecodeString(*files[0].Contents.Source)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(contents.Data)).Should(ContainSubstring(
+				"runtime_config_path = \"/usr/share/kata-containers/defaults/configuration-clh.toml\""))
+		})
+
+		It("Should render the settings in the section of the hypervisor", func() {
+			vcpus := int32(2)
+			r.kataConfig.Spec.Hypervisor = kataconfigurationv2.HypervisorFirecracker
+			r.kataConfig.Spec.HypervisorConfig = &kataconfigurationv2.HypervisorConfig{DefaultVCPUs: &vcpus}
+			Expect(r.hypervisorConfigFiles()).Should(HaveLen(1))
+			Expect(r.hypervisorSection()).Should(Equal("firecracker"))
+			Expect(hypervisorConfigPath(kataDefaultsDir, r.kataConfig.Spec.GetHypervisor())).Should(
+				Equal("/usr/share/kata-containers/defaults/configuration-fc.toml"))
+		})
+	})
+
+	Context("Loading the kata configuration drop-ins", func() {
+		It("Should write the drop-ins next to the configuration the runtime handlers load", func() {
+			vcpus := int32(2)
+			r := &KataConfigOpenShiftReconciler{kataConfig: &kataconfigurationv2.KataConfig{
+				Spec: kataconfigurationv2.KataConfigSpec{
+					HypervisorConfig: &kataconfigurationv2.HypervisorConfig{DefaultVCPUs: &vcpus},
+					Debug:            true,
+					RuntimeClasses:   []kataconfigurationv2.RuntimeClassVariant{{Name: "kata-small"}},
+				},
+			}}
+
+			handlerFiles := r.runtimeHandlerFiles()
+			Expect(handlerFiles).Should(HaveLen(2))
+			Expect(handlerFiles[0].Path).Should(Equal(crioDropInDir + "/50-kata-kata"))
+			configPath := runtimeConfigPath(handlerFiles[0])
+			Expect(configPath).Should(Equal(kataConfigDir + "/configuration.toml"))
+			Expect(runtimeConfigPath(handlerFiles[1])).Should(Equal(configPath))
+
+			dropInDir := filepath.Join(filepath.Dir(configPath), "config.d")
+			Expect(filepath.Dir(r.hypervisorConfigFiles()[0].Path)).Should(Equal(dropInDir))
+			Expect(filepath.Dir(r.debugFiles()[0].Path)).Should(Equal(dropInDir))
+
+			units := r.runtimeHandlerUnits()
+			Expect(units).Should(HaveLen(1))
+			Expect(*units[0].Contents).Should(ContainSubstring(
+				"cp -f /usr/share/kata-containers/defaults/configuration*.toml " + filepath.Dir(configPath) + "/"))
+		})
+
+		It("Should leave the handler of the extension alone without drop-ins", func() {
+			r := &KataConfigOpenShiftReconciler{kataConfig: &kataconfigurationv2.KataConfig{}}
+			Expect(r.runtimeHandlerFiles()).Should(BeEmpty())
+			Expect(r.runtimeHandlerUnits()).Should(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	kataconfigurationv2 "github.com/openshift/sandboxed-containers-operator/api/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// assignPoolName names the custom MachineConfigPool of the KataConfig. The only KataConfig of
+// the cluster gets the kata-oc name, the ones created next to others get a name suffixed with
+// theirs, so that the resources created for each KataConfig do not conflict.
+func assignPoolName(c client.Client, log logr.Logger, kataConfig *kataconfigurationv2.KataConfig) error {
+	if kataConfig.Status.PoolName != "" {
+		return nil
+	}
+
+	/* An installation started before the KataConfigs were named keeps the kata-oc pool */
+	if kataConfig.Status.RuntimeClass != "" || kataConfig.Status.InstallationStatus.IsInProgress {
+		kataConfig.Status.PoolName = kataconfigurationv2.DefaultPoolName
+		return c.Status().Update(context.TODO(), kataConfig)
+	}
+
+	kataConfigList := &kataconfigurationv2.KataConfigList{}
+	if err := c.List(context.TODO(), kataConfigList); err != nil {
+		return err
+	}
+
+	poolName := kataconfigurationv2.DefaultPoolName
+	for _, other := range kataConfigList.Items {
+		if other.Name != kataConfig.Name {
+			poolName = kataconfigurationv2.DefaultPoolName + "-" + kataConfig.Name
+			break
+		}
+	}
+	log.Info("Naming the custom MachineConfigPool", "mcp.Name", poolName)
+	kataConfig.Status.PoolName = poolName
+	return c.Status().Update(context.TODO(), kataConfig)
+}
+
+// poolName returns the name of the custom MachineConfigPool of the KataConfig
+func (r *KataConfigOpenShiftReconciler) poolName() string {
+	return kataconfigurationv2.DefaultPoolName + r.kataConfig.NameSuffix()
+}
+
+// instanceName returns the name of a resource created for the KataConfig, suffixed like
+// the name of its MachineConfigPool
+func (r *KataConfigOpenShiftReconciler) instanceName(name string) string {
+	return name + r.kataConfig.NameSuffix()
+}
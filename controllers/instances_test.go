@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	kataconfigurationv2 "github.com/openshift/sandboxed-containers-operator/api/v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Multiple KataConfigs", func() {
+	var r *KataConfigOpenShiftReconciler
+
+	BeforeEach(func() {
+		r = &KataConfigOpenShiftReconciler{
+			kataConfig: &kataconfigurationv2.KataConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: "batch"},
+				Spec: kataconfigurationv2.KataConfigSpec{
+					KataConfigPoolSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{"kata-batch": "true"},
+					},
+				},
+			},
+		}
+	})
+
+	It("Should keep the names of the first KataConfig", func() {
+		r.kataConfig.Status.PoolName = "kata-oc"
+		Expect(r.poolName()).Should(Equal("kata-oc"))
+		Expect(r.kataMcNames()).Should(ContainElement(extensionMcName))
+		Expect(r.kataConfig.GetRuntimeClassName()).Should(Equal("kata"))
+	})
+
+	It("Should derive the names of the other KataConfigs from their pool name", func() {
+		r.kataConfig.Status.PoolName = "kata-oc-batch"
+		Expect(r.poolName()).Should(Equal("kata-oc-batch"))
+		Expect(r.instanceName(preflightName)).Should(Equal("sandboxed-containers-preflight-batch"))
+		Expect(r.kataMcNames()).Should(ContainElement("50-enable-sandboxed-containers-extension-batch"))
+		Expect(r.kataConfig.GetRuntimeClassName()).Should(Equal("kata-batch"))
+
+		mcp := r.newMCPforCR()
+		Expect(mcp.Name).Should(Equal("kata-oc-batch"))
+		Expect(mcp.Spec.MachineConfigSelector.MatchExpressions[0].Values).Should(ConsistOf("kata-oc-batch", "worker"))
+	})
+
+	It("Should use the RuntimeClass name of the spec", func() {
+		r.kataConfig.Status.PoolName = "kata-oc-batch"
+		r.kataConfig.Spec.RuntimeClassName = "kata-batch-jobs"
+		Expect(r.kataConfig.GetRuntimeClassName()).Should(Equal("kata-batch-jobs"))
+	})
+})
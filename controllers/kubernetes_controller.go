@@ -0,0 +1,687 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/go-logr/logr"
+	kataconfigurationv2 "github.com/openshift/sandboxed-containers-operator/api/v2"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	nodeapi "k8s.io/api/node/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	installerName   = "sandboxed-containers-installer"
+	uninstallerName = "sandboxed-containers-uninstaller"
+
+	// installerServiceAccount runs the kata-deploy pods. It is only allowed to read and label the
+	// nodes, see config/rbac/installer_role.yaml.
+	installerServiceAccount = "sandboxed-containers-installer"
+
+	// installerImageEnv overrides the kata-deploy image installing the kata runtime on Kubernetes.
+	// The deployment of the operator sets it to an image pinned by digest.
+	installerImageEnv     = "KATA_INSTALLER_IMAGE"
+	defaultInstallerImage = "quay.io/kata-containers/kata-deploy:stable"
+
+	// kataDeployLabel is set by kata-deploy on the nodes, to true once the kata runtime is installed
+	kataDeployLabel = "katacontainers.io/kata-runtime"
+
+	// kataRuntimeNodeLabel is set by the operator on the nodes the kata runtime is installed on,
+	// the RuntimeClass only schedules pods on these nodes
+	kataRuntimeNodeLabel = "kataconfiguration.openshift.io/kata-runtime"
+
+	// installerGracePeriod leaves time to the installer pods to remove the kata runtime on deletion
+	installerGracePeriod = int64(300)
+)
+
+// KataDeployHypervisors are the hypervisors kata-deploy ships a kata configuration for
+var KataDeployHypervisors = []kataconfigurationv2.Hypervisor{
+	kataconfigurationv2.HypervisorQEMU,
+	kataconfigurationv2.HypervisorCloudHypervisor,
+	kataconfigurationv2.HypervisorFirecracker,
+}
+
+// KataConfigKubernetesReconciler reconciles a KataConfig object on Kubernetes clusters, where the
+// kata runtime is installed by a kata-deploy DaemonSet configuring containerd or CRI-O
+type KataConfigKubernetesReconciler struct {
+	client.Client
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+
+	clientset  kubernetes.Interface
+	kataConfig *kataconfigurationv2.KataConfig
+}
+
+func (r *KataConfigKubernetesReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	_ = r.Log.WithValues("kataconfig", req.NamespacedName)
+	r.Log.Info("Reconciling KataConfig in Kubernetes Cluster")
+
+	// Fetch the KataConfig instance
+	r.kataConfig = &kataconfigurationv2.KataConfig{}
+	err := r.Client.Get(context.TODO(), req.NamespacedName, r.kataConfig)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		r.Log.Error(err, "Cannot retrieve kataConfig")
+		return ctrl.Result{}, err
+	}
+
+	if r.kataConfig.GetDeletionTimestamp() != nil {
+		res, err := r.processKataConfigDeleteRequest()
+		updateConditions(r.kataConfig)
+		updateErr := r.Client.Status().Update(context.TODO(), r.kataConfig)
+		// the KataConfig is gone once the uninstallation removed the finalizer
+		if updateErr != nil && !k8serrors.IsNotFound(updateErr) {
+			return ctrl.Result{}, updateErr
+		}
+		return res, err
+	}
+
+	res, err := r.processKataConfigInstallRequest()
+	updateConditions(r.kataConfig)
+	if updateErr := r.Client.Status().Update(context.TODO(), r.kataConfig); updateErr != nil {
+		return ctrl.Result{}, updateErr
+	}
+	return res, err
+}
+
+// installerScript runs kata-deploy with the given action on the nodes running CRI-O. On the
+// nodes running containerd it only installs the kata artifacts, the operator configures
+// containerd itself to coordinate its restarts.
+const installerScript = `runtime=$(kubectl get node "$NODE_NAME" -o jsonpath='{.status.nodeInfo.containerRuntimeVersion}')
+case "$runtime" in
+containerd://*)
+  case "$1" in
+  install)
+    mkdir -p /opt/kata && cp -a /opt/kata-artifacts/opt/kata/. /opt/kata/ &&
+      kubectl label node "$NODE_NAME" --overwrite katacontainers.io/kata-runtime=true && sleep infinity ;;
+  cleanup)
+    rm -rf /opt/kata/* && kubectl label node "$NODE_NAME" --overwrite katacontainers.io/kata-runtime=cleanup ;;
+  reset)
+    kubectl label node "$NODE_NAME" katacontainers.io/kata-runtime- && sleep infinity ;;
+  esac ;;
+*)
+  exec /opt/kata-artifacts/scripts/kata-deploy.sh "$1" ;;
+esac
+`
+
+// kubernetesPoolSelector returns the kataConfigPoolSelector, or the selector of the nodes without
+// the control plane role used when it is not specified
+func kubernetesPoolSelector(kataConfig *kataconfigurationv2.KataConfig) *metav1.LabelSelector {
+	if kataConfig.Spec.KataConfigPoolSelector != nil {
+		return kataConfig.Spec.KataConfigPoolSelector
+	}
+	return &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: "node-role.kubernetes.io/control-plane", Operator: metav1.LabelSelectorOpDoesNotExist},
+			{Key: "node-role.kubernetes.io/master", Operator: metav1.LabelSelectorOpDoesNotExist},
+		},
+	}
+}
+
+// installerImage returns the kata-deploy image installing the kata runtime
+func installerImage() string {
+	if image := os.Getenv(installerImageEnv); image != "" {
+		return image
+	}
+	return defaultInstallerImage
+}
+
+// newInstallerDaemonSet returns a kata-deploy DaemonSet running the given action on the selected
+// nodes. The install action removes the kata runtime when its pods are stopped.
+func (r *KataConfigKubernetesReconciler) newInstallerDaemonSet(name string, action string) *appsv1.DaemonSet {
+	labels := map[string]string{"app": name}
+	privileged := true
+	gracePeriod := installerGracePeriod
+	hostPathDirectoryOrCreate := corev1.HostPathDirectoryOrCreate
+
+	container := corev1.Container{
+		Name:    "kata-deploy",
+		Image:   installerImage(),
+		Command: []string{"bash", "-c", installerScript, "installer", action},
+		Env: []corev1.EnvVar{{
+			Name: "NODE_NAME",
+			ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{FieldPath: "spec.nodeName"},
+			},
+		}},
+		SecurityContext: &corev1.SecurityContext{Privileged: &privileged},
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: "crio-conf", MountPath: "/etc/crio/"},
+			{Name: "containerd-conf", MountPath: "/etc/containerd/"},
+			{Name: "kata-artifacts", MountPath: "/opt/kata/"},
+			{Name: "dbus", MountPath: "/var/run/dbus/system_bus_socket"},
+			{Name: "systemd", MountPath: "/run/systemd/system"},
+			{Name: "local-bin", MountPath: "/usr/local/bin/"},
+		},
+	}
+	if action == "install" {
+		container.Lifecycle = &corev1.Lifecycle{
+			PreStop: &corev1.Handler{
+				Exec: &corev1.ExecAction{Command: []string{"bash", "-c", installerScript, "installer", "cleanup"}},
+			},
+		}
+	}
+
+	hostPath := func(name string, path string) corev1.Volume {
+		volume := corev1.Volume{Name: name, VolumeSource: corev1.VolumeSource{
+			HostPath: &corev1.HostPathVolumeSource{Path: path}}}
+		if name == "kata-artifacts" {
+			volume.HostPath.Type = &hostPathDirectoryOrCreate
+		}
+		return volume
+	}
+
+	return &appsv1.DaemonSet{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "apps/v1",
+			Kind:       "DaemonSet",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: operatorNamespace,
+			Labels:    labels,
+		},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					Affinity: &corev1.Affinity{
+						NodeAffinity: &corev1.NodeAffinity{
+							RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+								NodeSelectorTerms: []corev1.NodeSelectorTerm{
+									selectorAsNodeSelectorTerm(kubernetesPoolSelector(r.kataConfig)),
+								},
+							},
+						},
+					},
+					ServiceAccountName:            installerServiceAccount,
+					Tolerations:                   []corev1.Toleration{{Operator: corev1.TolerationOpExists}},
+					TerminationGracePeriodSeconds: &gracePeriod,
+					Containers:                    []corev1.Container{container},
+					Volumes: []corev1.Volume{
+						hostPath("crio-conf", "/etc/crio/"),
+						hostPath("containerd-conf", "/etc/containerd/"),
+						hostPath("kata-artifacts", "/opt/kata/"),
+						hostPath("dbus", "/var/run/dbus/system_bus_socket"),
+						hostPath("systemd", "/run/systemd/system"),
+						hostPath("local-bin", "/usr/local/bin/"),
+					},
+				},
+			},
+		},
+	}
+}
+
+// ensureDaemonSet creates the DaemonSet if it does not exist yet
+func (r *KataConfigKubernetesReconciler) ensureDaemonSet(ds *appsv1.DaemonSet, isOwned bool) error {
+	foundDs := &appsv1.DaemonSet{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: ds.Name, Namespace: ds.Namespace}, foundDs)
+	if err == nil || !k8serrors.IsNotFound(err) {
+		return err
+	}
+
+	if isOwned {
+		if err = controllerutil.SetControllerReference(r.kataConfig, ds, r.Scheme); err != nil {
+			return err
+		}
+	}
+	r.Log.Info("Creating DaemonSet", "ds.Name", ds.Name)
+	return r.Client.Create(context.TODO(), ds)
+}
+
+// deleteDaemonSet removes the named DaemonSet if it exists
+func (r *KataConfigKubernetesReconciler) deleteDaemonSet(name string) error {
+	ds := &appsv1.DaemonSet{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: operatorNamespace}, ds)
+	if err != nil && k8serrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	r.Log.Info("Deleting DaemonSet", "ds.Name", name)
+	err = r.Client.Delete(context.TODO(), ds)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// listSelectedNodes returns the nodes selected by the KataConfig
+func (r *KataConfigKubernetesReconciler) listSelectedNodes() ([]corev1.Node, error) {
+	selector, err := metav1.LabelSelectorAsSelector(kubernetesPoolSelector(r.kataConfig))
+	if err != nil {
+		return nil, err
+	}
+	nodes := &corev1.NodeList{}
+	if err = r.Client.List(context.TODO(), nodes, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, err
+	}
+	return nodes.Items, nil
+}
+
+// listPodsByNode returns the pods with the given app label by node name
+func (r *KataConfigKubernetesReconciler) listPodsByNode(name string) (map[string]corev1.Pod, error) {
+	pods := &corev1.PodList{}
+	if err := r.Client.List(context.TODO(), pods, client.InNamespace(operatorNamespace),
+		client.MatchingLabels{"app": name}); err != nil {
+		return nil, err
+	}
+
+	podsByNode := map[string]corev1.Pod{}
+	for _, pod := range pods.Items {
+		podsByNode[pod.Spec.NodeName] = pod
+	}
+	return podsByNode, nil
+}
+
+// installerFailure returns why the installer pod cannot complete, or an empty string
+func installerFailure(pod *corev1.Pod) string {
+	if pod.Status.Phase == corev1.PodFailed {
+		return fmt.Sprintf("The installer pod failed: %s %s", pod.Status.Reason, pod.Status.Message)
+	}
+	for _, containerStatus := range pod.Status.ContainerStatuses {
+		if waiting := containerStatus.State.Waiting; waiting != nil {
+			switch waiting.Reason {
+			case "CrashLoopBackOff", "ErrImagePull", "ImagePullBackOff", "CreateContainerConfigError":
+				return fmt.Sprintf("The installer pod is in %s: %s", waiting.Reason, waiting.Message)
+			}
+		}
+	}
+	return ""
+}
+
+// installerNodesStatus returns the progress of the installation on the selected nodes. A node
+// completed the installation once kata-deploy labelled it and, when it runs containerd, once
+// containerd is configured.
+func installerNodesStatus(nodes []corev1.Node, pods map[string]corev1.Pod,
+	containerdNodes []kataconfigurationv2.ContainerdNodeStatus) kataconfigurationv2.KataNodesStatus {
+	containerdStatuses := map[string]kataconfigurationv2.ContainerdNodeStatus{}
+	for _, containerdNode := range containerdNodes {
+		containerdStatuses[containerdNode.Name] = containerdNode
+	}
+
+	status := kataconfigurationv2.KataNodesStatus{}
+	for _, node := range nodes {
+		if node.Labels[kataDeployLabel] == "true" {
+			containerdStatus, ok := containerdStatuses[node.Name]
+			switch {
+			case !ok && isContainerdNode(&node):
+				status.InProgress.InProgressNodesList = append(status.InProgress.InProgressNodesList, node.Name)
+			case !ok || containerdStatus.Phase == kataconfigurationv2.ContainerdNodeConfigured:
+				status.Completed.CompletedNodesList = append(status.Completed.CompletedNodesList, node.Name)
+			case containerdStatus.Phase == kataconfigurationv2.ContainerdNodeFailed:
+				status.Failed.FailedNodesList = append(status.Failed.FailedNodesList,
+					kataconfigurationv2.FailedNodeStatus{Name: node.Name, Error: containerdStatus.Message})
+			default:
+				status.InProgress.InProgressNodesList = append(status.InProgress.InProgressNodesList, node.Name)
+			}
+			continue
+		}
+		if pod, ok := pods[node.Name]; ok {
+			if failure := installerFailure(&pod); failure != "" {
+				status.Failed.FailedNodesList = append(status.Failed.FailedNodesList,
+					kataconfigurationv2.FailedNodeStatus{Name: node.Name, Error: failure})
+				continue
+			}
+		}
+		status.InProgress.InProgressNodesList = append(status.InProgress.InProgressNodesList, node.Name)
+	}
+
+	status.Completed.CompletedNodesCount = len(status.Completed.CompletedNodesList)
+	status.InProgress.InProgressNodesCount = len(status.InProgress.InProgressNodesList)
+	status.Failed.FailedNodesCount = len(status.Failed.FailedNodesList)
+	if status.Failed.FailedNodesCount > 0 {
+		status.Failed.FailedReason = fmt.Sprintf("%s: %s", status.Failed.FailedNodesList[0].Name,
+			status.Failed.FailedNodesList[0].Error)
+	}
+	status.IsInProgress = status.InProgress.InProgressNodesCount > 0 || status.Failed.FailedNodesCount > 0
+	return status
+}
+
+// setNodeLabel sets or, with an empty value, removes the kata runtime label of the operator on a node
+func (r *KataConfigKubernetesReconciler) setNodeLabel(node *corev1.Node, value string) error {
+	current, ok := node.Labels[kataRuntimeNodeLabel]
+	if (value == "" && !ok) || (value != "" && current == value) {
+		return nil
+	}
+
+	patch := client.MergeFrom(node.DeepCopy())
+	if value == "" {
+		delete(node.Labels, kataRuntimeNodeLabel)
+	} else {
+		if node.Labels == nil {
+			node.Labels = map[string]string{}
+		}
+		node.Labels[kataRuntimeNodeLabel] = value
+	}
+	r.Log.Info("Updating the kata runtime label", "node", node.Name, "value", value)
+	return r.Client.Patch(context.TODO(), node, patch)
+}
+
+func (r *KataConfigKubernetesReconciler) processKataConfigInstallRequest() (ctrl.Result, error) {
+	r.Log.Info("Kata installation in progress")
+	if err := assignPoolName(r.Client, r.Log, r.kataConfig); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if !contains(r.kataConfig.GetFinalizers(), kataConfigFinalizer) {
+		r.Log.Info("Adding Finalizer for the KataConfig")
+		controllerutil.AddFinalizer(r.kataConfig, kataConfigFinalizer)
+		if err := r.Client.Update(context.TODO(), r.kataConfig); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	installer := r.kataConfig.NameSuffix()
+	if err := r.ensureDaemonSet(r.newInstallerDaemonSet(installerName+installer, "install"), true); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	nodes, err := r.listSelectedNodes()
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	pods, err := r.listPodsByNode(installerName + installer)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	/* containerd is configured on the nodes once the kata artifacts are installed */
+	var installedNodes []corev1.Node
+	for _, node := range nodes {
+		if node.Labels[kataDeployLabel] == "true" {
+			installedNodes = append(installedNodes, node)
+		}
+	}
+	containerdNodes, err := r.configureContainerd(installedNodes, r.kataConfig.Spec.GetRuntimeClassHandler())
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	r.kataConfig.Status.TotalNodesCount = len(nodes)
+	r.kataConfig.Status.InstallationStatus.KataNodesStatus = installerNodesStatus(nodes, pods, containerdNodes)
+	r.kataConfig.Status.InstallationStatus.ContainerdNodes = containerdNodes
+
+	/* Only let pods using the kata runtime run on the nodes it is installed on */
+	for i := range nodes {
+		value := ""
+		if contains(r.kataConfig.Status.InstallationStatus.Completed.CompletedNodesList, nodes[i].Name) {
+			value = "true"
+		}
+		if err = r.setNodeLabel(&nodes[i], value); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if len(nodes) == 0 || r.kataConfig.Status.InstallationStatus.Completed.CompletedNodesCount == 0 {
+		r.Log.Info("Waiting for the kata runtime to be installed on the selected nodes")
+		return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, nil
+	}
+
+	if err = r.setRuntimeClass(); err != nil {
+		return ctrl.Result{}, err
+	}
+	if r.kataConfig.Status.InstalledVersion == "" {
+		r.kataConfig.Status.InstalledVersion = OperatorVersion
+	}
+
+	if r.kataConfig.Status.InstallationStatus.IsInProgress {
+		r.Log.Info("Waiting for the kata runtime to be installed on all the selected nodes")
+		return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+// setRuntimeClass creates or updates the RuntimeClass scheduling pods on the nodes the kata
+// runtime is installed on
+func (r *KataConfigKubernetesReconciler) setRuntimeClass() error {
+	rc := &nodeapi.RuntimeClass{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "node.k8s.io/v1beta1",
+			Kind:       "RuntimeClass",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: r.kataConfig.GetRuntimeClassName(),
+		},
+		Handler: r.kataConfig.Spec.GetRuntimeClassHandler(),
+		Overhead: &nodeapi.Overhead{
+			PodFixed: r.kataConfig.Spec.GetRuntimeClassOverhead(),
+		},
+		Scheduling: &nodeapi.Scheduling{
+			NodeSelector: map[string]string{kataRuntimeNodeLabel: "true"},
+		},
+	}
+	if err := controllerutil.SetControllerReference(r.kataConfig, rc, r.Scheme); err != nil {
+		return err
+	}
+
+	foundRc := &nodeapi.RuntimeClass{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: rc.Name}, foundRc)
+	if err != nil && k8serrors.IsNotFound(err) {
+		r.Log.Info("Creating a new RuntimeClass", "rc.Name", rc.Name)
+		if err = r.Client.Create(context.TODO(), rc); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	} else if metav1.IsControlledBy(foundRc, r.kataConfig) &&
+		(!equality.Semantic.DeepEqual(foundRc.Overhead, rc.Overhead) ||
+			!equality.Semantic.DeepEqual(foundRc.Scheduling, rc.Scheduling)) {
+		r.Log.Info("Updating RuntimeClass", "rc.Name", rc.Name)
+		foundRc.Overhead = rc.Overhead
+		foundRc.Scheduling = rc.Scheduling
+		if err = r.Client.Update(context.TODO(), foundRc); err != nil {
+			return err
+		}
+	}
+
+	r.kataConfig.Status.RuntimeClass = rc.Name
+	return nil
+}
+
+// processKataConfigDeleteRequest drives the uninstallation through the phases persisted in the
+// KataConfig status: once no pod uses the kata runtime it is removed from the containerd
+// configuration, then the installer DaemonSet is deleted, which removes the kata runtime from
+// the nodes, and an uninstaller DaemonSet restarts their runtime
+func (r *KataConfigKubernetesReconciler) processKataConfigDeleteRequest() (ctrl.Result, error) {
+	r.Log.Info("KataConfig deletion in progress: ", "phase", r.kataConfig.Status.UnInstallationStatus.Phase)
+	if !contains(r.kataConfig.GetFinalizers(), kataConfigFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	status := &r.kataConfig.Status.UnInstallationStatus
+	uninstaller := uninstallerName + r.kataConfig.NameSuffix()
+
+	switch status.Phase {
+	case "", kataconfigurationv2.UninstallPhaseBlockedByPods:
+		var runtimeClassNames []string
+		if r.kataConfig.Status.RuntimeClass != "" {
+			runtimeClassNames = append(runtimeClassNames, r.kataConfig.Status.RuntimeClass)
+		}
+		pods, err := listPodsUsingRuntimeClasses(r.Client, runtimeClassNames)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		recordBlockingPods(r.Recorder, r.kataConfig, pods)
+		if len(pods) > 0 {
+			message, err := r.removeKataPods(pods)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			status.ErrorMessage = message
+			status.Phase = kataconfigurationv2.UninstallPhaseBlockedByPods
+			r.Log.Info("Kata PODs are present. Requeue for reconciliation ")
+			return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, nil
+		}
+		status.ErrorMessage = ""
+
+		if err = r.deleteRuntimeClass(r.kataConfig.Status.RuntimeClass); err != nil {
+			return ctrl.Result{}, err
+		}
+		status.IsInProgress = true
+		status.Phase = kataconfigurationv2.UninstallPhasePoolRolling
+		return ctrl.Result{Requeue: true}, nil
+
+	case kataconfigurationv2.UninstallPhasePoolRolling:
+		/* containerd stops using the kata runtime before its artifacts are removed */
+		nodes, err := r.listSelectedNodes()
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		containerdNodes, err := r.configureContainerd(nodes, "")
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		r.kataConfig.Status.InstallationStatus.ContainerdNodes = containerdNodes
+		status.InProgress = kataconfigurationv2.KataInProgressNodesStatus{}
+		for _, containerdNode := range containerdNodes {
+			if containerdNode.Phase != kataconfigurationv2.ContainerdNodeConfigured {
+				status.InProgress.InProgressNodesList = append(status.InProgress.InProgressNodesList, containerdNode.Name)
+			}
+		}
+		status.InProgress.InProgressNodesCount = len(status.InProgress.InProgressNodesList)
+		if status.InProgress.InProgressNodesCount > 0 {
+			r.Log.Info("Waiting for the nodes to restart containerd without the kata runtime")
+			return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, nil
+		}
+
+		/* The installer pods remove the kata runtime before they stop */
+		if err = r.deleteDaemonSet(installerName + r.kataConfig.NameSuffix()); err != nil {
+			return ctrl.Result{}, err
+		}
+		installerPods, err := r.listPodsByNode(installerName + r.kataConfig.NameSuffix())
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if len(installerPods) > 0 {
+			r.Log.Info("Waiting for the installer pods to remove the kata runtime")
+			return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, nil
+		}
+
+		/* kata-deploy removes its label once the runtime of the node is restarted without kata */
+		if err = r.ensureDaemonSet(r.newInstallerDaemonSet(uninstaller, "reset"), false); err != nil {
+			return ctrl.Result{}, err
+		}
+		status.Completed = kataconfigurationv2.KataConfigCompletedStatus{}
+		status.InProgress = kataconfigurationv2.KataInProgressNodesStatus{}
+		for _, node := range nodes {
+			if _, ok := node.Labels[kataDeployLabel]; ok {
+				status.InProgress.InProgressNodesList = append(status.InProgress.InProgressNodesList, node.Name)
+			} else {
+				status.Completed.CompletedNodesList = append(status.Completed.CompletedNodesList, node.Name)
+			}
+		}
+		status.Completed.CompletedNodesCount = len(status.Completed.CompletedNodesList)
+		status.InProgress.InProgressNodesCount = len(status.InProgress.InProgressNodesList)
+		if status.InProgress.InProgressNodesCount > 0 {
+			r.Log.Info("Waiting for the nodes to restart their runtime without the kata runtime")
+			return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, nil
+		}
+		status.Phase = kataconfigurationv2.UninstallPhaseCleanup
+		return ctrl.Result{Requeue: true}, nil
+
+	case kataconfigurationv2.UninstallPhaseCleanup:
+		if err := r.deleteDaemonSet(uninstaller); err != nil {
+			return ctrl.Result{}, err
+		}
+		nodes, err := r.listSelectedNodes()
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		for i := range nodes {
+			if err = r.setNodeLabel(&nodes[i], ""); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		status.IsInProgress = false
+		r.kataConfig.Status.InstallationStatus = kataconfigurationv2.KataInstallationStatus{}
+		status.Phase = kataconfigurationv2.UninstallPhaseDone
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	r.Log.Info("Removing finalizer from the KataConfig")
+	controllerutil.RemoveFinalizer(r.kataConfig, kataConfigFinalizer)
+	if err := r.Client.Update(context.TODO(), r.kataConfig); err != nil {
+		r.Log.Error(err, "Unable to update KataConfig")
+		return ctrl.Result{}, err
+	}
+	r.Log.Info("Uninstallation completed. Proceeding with the KataConfig deletion")
+	return ctrl.Result{}, nil
+}
+
+// deleteRuntimeClass removes the named RuntimeClass if it is owned by the KataConfig
+func (r *KataConfigKubernetesReconciler) deleteRuntimeClass(runtimeClassName string) error {
+	if runtimeClassName == "" {
+		return nil
+	}
+	rc := &nodeapi.RuntimeClass{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: runtimeClassName}, rc)
+	if err != nil && k8serrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !metav1.IsControlledBy(rc, r.kataConfig) {
+		return nil
+	}
+
+	r.Log.Info("Deleting RuntimeClass", "rc.Name", runtimeClassName)
+	err = r.Client.Delete(context.TODO(), rc)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// removeKataPods applies the uninstall policy to the pods still using the kata runtime and
+// returns the message reported in the uninstallation status while they are present
+func (r *KataConfigKubernetesReconciler) removeKataPods(pods []corev1.Pod) (string, error) {
+	return applyUninstallPolicy(r.Client, r.clientset, r.Log, r.kataConfig, pods)
+}
+
+func (r *KataConfigKubernetesReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	clientset, err := kubernetes.NewForConfig(mgr.GetConfig())
+	if err != nil {
+		return err
+	}
+	r.clientset = clientset
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&kataconfigurationv2.KataConfig{}).
+		Owns(&appsv1.DaemonSet{}).
+		Complete(r)
+}
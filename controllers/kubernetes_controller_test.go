@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	kataconfigurationv2 "github.com/openshift/sandboxed-containers-operator/api/v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+var _ = Describe("Kubernetes installation", func() {
+	It("Should select the nodes without the control plane role by default", func() {
+		selector, err := metav1.LabelSelectorAsSelector(kubernetesPoolSelector(&kataconfigurationv2.KataConfig{}))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(selector.Matches(labels.Set{"node-role.kubernetes.io/worker": ""})).Should(BeTrue())
+		Expect(selector.Matches(labels.Set{"node-role.kubernetes.io/control-plane": ""})).Should(BeFalse())
+		Expect(selector.Matches(labels.Set{"node-role.kubernetes.io/master": ""})).Should(BeFalse())
+	})
+
+	It("Should use the kataConfigPoolSelector when specified", func() {
+		poolSelector := &metav1.LabelSelector{MatchLabels: map[string]string{"kata": "true"}}
+		kataConfig := &kataconfigurationv2.KataConfig{
+			Spec: kataconfigurationv2.KataConfigSpec{KataConfigPoolSelector: poolSelector},
+		}
+		Expect(kubernetesPoolSelector(kataConfig)).Should(Equal(poolSelector))
+	})
+
+	It("Should run the installer with a service account only allowed to label the nodes", func() {
+		r := &KataConfigKubernetesReconciler{kataConfig: &kataconfigurationv2.KataConfig{}}
+		ds := r.newInstallerDaemonSet(installerName, "install")
+		Expect(ds.Spec.Template.Spec.ServiceAccountName).Should(Equal(installerServiceAccount))
+	})
+
+	It("Should report the progress of the installer on the nodes", func() {
+		nodes := []corev1.Node{
+			{ObjectMeta: metav1.ObjectMeta{Name: "worker0", Labels: map[string]string{kataDeployLabel: "true"}}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "worker1"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "worker2"}},
+		}
+		pods := map[string]corev1.Pod{
+			"worker1": {Status: corev1.PodStatus{Phase: corev1.PodRunning}},
+			"worker2": {Status: corev1.PodStatus{
+				Phase: corev1.PodPending,
+				ContainerStatuses: []corev1.ContainerStatus{{
+					State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{
+						Reason:  "ImagePullBackOff",
+						Message: "Back-off pulling image",
+					}},
+				}},
+			}},
+		}
+
+		status := installerNodesStatus(nodes, pods, nil)
+		Expect(status.Completed.CompletedNodesList).Should(Equal([]string{"worker0"}))
+		Expect(status.InProgress.InProgressNodesList).Should(Equal([]string{"worker1"}))
+		Expect(status.Failed.FailedNodesCount).Should(Equal(1))
+		Expect(status.Failed.FailedReason).Should(Equal("worker2: The installer pod is in ImagePullBackOff: Back-off pulling image"))
+		Expect(status.IsInProgress).Should(BeTrue())
+	})
+
+	It("Should complete once every node is labelled by the installer", func() {
+		nodes := []corev1.Node{
+			{ObjectMeta: metav1.ObjectMeta{Name: "worker0", Labels: map[string]string{kataDeployLabel: "true"}}},
+		}
+		status := installerNodesStatus(nodes, map[string]corev1.Pod{}, nil)
+		Expect(status.Completed.CompletedNodesCount).Should(Equal(1))
+		Expect(status.IsInProgress).Should(BeFalse())
+	})
+})
@@ -0,0 +1,346 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	kataconfigurationv2 "github.com/openshift/sandboxed-containers-operator/api/v2"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// hasPoolNames tells whether the kata runtime is installed on the existing MachineConfigPools
+// named in the KataConfig rather than on a pool of the selected nodes
+func (r *KataConfigOpenShiftReconciler) hasPoolNames() bool {
+	return len(r.kataConfig.Spec.MachineConfigPoolNames) > 0
+}
+
+// kataMcBaseNames are the names of the MachineConfigs installing the kata runtime, before they
+// are suffixed with the instance and the pool they are created for
+var kataMcBaseNames = []string{runtimeHandlersMcName, hypervisorConfigMcName, debugMcName, extensionMcName}
+
+// mcName returns the name of a MachineConfig created for the KataConfig. A MachineConfig only
+// has one role, so each named pool gets its own MachineConfigs, suffixed with its name.
+func (r *KataConfigOpenShiftReconciler) mcName(name string, role string) string {
+	if r.hasPoolNames() {
+		return r.instanceName(name) + "-" + role
+	}
+	return r.instanceName(name)
+}
+
+// poolMcNames returns the names of the MachineConfigs created for a named pool
+func (r *KataConfigOpenShiftReconciler) poolMcNames(role string) []string {
+	var names []string
+	for _, name := range kataMcBaseNames {
+		names = append(names, r.instanceName(name)+"-"+role)
+	}
+	return names
+}
+
+// listNamedPools returns the MachineConfigPools named in the KataConfig
+func (r *KataConfigOpenShiftReconciler) listNamedPools() ([]mcfgv1.MachineConfigPool, error) {
+	var pools []mcfgv1.MachineConfigPool
+	for _, name := range r.kataConfig.Spec.MachineConfigPoolNames {
+		pool := mcfgv1.MachineConfigPool{}
+		if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: name}, &pool); err != nil {
+			r.Log.Error(err, "Getting MachineConfigPool failed", "machinePool", name)
+			return nil, err
+		}
+		pools = append(pools, pool)
+	}
+	return pools, nil
+}
+
+// getMachineConfigPool returns the MachineConfigPool the kata runtime is installed on. With
+// named pools, it returns a pool aggregating their status and records the status of each.
+func (r *KataConfigOpenShiftReconciler) getMachineConfigPool(machinePools []string) (*mcfgv1.MachineConfigPool, error) {
+	if !r.hasPoolNames() {
+		foundMcp := &mcfgv1.MachineConfigPool{}
+		err := r.Client.Get(context.TODO(), types.NamespacedName{Name: machinePools[0]}, foundMcp)
+		return foundMcp, err
+	}
+
+	pools, err := r.listNamedPools()
+	if err != nil {
+		return nil, err
+	}
+	baseGenerations := map[string]int64{}
+	for _, poolStatus := range r.kataConfig.Status.MachineConfigPools {
+		baseGenerations[poolStatus.Name] = poolStatus.BaseGeneration
+	}
+	r.kataConfig.Status.MachineConfigPools = nil
+	for _, pool := range pools {
+		r.kataConfig.Status.MachineConfigPools = append(r.kataConfig.Status.MachineConfigPools,
+			kataconfigurationv2.MachineConfigPoolStatus{
+				Name:                 pool.Name,
+				MachineCount:         pool.Status.MachineCount,
+				UpdatedMachineCount:  pool.Status.UpdatedMachineCount,
+				DegradedMachineCount: pool.Status.DegradedMachineCount,
+				ObservedGeneration:   pool.Status.ObservedGeneration,
+				BaseGeneration:       baseGenerations[pool.Name],
+			})
+	}
+	return aggregatePools(pools), nil
+}
+
+// setBaseMcpGeneration records the generation the pools observed before the current operation
+// changes their configuration. With named pools, the generation of each pool is recorded.
+func (r *KataConfigOpenShiftReconciler) setBaseMcpGeneration(foundMcp *mcfgv1.MachineConfigPool) {
+	r.kataConfig.Status.BaseMcpGeneration = foundMcp.Status.ObservedGeneration
+	for i := range r.kataConfig.Status.MachineConfigPools {
+		poolStatus := &r.kataConfig.Status.MachineConfigPools[i]
+		poolStatus.BaseGeneration = poolStatus.ObservedGeneration
+	}
+}
+
+// isNewMcpGenerationObserved tells whether the pools observed a generation newer than the one
+// recorded before the current operation changed their configuration. With named pools, each of
+// them has to observe a generation newer than its own, as the sum of their generations already
+// grows once one of them observed its new configuration.
+func (r *KataConfigOpenShiftReconciler) isNewMcpGenerationObserved(foundMcp *mcfgv1.MachineConfigPool) bool {
+	if !r.hasPoolNames() {
+		return foundMcp.Status.ObservedGeneration > r.kataConfig.Status.BaseMcpGeneration
+	}
+	for _, poolStatus := range r.kataConfig.Status.MachineConfigPools {
+		if poolStatus.ObservedGeneration <= poolStatus.BaseGeneration {
+			return false
+		}
+	}
+	return true
+}
+
+// aggregatePools returns a MachineConfigPool whose status sums up the ones of the given pools.
+// It is only updated when all of them are, and updating or degraded as soon as one of them is.
+// Its generations are the sums of theirs, so it only observed its generation once all of them
+// did. Whether each of them observed a new generation is tracked in the KataConfig status.
+func aggregatePools(pools []mcfgv1.MachineConfigPool) *mcfgv1.MachineConfigPool {
+	aggregate := &mcfgv1.MachineConfigPool{}
+	aggregate.Status.Conditions = []mcfgv1.MachineConfigPoolCondition{
+		*mcfgv1.NewMachineConfigPoolCondition(mcfgv1.MachineConfigPoolUpdated, corev1.ConditionTrue, "", ""),
+		*mcfgv1.NewMachineConfigPoolCondition(mcfgv1.MachineConfigPoolUpdating, corev1.ConditionFalse, "", ""),
+		*mcfgv1.NewMachineConfigPoolCondition(mcfgv1.MachineConfigPoolDegraded, corev1.ConditionFalse, "", ""),
+		*mcfgv1.NewMachineConfigPoolCondition(mcfgv1.MachineConfigPoolNodeDegraded, corev1.ConditionFalse, "", ""),
+	}
+
+	var names []string
+	for _, pool := range pools {
+		names = append(names, pool.Name)
+		aggregate.Generation += pool.Generation
+		aggregate.Status.ObservedGeneration += pool.Status.ObservedGeneration
+		aggregate.Status.MachineCount += pool.Status.MachineCount
+		aggregate.Status.UpdatedMachineCount += pool.Status.UpdatedMachineCount
+		aggregate.Status.ReadyMachineCount += pool.Status.ReadyMachineCount
+		aggregate.Status.UnavailableMachineCount += pool.Status.UnavailableMachineCount
+		aggregate.Status.DegradedMachineCount += pool.Status.DegradedMachineCount
+		aggregate.Spec.Configuration.Source = append(aggregate.Spec.Configuration.Source, pool.Spec.Configuration.Source...)
+		aggregate.Status.Configuration.Source = append(aggregate.Status.Configuration.Source, pool.Status.Configuration.Source...)
+
+		for i := range aggregate.Status.Conditions {
+			condition := &aggregate.Status.Conditions[i]
+			poolCondition := mcfgv1.GetMachineConfigPoolCondition(pool.Status, condition.Type)
+			isTrue := poolCondition != nil && poolCondition.Status == corev1.ConditionTrue
+			if condition.Type == mcfgv1.MachineConfigPoolUpdated {
+				if !isTrue {
+					condition.Status = corev1.ConditionFalse
+				}
+			} else if isTrue && condition.Status != corev1.ConditionTrue {
+				condition.Status = corev1.ConditionTrue
+				condition.Message = poolCondition.Message
+			}
+		}
+	}
+	aggregate.Name = strings.Join(names, ",")
+	return aggregate
+}
+
+// isNodeOnPoolConfig tells whether the node runs the latest configuration rendered for its pool
+func (r *KataConfigOpenShiftReconciler) isNodeOnPoolConfig(node *corev1.Node, foundMcp *mcfgv1.MachineConfigPool) (bool, error) {
+	currentNodeConfig, ok := node.Annotations["machineconfiguration.openshift.io/currentConfig"]
+	if !ok {
+		return false, nil
+	}
+	if !r.hasPoolNames() {
+		return foundMcp.Spec.Configuration.Name == currentNodeConfig, nil
+	}
+
+	pools, err := r.listNamedPools()
+	if err != nil {
+		return false, err
+	}
+	for _, pool := range pools {
+		if pool.Spec.Configuration.Name == currentNodeConfig {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// listNamedPoolsNodes returns the nodes of the MachineConfigPools named in the KataConfig
+func (r *KataConfigOpenShiftReconciler) listNamedPoolsNodes() (*corev1.NodeList, error) {
+	pools, err := r.listNamedPools()
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := &corev1.NodeList{}
+	seen := map[string]bool{}
+	for _, pool := range pools {
+		selector, err := metav1.LabelSelectorAsSelector(pool.Spec.NodeSelector)
+		if err != nil {
+			return nil, err
+		}
+		poolNodes := &corev1.NodeList{}
+		if err = r.Client.List(context.TODO(), poolNodes, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+			return nil, err
+		}
+		for _, node := range poolNodes.Items {
+			if !seen[node.Name] {
+				seen[node.Name] = true
+				nodes.Items = append(nodes.Items, node)
+			}
+		}
+	}
+	return nodes, nil
+}
+
+// isExtensionOnNode tells whether the configuration the node runs contains the extension
+// MachineConfig of its pool. It returns false as second value when the configuration of the node
+// was not rendered by any of the given pools, e.g. while it is rolling out a new one.
+func (r *KataConfigOpenShiftReconciler) isExtensionOnNode(node *corev1.Node, pools []mcfgv1.MachineConfigPool) (bool, bool) {
+	currentConfig := node.Annotations["machineconfiguration.openshift.io/currentConfig"]
+	if currentConfig == "" {
+		return false, false
+	}
+	for _, pool := range pools {
+		for _, configuration := range []mcfgv1.MachineConfigPoolStatusConfiguration{pool.Status.Configuration, pool.Spec.Configuration} {
+			if configuration.Name == currentConfig {
+				return isMcInConfiguration(r.mcName(extensionMcName, pool.Name), configuration), true
+			}
+		}
+	}
+	return false, false
+}
+
+// labelPoolNodes labels the nodes of the named pools with the kata runtime label the
+// RuntimeClass schedules pods with, as the pools may not share a node selector. A node is only
+// labelled once the configuration it runs contains the extension MachineConfig, and the label
+// is removed from the nodes running a configuration without it.
+func (r *KataConfigOpenShiftReconciler) labelPoolNodes() error {
+	pools, err := r.listNamedPools()
+	if err != nil {
+		return err
+	}
+	nodes, err := r.listNamedPoolsNodes()
+	if err != nil {
+		return err
+	}
+
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		hasExtension, isKnown := r.isExtensionOnNode(node, pools)
+		isLabelled := node.Labels[kataRuntimeNodeLabel] == "true"
+		if !isKnown || hasExtension == isLabelled {
+			continue
+		}
+		patch := client.MergeFrom(node.DeepCopy())
+		if hasExtension {
+			if node.Labels == nil {
+				node.Labels = map[string]string{}
+			}
+			node.Labels[kataRuntimeNodeLabel] = "true"
+			r.Log.Info("Labelling the node of a named pool running the extension", "node", node.Name)
+		} else {
+			delete(node.Labels, kataRuntimeNodeLabel)
+			r.Log.Info("Unlabelling the node of a named pool running a configuration without the extension", "node", node.Name)
+		}
+		if err = r.Client.Patch(context.TODO(), node, patch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeDroppedPools removes the kata runtime from the pools removed from machineConfigPoolNames:
+// it deletes their MachineConfigs and the kata runtime label of their nodes. The pools named
+// before are the ones recorded in the status.
+func (r *KataConfigOpenShiftReconciler) removeDroppedPools() error {
+	var dropped []string
+	for _, poolStatus := range r.kataConfig.Status.MachineConfigPools {
+		if !contains(r.kataConfig.Spec.MachineConfigPoolNames, poolStatus.Name) {
+			dropped = append(dropped, poolStatus.Name)
+		}
+	}
+	if len(dropped) == 0 {
+		return nil
+	}
+
+	/* the nodes still selected by a named pool keep their label */
+	keptNodes := map[string]bool{}
+	if r.hasPoolNames() {
+		nodes, err := r.listNamedPoolsNodes()
+		if err != nil {
+			return err
+		}
+		for _, node := range nodes.Items {
+			keptNodes[node.Name] = true
+		}
+	}
+
+	for _, name := range dropped {
+		r.Log.Info("MachineConfigPool removed from machineConfigPoolNames, removing the kata runtime from it", "mcp.Name", name)
+		for _, mcName := range r.poolMcNames(name) {
+			if err := r.deleteMc(mcName); err != nil {
+				return err
+			}
+		}
+
+		pool := &mcfgv1.MachineConfigPool{}
+		err := r.Client.Get(context.TODO(), types.NamespacedName{Name: name}, pool)
+		if err != nil && k8serrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		selector, err := metav1.LabelSelectorAsSelector(pool.Spec.NodeSelector)
+		if err != nil {
+			return err
+		}
+		nodes := &corev1.NodeList{}
+		if err = r.Client.List(context.TODO(), nodes, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+			return err
+		}
+		for i := range nodes.Items {
+			node := &nodes.Items[i]
+			if keptNodes[node.Name] || node.Labels[kataRuntimeNodeLabel] == "" {
+				continue
+			}
+			patch := client.MergeFrom(node.DeepCopy())
+			delete(node.Labels, kataRuntimeNodeLabel)
+			r.Log.Info("Unlabelling the node of a removed pool", "node", node.Name, "mcp.Name", name)
+			if err = r.Client.Patch(context.TODO(), node, patch); err != nil && !k8serrors.IsNotFound(err) {
+				return err
+			}
+		}
+	}
+
+	var kept []kataconfigurationv2.MachineConfigPoolStatus
+	for _, poolStatus := range r.kataConfig.Status.MachineConfigPools {
+		if !contains(dropped, poolStatus.Name) {
+			kept = append(kept, poolStatus)
+		}
+	}
+	r.kataConfig.Status.MachineConfigPools = kept
+	return nil
+}
+
+// kataNodeSelector returns the selector of the nodes the kata runtime is installed on
+func (r *KataConfigOpenShiftReconciler) kataNodeSelector() *metav1.LabelSelector {
+	if r.hasPoolNames() {
+		return &metav1.LabelSelector{MatchLabels: map[string]string{kataRuntimeNodeLabel: "true"}}
+	}
+	return r.kataConfig.Spec.KataConfigPoolSelector
+}
@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	kataconfigurationv2 "github.com/openshift/sandboxed-containers-operator/api/v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newNamedPool(name string, updated bool, machineCount int32) mcfgv1.MachineConfigPool {
+	updatedStatus := corev1.ConditionFalse
+	if updated {
+		updatedStatus = corev1.ConditionTrue
+	}
+	return mcfgv1.MachineConfigPool{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: mcfgv1.MachineConfigPoolSpec{
+			NodeSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"node-role.kubernetes.io/" + name: ""}},
+		},
+		Status: mcfgv1.MachineConfigPoolStatus{
+			ObservedGeneration:  2,
+			MachineCount:        machineCount,
+			UpdatedMachineCount: machineCount,
+			Conditions: []mcfgv1.MachineConfigPoolCondition{
+				*mcfgv1.NewMachineConfigPoolCondition(mcfgv1.MachineConfigPoolUpdated, updatedStatus, "", ""),
+			},
+		},
+	}
+}
+
+var _ = Describe("Existing MachineConfigPools", func() {
+	It("Should name the MachineConfigs after each pool", func() {
+		r := &KataConfigOpenShiftReconciler{kataConfig: &kataconfigurationv2.KataConfig{}}
+		Expect(r.mcName(extensionMcName, "worker")).Should(Equal(extensionMcName))
+
+		r.kataConfig.Spec.MachineConfigPoolNames = []string{"infra", "gpu"}
+		Expect(r.mcName(extensionMcName, "infra")).Should(Equal(extensionMcName + "-infra"))
+		Expect(r.poolMcNames("gpu")).Should(ContainElement(extensionMcName + "-gpu"))
+		Expect(r.kataMcNames()).Should(ContainElements(extensionMcName+"-infra", debugMcName+"-gpu"))
+	})
+
+	It("Should only be updated once all the pools are", func() {
+		infra := newNamedPool("infra", true, 2)
+		gpu := newNamedPool("gpu", false, 1)
+		gpu.Status.Conditions = append(gpu.Status.Conditions,
+			*mcfgv1.NewMachineConfigPoolCondition(mcfgv1.MachineConfigPoolDegraded, corev1.ConditionTrue, "", "gpu degraded"))
+		gpu.Status.DegradedMachineCount = 1
+
+		mcp := aggregatePools([]mcfgv1.MachineConfigPool{infra, gpu})
+		Expect(mcp.Name).Should(Equal("infra,gpu"))
+		Expect(mcp.Status.MachineCount).Should(Equal(int32(3)))
+		Expect(mcp.Status.DegradedMachineCount).Should(Equal(int32(1)))
+		Expect(mcp.Status.ObservedGeneration).Should(Equal(int64(4)))
+		Expect(mcfgv1.IsMachineConfigPoolConditionTrue(mcp.Status.Conditions, mcfgv1.MachineConfigPoolUpdated)).Should(BeFalse())
+		Expect(mcfgv1.IsMachineConfigPoolConditionTrue(mcp.Status.Conditions, mcfgv1.MachineConfigPoolDegraded)).Should(BeTrue())
+		Expect(mcfgv1.GetMachineConfigPoolCondition(mcp.Status, mcfgv1.MachineConfigPoolDegraded).Message).Should(Equal("gpu degraded"))
+
+		mcp = aggregatePools([]mcfgv1.MachineConfigPool{infra})
+		Expect(mcfgv1.IsMachineConfigPoolConditionTrue(mcp.Status.Conditions, mcfgv1.MachineConfigPoolUpdated)).Should(BeTrue())
+	})
+
+	Context("With the pools and nodes of the cluster", func() {
+		var c client.Client
+		var r *KataConfigOpenShiftReconciler
+		var infra, gpu mcfgv1.MachineConfigPool
+
+		BeforeEach(func() {
+			scheme := runtime.NewScheme()
+			Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+			Expect(mcfgv1.AddToScheme(scheme)).To(Succeed())
+			infra = newNamedPool("infra", true, 1)
+			infra.Spec.Configuration.Name = "rendered-infra-2"
+			infra.Spec.Configuration.Source = []corev1.ObjectReference{{Name: extensionMcName + "-infra"}}
+			infra.Status.Configuration.Name = "rendered-infra-1"
+			gpu = newNamedPool("gpu", true, 1)
+			infraNode := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "infra0",
+				Labels:      map[string]string{"node-role.kubernetes.io/infra": ""},
+				Annotations: map[string]string{"machineconfiguration.openshift.io/currentConfig": "rendered-infra-1"}}}
+			workerNode := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "worker0",
+				Labels: map[string]string{"node-role.kubernetes.io/worker": ""}}}
+			c = fake.NewClientBuilder().WithScheme(scheme).WithObjects(&infra, &gpu, infraNode, workerNode).Build()
+			r = &KataConfigOpenShiftReconciler{
+				Client: c,
+				Log:    ctrl.Log.WithName("test"),
+				kataConfig: &kataconfigurationv2.KataConfig{Spec: kataconfigurationv2.KataConfigSpec{
+					MachineConfigPoolNames: []string{"infra", "gpu"},
+				}},
+			}
+		})
+
+		getInfraNode := func() *corev1.Node {
+			node := &corev1.Node{}
+			Expect(c.Get(context.TODO(), types.NamespacedName{Name: "infra0"}, node)).To(Succeed())
+			return node
+		}
+
+		It("Should record the status of each pool", func() {
+			mcp, err := r.getMachineConfigPool([]string{"infra", "gpu"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(mcp.Status.MachineCount).Should(Equal(int32(2)))
+			Expect(r.kataConfig.Status.MachineConfigPools).Should(HaveLen(2))
+			Expect(r.kataConfig.Status.MachineConfigPools[1].Name).Should(Equal("gpu"))
+		})
+
+		It("Should wait for every pool to observe a new generation", func() {
+			mcp, err := r.getMachineConfigPool([]string{"infra", "gpu"})
+			Expect(err).ToNot(HaveOccurred())
+			r.setBaseMcpGeneration(mcp)
+			Expect(r.isNewMcpGenerationObserved(mcp)).Should(BeFalse())
+
+			By("Waiting while another pool still reports the configuration it ran before")
+			infra.Status.ObservedGeneration = 3
+			Expect(c.Update(context.TODO(), &infra)).To(Succeed())
+			mcp, err = r.getMachineConfigPool([]string{"infra", "gpu"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(mcp.Status.ObservedGeneration).Should(BeNumerically(">", r.kataConfig.Status.BaseMcpGeneration))
+			Expect(r.isNewMcpGenerationObserved(mcp)).Should(BeFalse())
+
+			gpu.Status.ObservedGeneration = 3
+			Expect(c.Update(context.TODO(), &gpu)).To(Succeed())
+			mcp, err = r.getMachineConfigPool([]string{"infra", "gpu"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(r.isNewMcpGenerationObserved(mcp)).Should(BeTrue())
+		})
+
+		It("Should only label the nodes running the extension MachineConfig", func() {
+			Expect(r.labelPoolNodes()).To(Succeed())
+			Expect(getInfraNode().Labels).ShouldNot(HaveKey(kataRuntimeNodeLabel))
+
+			By("Labelling the node once it runs the configuration rendered with the extension")
+			node := getInfraNode()
+			node.Annotations["machineconfiguration.openshift.io/currentConfig"] = "rendered-infra-2"
+			Expect(c.Update(context.TODO(), node)).To(Succeed())
+			Expect(r.labelPoolNodes()).To(Succeed())
+			Expect(getInfraNode().Labels[kataRuntimeNodeLabel]).Should(Equal("true"))
+
+			err, nodes := r.getNodes()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(nodes.Items).Should(HaveLen(1))
+			Expect(r.kataNodeSelector().MatchLabels).Should(Equal(map[string]string{kataRuntimeNodeLabel: "true"}))
+		})
+
+		It("Should remove the kata runtime from the pools removed from the KataConfig", func() {
+			node := getInfraNode()
+			node.Labels[kataRuntimeNodeLabel] = "true"
+			Expect(c.Update(context.TODO(), node)).To(Succeed())
+			mc, err := newExtensionMc(extensionMcName+"-infra", "infra", "example-kataconfig")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(c.Create(context.TODO(), mc)).To(Succeed())
+			_, err = r.getMachineConfigPool([]string{"infra", "gpu"})
+			Expect(err).ToNot(HaveOccurred())
+
+			r.kataConfig.Spec.MachineConfigPoolNames = []string{"gpu"}
+			Expect(r.removeDroppedPools()).To(Succeed())
+			Expect(c.Get(context.TODO(), types.NamespacedName{Name: mc.Name}, &mcfgv1.MachineConfig{})).ToNot(Succeed())
+			Expect(getInfraNode().Labels).ShouldNot(HaveKey(kataRuntimeNodeLabel))
+			Expect(r.kataConfig.Status.MachineConfigPools).Should(HaveLen(1))
+			Expect(r.kataConfig.Status.MachineConfigPools[0].Name).Should(Equal("gpu"))
+		})
+	})
+})
@@ -27,15 +27,17 @@ import (
 	ignTypes "github.com/coreos/ignition/v2/config/v3_2/types"
 	"github.com/go-logr/logr"
 	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
-	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	kataconfigurationv2 "github.com/openshift/sandboxed-containers-operator/api/v2"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	nodeapi "k8s.io/api/node/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
-	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
@@ -50,11 +52,12 @@ import (
 // KataConfigOpenShiftReconciler reconciles a KataConfig object
 type KataConfigOpenShiftReconciler struct {
 	client.Client
-	Log    logr.Logger
-	Scheme *runtime.Scheme
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
 
 	clientset  kubernetes.Interface
-	kataConfig *kataconfigurationv1.KataConfig
+	kataConfig *kataconfigurationv2.KataConfig
 }
 
 // +kubebuilder:rbac:groups=kataconfiguration.openshift.io,resources=kataconfigs;kataconfigs/finalizers,verbs=get;list;watch;create;update;patch;delete
@@ -63,6 +66,9 @@ type KataConfigOpenShiftReconciler struct {
 // +kubebuilder:rbac:groups=apps,resources=daemonsets/finalizers,resourceNames=manager-role,verbs=update
 // +kubebuilder:rbac:groups=node.k8s.io,resources=runtimeclasses,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=config.openshift.io,resources=clusterversions,verbs=get
+// +kubebuilder:rbac:groups=config.openshift.io,resources=infrastructures,verbs=get
+// +kubebuilder:rbac:groups="",resources=pods/eviction,verbs=create
+// +kubebuilder:rbac:groups=security.openshift.io,resources=securitycontextconstraints,resourceNames=privileged,verbs=use
 // +kubebuilder:rbac:groups="";machineconfiguration.openshift.io,resources=nodes;machineconfigs;machineconfigpools;pods;services;services/finalizers;endpoints;persistentvolumeclaims;events;configmaps;secrets,verbs=get;list;watch;create;update;patch;delete
 
 func (r *KataConfigOpenShiftReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -70,7 +76,7 @@ func (r *KataConfigOpenShiftReconciler) Reconcile(ctx context.Context, req ctrl.
 	r.Log.Info("Reconciling KataConfig in OpenShift Cluster")
 
 	// Fetch the KataConfig instance
-	r.kataConfig = &kataconfigurationv1.KataConfig{}
+	r.kataConfig = &kataconfigurationv2.KataConfig{}
 	err := r.Client.Get(context.TODO(), req.NamespacedName, r.kataConfig)
 	if err != nil {
 		if k8serrors.IsNotFound(err) {
@@ -89,14 +95,17 @@ func (r *KataConfigOpenShiftReconciler) Reconcile(ctx context.Context, req ctrl.
 		// indicated by the deletion timestamp being set.
 		if r.kataConfig.GetDeletionTimestamp() != nil {
 			res, err := r.processKataConfigDeleteRequest()
+			updateConditions(r.kataConfig)
 			updateErr := r.Client.Status().Update(context.TODO(), r.kataConfig)
-			if updateErr != nil {
+			// the KataConfig is gone once the uninstallation removed the finalizer
+			if updateErr != nil && !k8serrors.IsNotFound(updateErr) {
 				return ctrl.Result{}, updateErr
 			}
 			return res, err
 		}
 
 		res, err := r.processKataConfigInstallRequest()
+		updateConditions(r.kataConfig)
 		updateErr := r.Client.Status().Update(context.TODO(), r.kataConfig)
 		if updateErr != nil {
 			return ctrl.Result{}, updateErr
@@ -110,14 +119,10 @@ func (r *KataConfigOpenShiftReconciler) newMCPforCR() *mcfgv1.MachineConfigPool
 	lsr := metav1.LabelSelectorRequirement{
 		Key:      "machineconfiguration.openshift.io/role",
 		Operator: metav1.LabelSelectorOpIn,
-		Values:   []string{"kata-oc", "worker"},
+		Values:   []string{r.poolName(), "worker"},
 	}
 
-	var nodeSelector *metav1.LabelSelector
-
-	if r.kataConfig.Spec.KataConfigPoolSelector != nil {
-		nodeSelector = r.kataConfig.Spec.KataConfigPoolSelector
-	}
+	nodeSelector := r.poolNodeSelector()
 
 	mcp := &mcfgv1.MachineConfigPool{
 		TypeMeta: metav1.TypeMeta{
@@ -125,7 +130,7 @@ func (r *KataConfigOpenShiftReconciler) newMCPforCR() *mcfgv1.MachineConfigPool
 			Kind:       "MachineConfigPool",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name: "kata-oc",
+			Name: r.poolName(),
 		},
 		Spec: mcfgv1.MachineConfigPoolSpec{
 			MachineConfigSelector: &metav1.LabelSelector{
@@ -134,23 +139,37 @@ func (r *KataConfigOpenShiftReconciler) newMCPforCR() *mcfgv1.MachineConfigPool
 			NodeSelector: nodeSelector,
 		},
 	}
+	r.setMcpRolloutControls(mcp)
 
 	return mcp
 }
 
+// setMcpRolloutControls applies the maxUnavailable and paused fields of the KataConfig to the
+// kata-oc MachineConfigPool. It returns true when the MachineConfigPool was changed.
+func (r *KataConfigOpenShiftReconciler) setMcpRolloutControls(mcp *mcfgv1.MachineConfigPool) bool {
+	if equality.Semantic.DeepEqual(mcp.Spec.MaxUnavailable, r.kataConfig.Spec.MaxUnavailable) &&
+		mcp.Spec.Paused == r.kataConfig.Spec.Paused {
+		return false
+	}
+	mcp.Spec.MaxUnavailable = r.kataConfig.Spec.MaxUnavailable
+	mcp.Spec.Paused = r.kataConfig.Spec.Paused
+	return true
+}
+
 func (r *KataConfigOpenShiftReconciler) newMCForCR(machinePool string) (*mcfgv1.MachineConfig, error) {
 	r.Log.Info("Creating MachineConfig for Custom Resource")
-	kataOC, err := r.kataOcExists()
+	mcName := r.mcName(extensionMcName, machinePool)
+	machinePool, err := r.getMcRole(machinePool)
 	if err != nil {
 		return nil, err
 	}
 
-	if kataOC {
-		machinePool = "kata-oc"
-	} else if _, ok := r.kataConfig.Spec.KataConfigPoolSelector.MatchLabels["node-role.kubernetes.io/"+machinePool]; !ok {
-		r.Log.Error(err, "no valid role for MachineConfig found")
-	}
+	return newExtensionMc(mcName, machinePool, r.kataConfig.Name)
+}
 
+// newExtensionMc returns the MachineConfig enabling the sandboxed containers RHCOS extension on
+// the nodes with the given role
+func newExtensionMc(name string, role string, app string) (*mcfgv1.MachineConfig, error) {
 	ic := ignTypes.Config{
 		Ignition: ignTypes.Ignition{
 			Version: "3.2.0",
@@ -168,10 +187,10 @@ func (r *KataConfigOpenShiftReconciler) newMCForCR(machinePool string) (*mcfgv1.
 			Kind:       "MachineConfig",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name: "50-enable-sandboxed-containers-extension",
+			Name: name,
 			Labels: map[string]string{
-				"machineconfiguration.openshift.io/role": machinePool,
-				"app":                                    r.kataConfig.Name,
+				"machineconfiguration.openshift.io/role": role,
+				"app":                                    app,
 			},
 			Namespace: "openshift-sandboxed-containers-operator",
 		},
@@ -186,6 +205,26 @@ func (r *KataConfigOpenShiftReconciler) newMCForCR(machinePool string) (*mcfgv1.
 	return &mc, nil
 }
 
+// getMcRole returns the role MachineConfigs created for the KataConfig must be labelled with
+func (r *KataConfigOpenShiftReconciler) getMcRole(machinePool string) (string, error) {
+	if r.hasPoolNames() {
+		return machinePool, nil
+	}
+
+	kataOC, err := r.kataOcExists()
+	if err != nil {
+		return "", err
+	}
+
+	if kataOC {
+		machinePool = r.poolName()
+	} else if _, ok := r.kataConfig.Spec.KataConfigPoolSelector.MatchLabels["node-role.kubernetes.io/"+machinePool]; !ok {
+		r.Log.Error(err, "no valid role for MachineConfig found")
+	}
+
+	return machinePool, nil
+}
+
 func (r *KataConfigOpenShiftReconciler) addFinalizer() error {
 	r.Log.Info("Adding Finalizer for the KataConfig")
 	controllerutil.AddFinalizer(r.kataConfig, kataConfigFinalizer)
@@ -199,67 +238,75 @@ func (r *KataConfigOpenShiftReconciler) addFinalizer() error {
 	return nil
 }
 
-func (r *KataConfigOpenShiftReconciler) listKataPods() error {
+func (r *KataConfigOpenShiftReconciler) listKataPods(runtimeClassName string) error {
+	pods, err := listPodsUsingRuntimeClasses(r.Client, []string{runtimeClassName})
+	if err != nil {
+		return err
+	}
+	if len(pods) > 0 {
+		return fmt.Errorf("Existing pods using Kata Runtime found. Please delete the pods manually for KataConfig deletion to proceed")
+	}
+	return nil
+}
+
+// listPodsUsingRuntimeClasses returns the pods using one of the given RuntimeClasses
+func listPodsUsingRuntimeClasses(c client.Client, runtimeClassNames []string) ([]corev1.Pod, error) {
 	podList := &corev1.PodList{}
 	listOpts := []client.ListOption{
 		client.InNamespace(corev1.NamespaceAll),
 	}
-	if err := r.Client.List(context.TODO(), podList, listOpts...); err != nil {
-		return fmt.Errorf("Failed to list kata pods: %v", err)
+	if err := c.List(context.TODO(), podList, listOpts...); err != nil {
+		return nil, fmt.Errorf("Failed to list kata pods: %v", err)
 	}
+
+	var pods []corev1.Pod
 	for _, pod := range podList.Items {
-		if pod.Spec.RuntimeClassName != nil {
-			if *pod.Spec.RuntimeClassName == r.kataConfig.Status.RuntimeClass {
-				return fmt.Errorf("Existing pods using Kata Runtime found. Please delete the pods manually for KataConfig deletion to proceed")
-			}
+		if pod.Spec.RuntimeClassName != nil && contains(runtimeClassNames, *pod.Spec.RuntimeClassName) {
+			pods = append(pods, pod)
 		}
 	}
-	return nil
+	return pods, nil
 }
 
 func (r *KataConfigOpenShiftReconciler) kataOcExists() (bool, error) {
 	kataOcMcp := &mcfgv1.MachineConfigPool{}
-	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: "kata-oc"}, kataOcMcp)
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: r.poolName()}, kataOcMcp)
 	if err != nil && k8serrors.IsNotFound(err) {
 		return false, nil
 	} else if err != nil {
-		r.Log.Error(err, "Could not get the kata-oc MachineConfigPool")
+		r.Log.Error(err, "Could not get the custom MachineConfigPool", "mcp.Name", r.poolName())
 		return false, err
 	}
 
 	return true, nil
 }
 
-func (r *KataConfigOpenShiftReconciler) getMcpName() (string, error) {
+// getMcpNames returns the MachineConfigPools the kata runtime is installed on: the named pools,
+// or the single pool of the selected nodes
+func (r *KataConfigOpenShiftReconciler) getMcpNames() ([]string, error) {
 	r.Log.Info("Getting MachineConfigPool Name")
-	var mcpName string
 
-	kataOC, err := r.kataOcExists()
-	if kataOC && err == nil {
-		r.Log.Info("kata-oc MachineConfigPool exists")
-		return "kata-oc", nil
+	topology, err := r.detectTopology()
+	if err != nil {
+		return nil, err
 	}
 
-	workerMcp := &mcfgv1.MachineConfigPool{}
-	err = r.Client.Get(context.TODO(), types.NamespacedName{Name: "worker"}, workerMcp)
-	if err != nil && k8serrors.IsNotFound(err) {
-		r.Log.Error(err, "No worker MachineConfigPool found!")
-		return "", err
-	} else if err != nil {
-		r.Log.Error(err, "Could not get the worker MachineConfigPool!")
-		return "", err
+	if r.hasPoolNames() {
+		return r.kataConfig.Spec.MachineConfigPoolNames, nil
 	}
 
-	if workerMcp.Status.MachineCount > 0 {
-		mcpName = "worker"
-	} else {
-		mcpName = "master"
+	kataOC, err := r.kataOcExists()
+	if kataOC && err == nil {
+		r.Log.Info("Custom MachineConfigPool exists", "mcp.Name", r.poolName())
+		return []string{r.poolName()}, nil
 	}
-	return mcpName, nil
+
+	return []string{topologyPool(topology)}, nil
 }
 
 func (r *KataConfigOpenShiftReconciler) setRuntimeClass() (ctrl.Result, error) {
-	runtimeClassName := "kata"
+	runtimeClassName := r.kataConfig.GetRuntimeClassName()
+	runtimeClassHandler := r.kataConfig.Spec.GetRuntimeClassHandler()
 
 	rc := func() *nodeapi.RuntimeClass {
 		rc := &nodeapi.RuntimeClass{
@@ -270,20 +317,15 @@ func (r *KataConfigOpenShiftReconciler) setRuntimeClass() (ctrl.Result, error) {
 			ObjectMeta: metav1.ObjectMeta{
 				Name: runtimeClassName,
 			},
-			Handler: runtimeClassName,
-			// Use same values for Pod Overhead as upstream kata-deploy using, see
-			// https://github.com/kata-containers/packaging/blob/f17450317563b6e4d6b1a71f0559360b37783e19/kata-deploy/k8s-1.18/kata-runtimeClasses.yaml#L7
+			Handler: runtimeClassHandler,
 			Overhead: &nodeapi.Overhead{
-				PodFixed: corev1.ResourceList{
-					corev1.ResourceCPU:    resource.MustParse("250m"),
-					corev1.ResourceMemory: resource.MustParse("350Mi"),
-				},
+				PodFixed: r.kataConfig.Spec.GetRuntimeClassOverhead(),
 			},
 		}
 
-		if r.kataConfig.Spec.KataConfigPoolSelector != nil {
-			r.Log.Info("KataConfigPoolSelector:", "r.kataConfig.Spec.KataConfigPoolSelector", r.kataConfig.Spec.KataConfigPoolSelector)
-			nodeSelector, err := metav1.LabelSelectorAsMap(r.kataConfig.Spec.KataConfigPoolSelector)
+		if poolNodeSelector := r.poolNodeSelector(); poolNodeSelector != nil {
+			r.Log.Info("KataConfigPoolSelector:", "r.kataConfig.Spec.KataConfigPoolSelector", poolNodeSelector)
+			nodeSelector, err := metav1.LabelSelectorAsMap(poolNodeSelector)
 			if err != nil {
 				r.Log.Error(err, "Unable to get nodeSelector for runtimeClass")
 			}
@@ -299,6 +341,18 @@ func (r *KataConfigOpenShiftReconciler) setRuntimeClass() (ctrl.Result, error) {
 		return ctrl.Result{}, err
 	}
 
+	/* The RuntimeClass was renamed, remove the previous one once no pod uses it */
+	previousRuntimeClass := r.kataConfig.Status.RuntimeClass
+	if previousRuntimeClass != "" && previousRuntimeClass != runtimeClassName {
+		if err := r.listKataPods(previousRuntimeClass); err != nil {
+			r.Log.Info("Pods still use the previous RuntimeClass, not renaming it yet", "RuntimeClass", previousRuntimeClass)
+			return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, nil
+		}
+		if err := r.deleteRuntimeClass(previousRuntimeClass); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
 	foundRc := &nodeapi.RuntimeClass{}
 	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: rc.Name}, foundRc)
 	if err != nil && k8serrors.IsNotFound(err) {
@@ -307,9 +361,32 @@ func (r *KataConfigOpenShiftReconciler) setRuntimeClass() (ctrl.Result, error) {
 		if err != nil {
 			return ctrl.Result{}, err
 		}
+	} else if err != nil {
+		return ctrl.Result{}, err
+	} else if foundRc.Handler != rc.Handler && metav1.IsControlledBy(foundRc, r.kataConfig) {
+		/* The handler of a RuntimeClass is immutable, it has to be recreated */
+		r.Log.Info("Replacing RuntimeClass with a new handler", "rc.Name", rc.Name, "rc.Handler", rc.Handler)
+		err = r.Client.Delete(context.TODO(), foundRc)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		err = r.Client.Create(context.TODO(), rc)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	} else if metav1.IsControlledBy(foundRc, r.kataConfig) &&
+		(!equality.Semantic.DeepEqual(foundRc.Overhead, rc.Overhead) ||
+			!equality.Semantic.DeepEqual(foundRc.Scheduling, rc.Scheduling)) {
+		r.Log.Info("Updating RuntimeClass", "rc.Name", rc.Name)
+		foundRc.Overhead = rc.Overhead
+		foundRc.Scheduling = rc.Scheduling
+		err = r.Client.Update(context.TODO(), foundRc)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
 	}
 
-	if r.kataConfig.Status.RuntimeClass == "" {
+	if r.kataConfig.Status.RuntimeClass != runtimeClassName {
 		r.kataConfig.Status.RuntimeClass = runtimeClassName
 		err = r.Client.Status().Update(context.TODO(), r.kataConfig)
 		if err != nil {
@@ -317,122 +394,195 @@ func (r *KataConfigOpenShiftReconciler) setRuntimeClass() (ctrl.Result, error) {
 		}
 	}
 
+	err = r.setRuntimeClassVariants()
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	err = r.reconcilePeerPods()
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
 	return ctrl.Result{}, nil
 }
 
+// deleteRuntimeClass removes the named RuntimeClass if it is owned by the KataConfig
+func (r *KataConfigOpenShiftReconciler) deleteRuntimeClass(runtimeClassName string) error {
+	rc := &nodeapi.RuntimeClass{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: runtimeClassName}, rc)
+	if err != nil && k8serrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if !metav1.IsControlledBy(rc, r.kataConfig) {
+		r.Log.Info("RuntimeClass not owned by KataConfig, leaving it in place", "rc.Name", runtimeClassName)
+		return nil
+	}
+
+	r.Log.Info("Deleting RuntimeClass", "rc.Name", runtimeClassName)
+	err = r.Client.Delete(context.TODO(), rc)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// processKataConfigDeleteRequest drives the uninstallation through the phases persisted in the
+// status, so that it never blocks the reconcile loop and resumes where it stopped after a restart
+// of the operator
 func (r *KataConfigOpenShiftReconciler) processKataConfigDeleteRequest() (ctrl.Result, error) {
-	r.Log.Info("KataConfig deletion in progress: ")
-	machinePool, err := r.getMcpName()
+	r.Log.Info("KataConfig deletion in progress: ", "phase", r.kataConfig.Status.UnInstallationStatus.Phase)
+	if !contains(r.kataConfig.GetFinalizers(), kataConfigFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	machinePools, err := r.getMcpNames()
 	if err != nil {
 		return reconcile.Result{Requeue: true, RequeueAfter: 15 * time.Second}, err
 	}
 
-	foundMcp := &mcfgv1.MachineConfigPool{}
-	err = r.Client.Get(context.TODO(), types.NamespacedName{Name: machinePool}, foundMcp)
+	if err = r.removeDroppedPools(); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	foundMcp, err := r.getMachineConfigPool(machinePools)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
 
-	if contains(r.kataConfig.GetFinalizers(), kataConfigFinalizer) {
-		// Get the list of pods that might be running using kata runtime
-		err := r.listKataPods()
-		if err != nil {
-			r.kataConfig.Status.UnInstallationStatus.ErrorMessage = err.Error()
-			updErr := r.Client.Status().Update(context.TODO(), r.kataConfig)
-			if updErr != nil {
-				return ctrl.Result{}, updErr
-			}
-			r.Log.Info("Kata PODs are present. Requeue for reconciliation ")
-			return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, err
-		} else {
-			if r.kataConfig.Status.UnInstallationStatus.ErrorMessage != "" {
-				r.kataConfig.Status.UnInstallationStatus.ErrorMessage = ""
-				updErr := r.Client.Status().Update(context.TODO(), r.kataConfig)
-				if updErr != nil {
-					return ctrl.Result{}, updErr
-				}
-			}
+	if !r.hasPoolNames() {
+		if poolSelector, err := topologyPoolSelector(r.kataConfig.Spec.KataConfigPoolSelector, machinePools[0]); err == nil {
+			r.kataConfig.Spec.KataConfigPoolSelector = poolSelector
 		}
 	}
 
-	if r.kataConfig.Spec.KataConfigPoolSelector == nil {
-		r.kataConfig.Spec.KataConfigPoolSelector = &metav1.LabelSelector{
-			MatchLabels: map[string]string{"node-role.kubernetes.io/" + machinePool: ""},
+	switch r.kataConfig.Status.UnInstallationStatus.Phase {
+	case "", kataconfigurationv2.UninstallPhaseBlockedByPods:
+		return r.deleteMachineConfigs(machinePools, foundMcp)
+	case kataconfigurationv2.UninstallPhaseMachineConfigDeleted:
+		/* Wait for the MachineConfigPool to render a configuration without the MachineConfigs */
+		if !r.isNewMcpGenerationObserved(foundMcp) &&
+			isAnyMcInConfiguration(r.kataMcNames(), foundMcp.Spec.Configuration) {
+			r.Log.Info("Waiting for the MachineConfigPool to pick up the deleted MachineConfigs", "mcp.Names", machinePools)
+			return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, nil
+		}
+		r.setUninstallPhase(kataconfigurationv2.UninstallPhasePoolRolling)
+		return ctrl.Result{Requeue: true}, nil
+	case kataconfigurationv2.UninstallPhasePoolRolling:
+		r.Log.Info("Monitoring worker mcp", "worker mcp name", foundMcp.Name, "ready machines", foundMcp.Status.ReadyMachineCount,
+			"total machines", foundMcp.Status.MachineCount)
+		_, result, err, done := r.updateStatus(machinePools)
+		if !done {
+			return result, err
+		}
+		if isAnyMcInConfiguration(r.kataMcNames(), foundMcp.Status.Configuration) ||
+			!mcfgv1.IsMachineConfigPoolConditionTrue(foundMcp.Status.Conditions, mcfgv1.MachineConfigPoolUpdated) ||
+			foundMcp.Status.ReadyMachineCount != foundMcp.Status.MachineCount {
+			return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, nil
 		}
+		r.setUninstallPhase(kataconfigurationv2.UninstallPhaseCleanup)
+		return ctrl.Result{Requeue: true}, nil
+	case kataconfigurationv2.UninstallPhaseCleanup:
+		if err = r.removeNodeLabels(); err != nil {
+			return ctrl.Result{}, err
+		}
+		r.kataConfig.Status.UnInstallationStatus.IsInProgress = false
+		r.clearInstallStatus()
+		r.setUninstallPhase(kataconfigurationv2.UninstallPhaseDone)
+		return ctrl.Result{Requeue: true}, nil
 	}
 
-	r.Log.Info("Making sure parent MCP is synced properly, SCNodeRole=" + machinePool)
-	r.kataConfig.Status.UnInstallationStatus.InProgress.IsInProgress = corev1.ConditionTrue
-	mc, err := r.newMCForCR(machinePool)
+	r.Log.Info("Uninstallation completed. Proceeding with the KataConfig deletion")
+	controllerutil.RemoveFinalizer(r.kataConfig, kataConfigFinalizer)
+
+	err = r.Client.Update(context.TODO(), r.kataConfig)
 	if err != nil {
+		r.Log.Error(err, "Unable to update KataConfig")
 		return ctrl.Result{}, err
 	}
-	var isMcDeleted bool
+	return ctrl.Result{}, nil
+}
 
-	err = r.Client.Get(context.TODO(), types.NamespacedName{Name: mc.Name}, mc)
-	if err != nil && k8serrors.IsNotFound(err) {
-		isMcDeleted = true
-	} else if err != nil {
+// deleteMachineConfigs deletes the MachineConfigs installing the kata runtime once no pod uses it
+func (r *KataConfigOpenShiftReconciler) deleteMachineConfigs(machinePools []string, foundMcp *mcfgv1.MachineConfigPool) (ctrl.Result, error) {
+	// Get the list of pods that might be running using kata runtime
+	pods, err := listPodsUsingRuntimeClasses(r.Client, r.runtimeClassNames())
+	if err != nil {
 		return ctrl.Result{}, err
 	}
+	r.setBlockingPods(pods)
+	if len(pods) > 0 {
+		message, err := r.removeKataPods(pods)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		r.kataConfig.Status.UnInstallationStatus.ErrorMessage = message
+		r.setUninstallPhase(kataconfigurationv2.UninstallPhaseBlockedByPods)
+		r.Log.Info("Kata PODs are present. Requeue for reconciliation ")
+		return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, nil
+	}
+	r.kataConfig.Status.UnInstallationStatus.ErrorMessage = ""
 
-	if !isMcDeleted {
-		err = r.Client.Delete(context.TODO(), mc)
+	r.Log.Info("Making sure parent MCP is synced properly", "mcp.Names", machinePools)
+	for _, mcName := range r.kataMcNames() {
+		err = r.deleteMc(mcName)
 		if err != nil {
 			// error during removing mc, don't block the uninstall. Just log the error and move on.
 			r.Log.Error(err, "Error found deleting machine config. If the machine config exists after installation it can be safely deleted manually.",
-				"mc", mc.Name)
+				"mc", mcName)
 		}
-		// Sleep for MCP to reflect the changes
-		r.Log.Info("Pausing for a minute to make sure worker mcp has started syncing up")
-		time.Sleep(60 * time.Second)
 	}
 
-	workerMcp := &mcfgv1.MachineConfigPool{}
-	err = r.Client.Get(context.TODO(), types.NamespacedName{Name: machinePool}, workerMcp)
-	if err != nil {
-		r.Log.Error(err, "Unable to get MachineConfigPool ", "machinePool", machinePool)
-		return ctrl.Result{}, err
-	}
-	r.Log.Info("Monitoring worker mcp", "worker mcp name", workerMcp.Name, "ready machines", workerMcp.Status.ReadyMachineCount,
-		"total machines", workerMcp.Status.MachineCount)
-	r.kataConfig.Status.UnInstallationStatus.InProgress.IsInProgress = corev1.ConditionTrue
+	r.kataConfig.Status.UnInstallationStatus.IsInProgress = true
+	r.setBaseMcpGeneration(foundMcp)
 	r.clearUninstallStatus()
-	_, result, err2, done := r.updateStatus(machinePool)
-	if !done {
-		return result, err2
-	}
-
-	if workerMcp.Status.ReadyMachineCount != workerMcp.Status.MachineCount {
-		return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, nil
-	}
+	r.setUninstallPhase(kataconfigurationv2.UninstallPhaseMachineConfigDeleted)
+	return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, nil
+}
 
-	r.kataConfig.Status.UnInstallationStatus.InProgress.IsInProgress = corev1.ConditionFalse
-	_, result, err2, done = r.updateStatus(machinePool)
-	r.clearInstallStatus()
-	if !done {
-		return result, err2
+// kataMcNames lists the MachineConfigs the operator creates to install the kata runtime
+func (r *KataConfigOpenShiftReconciler) kataMcNames() []string {
+	var names []string
+	if !r.hasPoolNames() {
+		for _, name := range kataMcBaseNames {
+			names = append(names, r.mcName(name, ""))
+		}
+		return names
 	}
-	err = r.Client.Status().Update(context.TODO(), r.kataConfig)
-	if err != nil {
-		r.Log.Error(err, "Unable to update KataConfig status")
-		return ctrl.Result{}, err
+	for _, role := range r.kataConfig.Spec.MachineConfigPoolNames {
+		names = append(names, r.poolMcNames(role)...)
 	}
+	return names
+}
 
-	r.Log.Info("Uninstallation completed. Proceeding with the KataConfig deletion")
-	controllerutil.RemoveFinalizer(r.kataConfig, kataConfigFinalizer)
+// isAnyMcInConfiguration tells whether one of the named MachineConfigs is part of a rendered configuration
+func isAnyMcInConfiguration(mcNames []string, configuration mcfgv1.MachineConfigPoolStatusConfiguration) bool {
+	for _, mcName := range mcNames {
+		if isMcInConfiguration(mcName, configuration) {
+			return true
+		}
+	}
+	return false
+}
 
-	err = r.Client.Update(context.TODO(), r.kataConfig)
-	if err != nil {
-		r.Log.Error(err, "Unable to update KataConfig")
-		return ctrl.Result{}, err
+// setUninstallPhase moves the uninstallation to the given phase
+func (r *KataConfigOpenShiftReconciler) setUninstallPhase(phase kataconfigurationv2.UninstallPhase) {
+	if r.kataConfig.Status.UnInstallationStatus.Phase != phase {
+		r.Log.Info("Uninstallation moving to a new phase", "phase", phase)
+		r.kataConfig.Status.UnInstallationStatus.Phase = phase
 	}
-	return ctrl.Result{}, nil
 }
 
 func (r *KataConfigOpenShiftReconciler) processKataConfigInstallRequest() (ctrl.Result, error) {
 	r.Log.Info("Kata installation in progress")
-	machinePool, err := r.getMcpName()
+	if err := assignPoolName(r.Client, r.Log, r.kataConfig); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	machinePools, err := r.getMcpNames()
 	if err != nil {
 		return reconcile.Result{}, err
 	}
@@ -442,17 +592,45 @@ func (r *KataConfigOpenShiftReconciler) processKataConfigInstallRequest() (ctrl.
 		if err := r.addFinalizer(); err != nil {
 			return ctrl.Result{}, err
 		}
-		r.Log.Info("SCNodeRole is: " + machinePool)
+		r.Log.Info("SCNodeRole is", "mcp.Names", machinePools)
+	}
+
+	/* The pools removed from machineConfigPoolNames lose the kata runtime */
+	if err = r.removeDroppedPools(); err != nil {
+		return ctrl.Result{}, err
 	}
 
-	if r.kataConfig.Spec.KataConfigPoolSelector == nil {
-		r.kataConfig.Spec.KataConfigPoolSelector = &metav1.LabelSelector{
-			MatchLabels: map[string]string{"node-role.kubernetes.io/" + machinePool: ""},
+	/* The installation is refused until the selector fits the topology of the cluster */
+	if !r.hasPoolNames() {
+		poolSelector, err := topologyPoolSelector(r.kataConfig.Spec.KataConfigPoolSelector, machinePools[0])
+		if err != nil {
+			r.Log.Info("The kataConfigPoolSelector does not fit the cluster topology", "topology", r.kataConfig.Status.ClusterTopology)
+			r.kataConfig.Status.InstallationStatus.Failed.FailedReason = err.Error()
+			return ctrl.Result{}, nil
+		}
+		if r.kataConfig.Status.InstallationStatus.Failed.FailedReason == errMasterPoolSelector.Error() {
+			r.kataConfig.Status.InstallationStatus.Failed.FailedReason = ""
+		}
+		r.kataConfig.Spec.KataConfigPoolSelector = poolSelector
+	}
+
+	/* A rolled back installation is only retried once the KataConfig changed */
+	if r.kataConfig.Status.Rollback != nil {
+		res, isRetried, err := r.processRollback(machinePools)
+		if !isRetried {
+			return res, err
 		}
 	}
 
-	/* create custom Machine Config Pool if configured by user */
-	if _, ok := r.kataConfig.Spec.KataConfigPoolSelector.MatchLabels["node-role.kubernetes.io/"+machinePool]; !ok {
+	r.initCanaryStatus()
+
+	/* The named pools already exist, their nodes are labelled for the RuntimeClass to select them */
+	if r.hasPoolNames() {
+		if err = r.labelPoolNodes(); err != nil {
+			return ctrl.Result{}, err
+		}
+	} else if _, ok := r.kataConfig.Spec.KataConfigPoolSelector.MatchLabels["node-role.kubernetes.io/"+machinePools[0]]; !ok {
+		/* create custom Machine Config Pool if configured by user */
 		r.Log.Info("Creating new MachineConfigPool")
 		mcp := r.newMCPforCR()
 
@@ -472,6 +650,24 @@ func (r *KataConfigOpenShiftReconciler) processKataConfigInstallRequest() (ctrl.
 			return ctrl.Result{}, err
 		}
 
+		isMcpChanged := r.setMcpRolloutControls(foundMcp)
+		if !equality.Semantic.DeepEqual(foundMcp.Spec.NodeSelector, mcp.Spec.NodeSelector) {
+			foundMcp.Spec.NodeSelector = mcp.Spec.NodeSelector
+			isMcpChanged = true
+		}
+		if isMcpChanged {
+			r.Log.Info("Updating the MachineConfigPool", "mcp.Name", mcp.Name, "nodeSelector", foundMcp.Spec.NodeSelector,
+				"maxUnavailable", foundMcp.Spec.MaxUnavailable, "paused", foundMcp.Spec.Paused)
+			err = r.Client.Update(context.TODO(), foundMcp)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+
+		if err = r.trackLeavingNodes(machinePools); err != nil {
+			return ctrl.Result{}, err
+		}
+
 		// Wait till MCP is ready
 		if foundMcp.Status.MachineCount == 0 {
 			r.Log.Info("Waiting till MachineConfigPool is initialized ", "mcp.Name", mcp.Name)
@@ -480,51 +676,156 @@ func (r *KataConfigOpenShiftReconciler) processKataConfigInstallRequest() (ctrl.
 
 	}
 
-	doReconcile, err, isMcCreated := r.createExtensionMc(machinePool)
+	/* Check the selected nodes are able to run the kata runtime, the first installation waits for the check */
+	isPreflightDone, err := r.runPreflight()
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if r.kataConfig.Status.RuntimeClass == "" && !r.kataConfig.Status.InstallationStatus.IsInProgress {
+		if !isPreflightDone {
+			return ctrl.Result{Requeue: true, RequeueAfter: 10 * time.Second}, nil
+		}
+		if countEligibleNodes(r.kataConfig.Status.NodeEligibility) == 0 {
+			if r.kataConfig.Spec.GetPreflightPolicy() == kataconfigurationv2.PreflightPolicyEnforce {
+				r.Log.Info("No selected node is able to run the kata runtime, refusing to install it")
+				return ctrl.Result{}, nil
+			}
+			r.Log.Info("No selected node is able to run the kata runtime, installing it anyway")
+		}
+	}
+
+	doReconcile, err, isMcCreated := r.createExtensionMc(machinePools)
 	if isMcCreated {
 		return doReconcile, err
 	}
 
-	foundMcp, doReconcile, err, done := r.updateStatus(machinePool)
+	isUpgradeStarted, err := r.checkUpgrade(machinePools)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if isUpgradeStarted {
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	foundMcp, doReconcile, err, done := r.updateStatus(machinePools)
 	if !done {
 		return doReconcile, err
 	}
 
 	r.kataConfig.Status.TotalNodesCount = int(foundMcp.Status.MachineCount)
 
+	if reason := rollbackReason(r.kataConfig, foundMcp, time.Now()); reason != "" {
+		return r.startRollback(foundMcp, reason)
+	}
+
+	err = r.updateDebugStatus(foundMcp)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
 	if mcfgv1.IsMachineConfigPoolConditionTrue(foundMcp.Status.Conditions, mcfgv1.MachineConfigPoolUpdating) &&
-		r.kataConfig.Status.InstallationStatus.IsInProgress == "false" &&
-		r.kataConfig.Status.RuntimeClass == "kata" {
+		!r.kataConfig.Status.InstallationStatus.IsInProgress &&
+		!r.isUpgradeInProgress() &&
+		r.kataConfig.Status.RuntimeClass != "" {
 		r.Log.Info("New node being added to existing cluster")
-		r.kataConfig.Status.InstallationStatus.IsInProgress = corev1.ConditionTrue
+		r.kataConfig.Status.InstallationStatus.IsInProgress = true
 		return reconcile.Result{Requeue: true, RequeueAfter: 15 * time.Second}, nil
 	}
 
 	if mcfgv1.IsMachineConfigPoolConditionTrue(foundMcp.Status.Conditions, mcfgv1.MachineConfigPoolUpdated) &&
-		foundMcp.Status.ObservedGeneration > r.kataConfig.Status.BaseMcpGeneration &&
+		r.isNewMcpGenerationObserved(foundMcp) &&
 		foundMcp.Status.UpdatedMachineCount == foundMcp.Status.MachineCount {
 		r.Log.Info("set runtime class")
-		r.kataConfig.Status.InstallationStatus.IsInProgress = "false"
-		return r.setRuntimeClass()
+		if r.isUpgradeInProgress() {
+			r.completeUpgrade()
+		} else if r.kataConfig.Status.InstalledVersion == "" && r.kataConfig.Status.RuntimeClass == "" {
+			r.kataConfig.Status.InstalledVersion = OperatorVersion
+		}
+		res, err := r.setRuntimeClass()
+		if err != nil {
+			return res, err
+		}
+		if r.isCanaryInProgress() {
+			r.kataConfig.Status.InstallationStatus.IsInProgress = false
+			return r.processCanary()
+		}
+
+		/* The installation is only complete once pods using the RuntimeClass run in a virtual machine */
+		isValidated, retryAfter, err := r.validateNodes(foundMcp)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if !isValidated {
+			return ctrl.Result{Requeue: true, RequeueAfter: 10 * time.Second}, nil
+		}
+		r.kataConfig.Status.InstallationStatus.IsInProgress = false
+		if retryAfter > 0 {
+			/* retry the validation of the nodes that failed it */
+			return ctrl.Result{Requeue: true, RequeueAfter: retryAfter}, nil
+		}
+		return res, nil
 	} else {
 		r.Log.Info("Waiting for MachineConfigPool to be fully updated")
 		return reconcile.Result{Requeue: true, RequeueAfter: 15 * time.Second}, nil
 	}
 }
 
-func (r *KataConfigOpenShiftReconciler) createExtensionMc(machinePool string) (ctrl.Result, error, bool) {
+func (r *KataConfigOpenShiftReconciler) createExtensionMc(machinePools []string) (ctrl.Result, error, bool) {
 	r.Log.Info("creating RHCOS extension MachineConfig")
+	foundMcp, err := r.getMachineConfigPool(machinePools)
+	if err != nil && k8serrors.IsNotFound(err) {
+		r.Log.Info("MachineConfigPool not found")
+		return reconcile.Result{Requeue: true, RequeueAfter: 15 * time.Second}, nil, false
+	} else if err != nil {
+		return ctrl.Result{}, err, true
+	}
+
+	isMcChanged := false
+	for _, role := range machinePools {
+		isRoleMcChanged, err := r.reconcileMachineConfigs(role)
+		if err != nil {
+			return ctrl.Result{}, err, true
+		}
+		isMcChanged = isMcChanged || isRoleMcChanged
+	}
+
+	if isMcChanged {
+		/* mc created successfully - it will take a moment to finalize, requeue to create runtimeclass */
+		r.kataConfig.Status.InstallationStatus.IsInProgress = true
+		r.setBaseMcpGeneration(foundMcp)
+		r.kataConfig.Status.NodeValidation = nil
+		return ctrl.Result{Requeue: true}, nil, true
+	}
+	return ctrl.Result{}, nil, false
+}
+
+// reconcileMachineConfigs applies the MachineConfigs installing the kata runtime with the given
+// role. It returns true if one of them was changed and the pool has to roll out the change.
+func (r *KataConfigOpenShiftReconciler) reconcileMachineConfigs(machinePool string) (bool, error) {
 	mc, err := r.newMCForCR(machinePool)
 	if err != nil {
-		return ctrl.Result{}, err, true
+		return false, err
 	}
 
-	foundMcp := &mcfgv1.MachineConfigPool{}
-	err = r.Client.Get(context.TODO(), types.NamespacedName{Name: machinePool}, foundMcp)
-	if err != nil && k8serrors.IsNotFound(err) {
-		r.Log.Info("MachineConfigPool not found")
-		return reconcile.Result{Requeue: true, RequeueAfter: 15 * time.Second}, nil, false
+	/* Render the CRI-O runtime handlers of the additional RuntimeClasses */
+	isMcChanged, err := r.reconcileRuntimeHandlersMc(machinePool)
+	if err != nil {
+		return false, err
+	}
+
+	/* Render the hypervisor settings into a kata configuration drop-in */
+	isHypervisorMcChanged, err := r.reconcileHypervisorConfigMc(machinePool)
+	if err != nil {
+		return false, err
 	}
+	isMcChanged = isMcChanged || isHypervisorMcChanged
+
+	/* Enable or disable the debug mode */
+	isDebugMcChanged, err := r.reconcileDebugMc(machinePool)
+	if err != nil {
+		return false, err
+	}
+	isMcChanged = isMcChanged || isDebugMcChanged
 
 	/* Create Machine Config object to enable sandboxed containers RHCOS extension */
 	foundMc := &mcfgv1.MachineConfig{}
@@ -533,19 +834,16 @@ func (r *KataConfigOpenShiftReconciler) createExtensionMc(machinePool string) (c
 		err = r.Client.Create(context.TODO(), mc)
 		if err != nil {
 			r.Log.Error(err, "Failed to create a new MachineConfig ", "mc.Name", mc.Name)
-			return ctrl.Result{}, err, true
+			return false, err
 		}
-		/* mc created successfully - it will take a moment to finalize, requeue to create runtimeclass */
-		r.kataConfig.Status.InstallationStatus.IsInProgress = corev1.ConditionTrue
-		r.kataConfig.Status.BaseMcpGeneration = foundMcp.Status.ObservedGeneration
-		return ctrl.Result{Requeue: true}, nil, true
+		isMcChanged = true
 	}
-	return ctrl.Result{}, nil, false
+	return isMcChanged, nil
 }
 
 func (r *KataConfigOpenShiftReconciler) mapKataConfigToRequests(kataConfigObj client.Object) []reconcile.Request {
 
-	kataConfigList := &kataconfigurationv1.KataConfigList{}
+	kataConfigList := &kataconfigurationv2.KataConfigList{}
 
 	err := r.Client.List(context.TODO(), kataConfigList)
 	if err != nil {
@@ -564,8 +862,15 @@ func (r *KataConfigOpenShiftReconciler) mapKataConfigToRequests(kataConfigObj cl
 }
 
 func (r *KataConfigOpenShiftReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	clientset, err := kubernetes.NewForConfig(mgr.GetConfig())
+	if err != nil {
+		return err
+	}
+	r.clientset = clientset
+
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&kataconfigurationv1.KataConfig{}).
+		For(&kataconfigurationv2.KataConfig{}).
+		Owns(&appsv1.DaemonSet{}).
 		Watches(
 			&source.Kind{Type: &mcfgv1.MachineConfigPool{}},
 			handler.EnqueueRequestsFromMapFunc(r.mapKataConfigToRequests)).
@@ -573,15 +878,14 @@ func (r *KataConfigOpenShiftReconciler) SetupWithManager(mgr ctrl.Manager) error
 }
 
 func (r *KataConfigOpenShiftReconciler) getMcp() (*mcfgv1.MachineConfigPool, error) {
-	machinePool, err := r.getMcpName()
+	machinePools, err := r.getMcpNames()
 	if err != nil {
 		return nil, err
 	}
 
-	foundMcp := &mcfgv1.MachineConfigPool{}
-	err = r.Client.Get(context.TODO(), types.NamespacedName{Name: machinePool}, foundMcp)
+	foundMcp, err := r.getMachineConfigPool(machinePools)
 	if err != nil {
-		r.Log.Error(err, "Getting MachineConfigPool failed ", "machinePool", machinePool)
+		r.Log.Error(err, "Getting MachineConfigPool failed ", "mcp.Names", machinePools)
 		return nil, err
 	}
 
@@ -589,8 +893,25 @@ func (r *KataConfigOpenShiftReconciler) getMcp() (*mcfgv1.MachineConfigPool, err
 }
 
 func (r *KataConfigOpenShiftReconciler) getNodes() (error, *corev1.NodeList) {
+	if r.hasPoolNames() {
+		nodes, err := r.listNamedPoolsNodes()
+		if err != nil {
+			r.Log.Error(err, "Getting list of nodes failed")
+			return err, &corev1.NodeList{}
+		}
+		return nil, nodes
+	}
+
 	nodes := &corev1.NodeList{}
-	labelSelector := labels.SelectorFromSet(map[string]string{"node-role.kubernetes.io/worker": ""})
+	labelSelector := labels.SelectorFromSet(map[string]string{
+		"node-role.kubernetes.io/" + topologyPool(r.kataConfig.Status.ClusterTopology): ""})
+	if r.kataConfig.Spec.KataConfigPoolSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(r.kataConfig.Spec.KataConfigPoolSelector)
+		if err != nil {
+			return err, &corev1.NodeList{}
+		}
+		labelSelector = selector
+	}
 	listOpts := []client.ListOption{
 		client.MatchingLabelsSelector{Selector: labelSelector},
 	}
@@ -612,19 +933,20 @@ func (r *KataConfigOpenShiftReconciler) getConditionReason(conditions []mcfgv1.M
 	return ""
 }
 
-func (r *KataConfigOpenShiftReconciler) updateStatus(machinePool string) (*mcfgv1.MachineConfigPool, ctrl.Result, error, bool) {
+func (r *KataConfigOpenShiftReconciler) updateStatus(machinePools []string) (*mcfgv1.MachineConfigPool, ctrl.Result, error, bool) {
 	/* update KataConfig according to occurred error
 	 * We need to pull the status information from the machine config pool object
 	 */
-	foundMcp := &mcfgv1.MachineConfigPool{}
-	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: machinePool}, foundMcp)
+	foundMcp, err := r.getMachineConfigPool(machinePools)
 	if err != nil && k8serrors.IsNotFound(err) {
-		r.Log.Error(err, "Unable to get MachineConfigPool ", "machinePool", machinePool)
+		r.Log.Error(err, "Unable to get MachineConfigPool ", "mcp.Names", machinePools)
 		return nil, reconcile.Result{Requeue: true, RequeueAfter: 15 * time.Second}, nil, true
+	} else if err != nil {
+		return nil, reconcile.Result{}, err, false
 	}
 
 	/* installation status */
-	if corev1.ConditionTrue == r.kataConfig.Status.InstallationStatus.IsInProgress {
+	if r.kataConfig.Status.InstallationStatus.IsInProgress {
 		err, _ := r.updateInstallStatus()
 		if err != nil {
 			return foundMcp, reconcile.Result{Requeue: true, RequeueAfter: 15 * time.Second}, err, false
@@ -638,8 +960,23 @@ func (r *KataConfigOpenShiftReconciler) updateStatus(machinePool string) (*mcfgv
 		}
 	}
 
+	/* upgrade status */
+	if r.isUpgradeInProgress() {
+		err, _ := r.updateUpgradeStatus()
+		if err != nil {
+			return foundMcp, reconcile.Result{Requeue: true, RequeueAfter: 15 * time.Second}, err, false
+		}
+		if foundMcp.Status.DegradedMachineCount > 0 || mcfgv1.IsMachineConfigPoolConditionTrue(foundMcp.Status.Conditions,
+			mcfgv1.MachineConfigPoolDegraded) {
+			err, r.kataConfig.Status.UpgradeStatus.Failed = r.updateFailedStatus(r.kataConfig.Status.UpgradeStatus.Failed)
+			if err != nil {
+				return foundMcp, reconcile.Result{Requeue: true, RequeueAfter: 15 * time.Second}, err, false
+			}
+		}
+	}
+
 	/* uninstallation status */
-	if corev1.ConditionTrue == r.kataConfig.Status.UnInstallationStatus.InProgress.IsInProgress {
+	if r.kataConfig.Status.UnInstallationStatus.IsInProgress {
 		err, _ := r.updateUninstallStatus()
 		if err != nil {
 			return foundMcp, reconcile.Result{Requeue: true, RequeueAfter: 15 * time.Second}, err, false
@@ -675,8 +1012,8 @@ func (r *KataConfigOpenShiftReconciler) updateUninstallStatus() (error, bool) {
 				err, r.kataConfig.Status.UnInstallationStatus.Failed.FailedNodesList =
 					r.updateFailedNodes(&node, r.kataConfig.Status.UnInstallationStatus.Failed.FailedNodesList)
 			case "Working":
-				err, r.kataConfig.Status.UnInstallationStatus.InProgress.BinariesUnInstalledNodesList =
-					r.updateInProgressNodes(&node, r.kataConfig.Status.UnInstallationStatus.InProgress.BinariesUnInstalledNodesList)
+				err, r.kataConfig.Status.UnInstallationStatus.InProgress.InProgressNodesList =
+					r.updateInProgressNodes(&node, r.kataConfig.Status.UnInstallationStatus.InProgress.InProgressNodesList)
 			default:
 				err = fmt.Errorf("Invalid machineconfig state: %v ", annotation)
 				r.Log.Error(err, "Error updating Uninstall status")
@@ -692,23 +1029,27 @@ func (r *KataConfigOpenShiftReconciler) updateInProgressNodes(node *corev1.Node,
 		return err, inProgressList
 	}
 	if mcfgv1.IsMachineConfigPoolConditionTrue(foundMcp.Status.Conditions, mcfgv1.MachineConfigPoolUpdating) &&
-		r.kataConfig.Status.BaseMcpGeneration < foundMcp.Status.ObservedGeneration {
+		r.isNewMcpGenerationObserved(foundMcp) {
 		inProgressList = append(inProgressList, node.GetName())
 	}
 
 	return nil, inProgressList
 }
 
-func (r *KataConfigOpenShiftReconciler) updateCompletedNodes(node *corev1.Node, completedStatus kataconfigurationv1.KataConfigCompletedStatus) (error, kataconfigurationv1.KataConfigCompletedStatus) {
+func (r *KataConfigOpenShiftReconciler) updateCompletedNodes(node *corev1.Node, completedStatus kataconfigurationv2.KataConfigCompletedStatus) (error, kataconfigurationv2.KataConfigCompletedStatus) {
 	foundMcp, err := r.getMcp()
 	if err != nil {
 		return err, completedStatus
 	}
-	currentNodeConfig, ok := node.Annotations["machineconfiguration.openshift.io/currentConfig"]
-	if ok && foundMcp.Spec.Configuration.Name == currentNodeConfig &&
-		r.kataConfig.Status.BaseMcpGeneration < foundMcp.Status.ObservedGeneration &&
-		(r.kataConfig.Status.InstallationStatus.IsInProgress == corev1.ConditionTrue ||
-			r.kataConfig.Status.UnInstallationStatus.InProgress.IsInProgress == corev1.ConditionTrue) {
+	isOnPoolConfig, err := r.isNodeOnPoolConfig(node, foundMcp)
+	if err != nil {
+		return err, completedStatus
+	}
+	if isOnPoolConfig &&
+		r.isNewMcpGenerationObserved(foundMcp) &&
+		(r.kataConfig.Status.InstallationStatus.IsInProgress ||
+			r.kataConfig.Status.UnInstallationStatus.IsInProgress ||
+			r.isUpgradeInProgress()) {
 
 		completedStatus.CompletedNodesList = append(completedStatus.CompletedNodesList, node.GetName())
 		completedStatus.CompletedNodesCount = int(foundMcp.Status.UpdatedMachineCount)
@@ -718,7 +1059,7 @@ func (r *KataConfigOpenShiftReconciler) updateCompletedNodes(node *corev1.Node,
 }
 
 func (r *KataConfigOpenShiftReconciler) updateFailedNodes(node *corev1.Node,
-	failedList []kataconfigurationv1.FailedNodeStatus) (error, []kataconfigurationv1.FailedNodeStatus) {
+	failedList []kataconfigurationv2.FailedNodeStatus) (error, []kataconfigurationv2.FailedNodeStatus) {
 
 	foundMcp, err := r.getMcp()
 	if err != nil {
@@ -726,10 +1067,10 @@ func (r *KataConfigOpenShiftReconciler) updateFailedNodes(node *corev1.Node,
 	}
 	if (mcfgv1.IsMachineConfigPoolConditionTrue(foundMcp.Status.Conditions, mcfgv1.MachineConfigPoolNodeDegraded) ||
 		mcfgv1.IsMachineConfigPoolConditionTrue(foundMcp.Status.Conditions, mcfgv1.MachineConfigPoolDegraded)) &&
-		r.kataConfig.Status.BaseMcpGeneration < foundMcp.Status.ObservedGeneration {
+		r.isNewMcpGenerationObserved(foundMcp) {
 		failedList =
-			append(r.kataConfig.Status.InstallationStatus.Failed.FailedNodesList,
-				kataconfigurationv1.FailedNodeStatus{Name: node.GetName(),
+			append(failedList,
+				kataconfigurationv2.FailedNodeStatus{Name: node.GetName(),
 					Error: node.Annotations["machineconfiguration.openshift.io/reason"]})
 	}
 
@@ -755,8 +1096,8 @@ func (r *KataConfigOpenShiftReconciler) updateInstallStatus() (error, bool) {
 				err, r.kataConfig.Status.InstallationStatus.Failed.FailedNodesList =
 					r.updateFailedNodes(&node, r.kataConfig.Status.InstallationStatus.Failed.FailedNodesList)
 			case "Working":
-				err, r.kataConfig.Status.InstallationStatus.InProgress.BinariesInstalledNodesList =
-					r.updateInProgressNodes(&node, r.kataConfig.Status.InstallationStatus.InProgress.BinariesInstalledNodesList)
+				err, r.kataConfig.Status.InstallationStatus.InProgress.InProgressNodesList =
+					r.updateInProgressNodes(&node, r.kataConfig.Status.InstallationStatus.InProgress.InProgressNodesList)
 			default:
 				err = fmt.Errorf("Invalid machineconfig state: %v ", annotation)
 				r.Log.Error(err, "Error updating Install status")
@@ -766,7 +1107,7 @@ func (r *KataConfigOpenShiftReconciler) updateInstallStatus() (error, bool) {
 	return err, true
 }
 
-func (r *KataConfigOpenShiftReconciler) updateFailedStatus(status kataconfigurationv1.KataFailedNodeStatus) (error, kataconfigurationv1.KataFailedNodeStatus) {
+func (r *KataConfigOpenShiftReconciler) updateFailedStatus(status kataconfigurationv2.KataFailedNodeStatus) (error, kataconfigurationv2.KataFailedNodeStatus) {
 	foundMcp, err := r.getMcp()
 	if err != nil {
 		r.Log.Error(err, "couldn't get MachineConfigPool information")
@@ -790,7 +1131,7 @@ func (r *KataConfigOpenShiftReconciler) updateFailedStatus(status kataconfigurat
 func (r *KataConfigOpenShiftReconciler) clearInstallStatus() {
 	r.kataConfig.Status.InstallationStatus.Completed.CompletedNodesList = nil
 	r.kataConfig.Status.InstallationStatus.Completed.CompletedNodesCount = 0
-	r.kataConfig.Status.InstallationStatus.InProgress.BinariesInstalledNodesList = nil
+	r.kataConfig.Status.InstallationStatus.InProgress.InProgressNodesList = nil
 	r.kataConfig.Status.InstallationStatus.Failed.FailedNodesList = nil
 	r.kataConfig.Status.InstallationStatus.Failed.FailedReason = ""
 	r.kataConfig.Status.InstallationStatus.Failed.FailedNodesCount = 0
@@ -799,13 +1140,13 @@ func (r *KataConfigOpenShiftReconciler) clearInstallStatus() {
 func (r *KataConfigOpenShiftReconciler) clearUninstallStatus() {
 	r.kataConfig.Status.UnInstallationStatus.Completed.CompletedNodesList = nil
 	r.kataConfig.Status.UnInstallationStatus.Completed.CompletedNodesCount = 0
-	r.kataConfig.Status.UnInstallationStatus.InProgress.BinariesUnInstalledNodesList = nil
+	r.kataConfig.Status.UnInstallationStatus.InProgress.InProgressNodesList = nil
 	r.kataConfig.Status.UnInstallationStatus.Failed.FailedNodesList = nil
 	r.kataConfig.Status.UnInstallationStatus.Failed.FailedReason = ""
 	r.kataConfig.Status.UnInstallationStatus.Failed.FailedNodesCount = 0
 }
 
-func (r *KataConfigOpenShiftReconciler) clearFailedStatus(status kataconfigurationv1.KataFailedNodeStatus) kataconfigurationv1.KataFailedNodeStatus {
+func (r *KataConfigOpenShiftReconciler) clearFailedStatus(status kataconfigurationv2.KataFailedNodeStatus) kataconfigurationv2.KataFailedNodeStatus {
 	status.FailedNodesList = nil
 	status.FailedReason = ""
 	status.FailedNodesCount = 0
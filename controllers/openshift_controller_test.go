@@ -10,7 +10,7 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
-	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	kataconfigurationv2 "github.com/openshift/sandboxed-containers-operator/api/v2"
 	corev1 "k8s.io/api/core/v1"
 	nodeapi "k8s.io/api/node/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -20,15 +20,15 @@ import (
 
 var _ = Describe("OpenShift KataConfig Controller", func() {
 	Context("KataConfig create", func() {
-		It("Should not support multiple KataConfig CRs", func() {
+		It("Should not support multiple KataConfig CRs selecting the same nodes", func() {
 
 			const (
 				name = "example-kataconfig"
 			)
 
-			kataconfig := &kataconfigurationv1.KataConfig{
+			kataconfig := &kataconfigurationv2.KataConfig{
 				TypeMeta: metav1.TypeMeta{
-					APIVersion: "kataconfiguration.openshift.io/v1",
+					APIVersion: "kataconfiguration.openshift.io/v2",
 					Kind:       "KataConfig",
 				},
 				ObjectMeta: metav1.ObjectMeta{
@@ -40,9 +40,9 @@ var _ = Describe("OpenShift KataConfig Controller", func() {
 			Expect(k8sClient.Create(context.Background(), kataconfig)).Should(Succeed())
 			time.Sleep(time.Second * 5)
 
-			kataconfig2 := &kataconfigurationv1.KataConfig{
+			kataconfig2 := &kataconfigurationv2.KataConfig{
 				TypeMeta: metav1.TypeMeta{
-					APIVersion: "kataconfiguration.openshift.io/v1",
+					APIVersion: "kataconfiguration.openshift.io/v2",
 					Kind:       "KataConfig",
 				},
 				ObjectMeta: metav1.ObjectMeta{
@@ -75,15 +75,15 @@ var _ = Describe("OpenShift KataConfig Controller", func() {
 				name = "example-kataconfig"
 			)
 
-			kataconfig := &kataconfigurationv1.KataConfig{
+			kataconfig := &kataconfigurationv2.KataConfig{
 				TypeMeta: metav1.TypeMeta{
-					APIVersion: "kataconfiguration.openshift.io/v1",
+					APIVersion: "kataconfiguration.openshift.io/v2",
 					Kind:       "KataConfig",
 				},
 				ObjectMeta: metav1.ObjectMeta{
 					Name: name,
 				},
-				Spec: kataconfigurationv1.KataConfigSpec{
+				Spec: kataconfigurationv2.KataConfigSpec{
 					KataConfigPoolSelector: &metav1.LabelSelector{
 						MatchLabels: map[string]string{"kata": "true"},
 					},
@@ -94,15 +94,15 @@ var _ = Describe("OpenShift KataConfig Controller", func() {
 			Expect(k8sClient.Create(context.Background(), kataconfig)).Should(Succeed())
 			time.Sleep(time.Second * 5)
 
-			kataconfig2 := &kataconfigurationv1.KataConfig{
+			kataconfig2 := &kataconfigurationv2.KataConfig{
 				TypeMeta: metav1.TypeMeta{
-					APIVersion: "kataconfiguration.openshift.io/v1",
+					APIVersion: "kataconfiguration.openshift.io/v2",
 					Kind:       "KataConfig",
 				},
 				ObjectMeta: metav1.ObjectMeta{
 					Name: name + "2",
 				},
-				Spec: kataconfigurationv1.KataConfigSpec{
+				Spec: kataconfigurationv2.KataConfigSpec{
 					KataConfigPoolSelector: &metav1.LabelSelector{
 						MatchLabels: map[string]string{"kata": "true"},
 					},
@@ -112,6 +112,29 @@ var _ = Describe("OpenShift KataConfig Controller", func() {
 			Expect(k8sClient.Create(context.Background(), kataconfig2)).ShouldNot(Succeed())
 			time.Sleep(time.Second * 5)
 
+			By("Creating a KataConfig CR selecting other nodes successfully")
+			kataconfig3 := &kataconfigurationv2.KataConfig{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "kataconfiguration.openshift.io/v2",
+					Kind:       "KataConfig",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: name + "3",
+				},
+				Spec: kataconfigurationv2.KataConfigSpec{
+					KataConfigPoolSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{"kata-batch": "true"},
+					},
+				},
+			}
+			Expect(k8sClient.Create(context.Background(), kataconfig3)).Should(Succeed())
+
+			By("Naming the resources of the second KataConfig after it")
+			Eventually(func() string {
+				k8sClient.Get(context.Background(), types.NamespacedName{Name: kataconfig3.Name}, kataconfig3)
+				return kataconfig3.Status.PoolName
+			}, 10, time.Second).Should(Equal("kata-oc-" + kataconfig3.Name))
+
 			//Delete
 			By("Deleting KataConfig CR successfully")
 			kataConfigKey := types.NamespacedName{Name: kataconfig.Name}
@@ -119,6 +142,10 @@ var _ = Describe("OpenShift KataConfig Controller", func() {
 				k8sClient.Get(context.Background(), kataConfigKey, kataconfig)
 				return k8sClient.Delete(context.Background(), kataconfig)
 			}, 5, time.Second).Should(Succeed())
+			Eventually(func() error {
+				k8sClient.Get(context.Background(), types.NamespacedName{Name: kataconfig3.Name}, kataconfig3)
+				return k8sClient.Delete(context.Background(), kataconfig3)
+			}, 5, time.Second).Should(Succeed())
 
 		})
 	})
@@ -242,9 +269,9 @@ var _ = Describe("OpenShift KataConfig Controller", func() {
 			Expect(k8sClient.Status().Update(context.Background(), mcp)).Should(Succeed())
 
 			// Create KataConfig CR
-			kataConfig := &kataconfigurationv1.KataConfig{
+			kataConfig := &kataconfigurationv2.KataConfig{
 				TypeMeta: metav1.TypeMeta{
-					APIVersion: "kataconfiguration.openshift.io/v1",
+					APIVersion: "kataconfiguration.openshift.io/v2",
 					Kind:       "KataConfig",
 				},
 				ObjectMeta: metav1.ObjectMeta{
@@ -262,6 +289,51 @@ var _ = Describe("OpenShift KataConfig Controller", func() {
 
 			fmt.Fprintf(GinkgoWriter, "[DEBUG] kataConfig: %+v\n", kataConfig)
 
+			// Report the pre-flight check of worker0 as the DaemonSet pod would
+			operatorNs := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: operatorNamespace,
+				},
+			}
+
+			By("Creating the operator namespace successfully")
+			Expect(k8sClient.Create(context.Background(), operatorNs)).Should(Succeed())
+
+			preflightPod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      preflightName + "-worker0",
+					Namespace: operatorNamespace,
+					Labels:    map[string]string{"app": preflightName},
+				},
+				Spec: corev1.PodSpec{
+					NodeName:   "worker0",
+					Containers: []corev1.Container{{Name: "wait", Image: defaultPreflightImage}},
+				},
+			}
+
+			By("Creating the pre-flight check pod successfully")
+			Expect(k8sClient.Create(context.Background(), preflightPod)).Should(Succeed())
+
+			preflightPod.Status.InitContainerStatuses = []corev1.ContainerStatus{
+				{
+					Name: "check",
+					State: corev1.ContainerState{
+						Terminated: &corev1.ContainerStateTerminated{
+							Message: `{"kvm":true,"nestedVirtualization":false,"cpuVirtualizationFlags":["vmx"]}`,
+						},
+					},
+				},
+			}
+
+			By("Reporting the pre-flight check of worker0")
+			Expect(k8sClient.Status().Update(context.Background(), preflightPod)).Should(Succeed())
+
+			By("Checking worker0 is eligible")
+			Eventually(func() bool {
+				k8sClient.Get(context.Background(), types.NamespacedName{Name: kataConfig.Name}, kataConfig)
+				return countEligibleNodes(kataConfig.Status.NodeEligibility) == 1
+			}, timeout*3, interval).Should(BeTrue())
+
 			// Change node state to indicate Install in progress
 			By("Updating Node status")
 			nodeRet := &corev1.Node{}
@@ -301,7 +373,7 @@ var _ = Describe("OpenShift KataConfig Controller", func() {
 			Expect(k8sClient.Get(context.Background(), types.NamespacedName{Name: kataConfig.Name}, kataConfig)).Should(Succeed())
 
 			//TBD InProgressNodesCount is not updated
-			Expect(kataConfig.Status.InstallationStatus.InProgress.BinariesInstalledNodesList).Should(ContainElement("worker0"))
+			Expect(kataConfig.Status.InstallationStatus.InProgress.InProgressNodesList).Should(ContainElement("worker0"))
 
 			// Change node state to indicate Install complete
 			nodeRet = &corev1.Node{}
@@ -352,7 +424,7 @@ var _ = Describe("OpenShift KataConfig Controller", func() {
 
 			const (
 				name = "example-kataconfig"
-				//MCP pauses for 60sec during delete
+				//Uninstallation waits for the MCP to roll out
 				timeout  = time.Second * 70
 				interval = time.Second * 2
 			)
@@ -385,7 +457,7 @@ var _ = Describe("OpenShift KataConfig Controller", func() {
 
 			fmt.Fprintf(GinkgoWriter, "[DEBUG] MachineConfigPool: %+v\n", mcp)
 
-			kataConfig := &kataconfigurationv1.KataConfig{}
+			kataConfig := &kataconfigurationv2.KataConfig{}
 			Eventually(func() error {
 				return k8sClient.Get(context.Background(), types.NamespacedName{Name: "example-kataconfig"}, kataConfig)
 			}, timeout, interval).Should(Succeed())
@@ -430,8 +502,8 @@ var _ = Describe("OpenShift KataConfig Controller", func() {
 			Expect(k8sClient.Get(context.Background(), types.NamespacedName{Name: kataConfig.Name}, kataConfig)).Should(Succeed())
 
 			//TBD InProgressNodesCount is not updated
-			Expect(kataConfig.Status.InstallationStatus.InProgress.BinariesInstalledNodesList).Should(ContainElement("worker1"))
-			Expect(kataConfig.Status.InstallationStatus.InProgress.BinariesInstalledNodesList).ShouldNot(ContainElement("worker0"))
+			Expect(kataConfig.Status.InstallationStatus.InProgress.InProgressNodesList).Should(ContainElement("worker1"))
+			Expect(kataConfig.Status.InstallationStatus.InProgress.InProgressNodesList).ShouldNot(ContainElement("worker0"))
 
 			// Change node state to indicate Install complete
 			nodeRet = &corev1.Node{}
@@ -471,14 +543,14 @@ var _ = Describe("OpenShift KataConfig Controller", func() {
 			//Delete
 			By("Deleting KataConfig CR successfully")
 			Eventually(func() error {
-				kataConfig := &kataconfigurationv1.KataConfig{}
+				kataConfig := &kataconfigurationv2.KataConfig{}
 				k8sClient.Get(context.Background(), types.NamespacedName{Name: name}, kataConfig)
 				return k8sClient.Delete(context.Background(), kataConfig)
 			}, timeout, interval).Should(Succeed())
 
 			By("Expecting to not find KataConfig CR")
 			Eventually(func() error {
-				kataConfig := &kataconfigurationv1.KataConfig{}
+				kataConfig := &kataconfigurationv2.KataConfig{}
 				return k8sClient.Get(context.Background(), types.NamespacedName{Name: name}, kataConfig)
 			}, timeout, interval).ShouldNot(Succeed())
 
@@ -0,0 +1,297 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	ignTypes "github.com/coreos/ignition/v2/config/v3_2/types"
+	kataconfigurationv2 "github.com/openshift/sandboxed-containers-operator/api/v2"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	cloudAPIAdaptorName = "peer-pods-cloud-api-adaptor"
+
+	// cloudAPIAdaptorImageEnv overrides the image of cloud-api-adaptor. The deployment of the
+	// operator sets it to an image pinned by digest.
+	cloudAPIAdaptorImageEnv     = "CLOUD_API_ADAPTOR_IMAGE"
+	defaultCloudAPIAdaptorImage = "quay.io/confidential-containers/cloud-api-adaptor:latest"
+
+	// peerPodsSocketDir holds the socket the kata runtime reaches cloud-api-adaptor on
+	peerPodsSocketDir = "/run/peerpod"
+
+	// peerPodsConfigPath is the kata configuration of the kata-remote runtime handler
+	peerPodsConfigPath = "/etc/kata-containers/remote/configuration.toml"
+)
+
+// peerPodsKataConfig is the kata configuration handing the creation of the pod virtual machines
+// over to cloud-api-adaptor. The pod runs in a virtual machine of the provider, so the sandbox
+// resources and network of the node are not used.
+var peerPodsKataConfig = `[hypervisor.remote]
+remote_hypervisor_socket = "` + peerPodsSocketDir + `/hypervisor.sock"
+remote_hypervisor_timeout = 600
+disable_guest_selinux = true
+
+[agent.kata]
+
+[runtime]
+internetworking_model = "none"
+disable_new_netns = true
+disable_guest_seccomp = true
+sandbox_cgroup_only = false
+static_sandbox_resource_mgmt = true
+`
+
+// peerPodsOverhead is the pod overhead of the kata-remote RuntimeClass, only the shim and the
+// agent protocol forwarder run on the node
+var peerPodsOverhead = corev1.ResourceList{
+	corev1.ResourceCPU:    resource.MustParse("250m"),
+	corev1.ResourceMemory: resource.MustParse("120Mi"),
+}
+
+// CloudProvider creates the peer pod virtual machines on behalf of cloud-api-adaptor
+type CloudProvider interface {
+	// Name is the name cloud-api-adaptor knows the provider by
+	Name() string
+
+	// Validate checks the credentials Secret holds the settings the provider needs, and that
+	// the provider can be reached with them
+	Validate(secret *corev1.Secret) error
+
+	// AdaptorEnv returns the environment configuring cloud-api-adaptor for the provider, read
+	// from the credentials Secret
+	AdaptorEnv(secretName string) []corev1.EnvVar
+}
+
+// cloudProviders lists the supported providers by name
+var cloudProviders = map[string]func() CloudProvider{
+	libvirtProviderName: newLibvirtProvider,
+}
+
+// newCloudProvider returns the named provider
+func newCloudProvider(name string) (CloudProvider, error) {
+	newProvider, ok := cloudProviders[name]
+	if !ok {
+		return nil, fmt.Errorf("Unsupported peer pods provider %s", name)
+	}
+	return newProvider(), nil
+}
+
+// secretEnvVar returns an environment variable set from a key of the Secret
+func secretEnvVar(name string, secretName string, key string, optional bool) corev1.EnvVar {
+	return corev1.EnvVar{
+		Name: name,
+		ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+				Key:                  key,
+				Optional:             &optional,
+			},
+		},
+	}
+}
+
+// cloudAPIAdaptorImage returns the image of cloud-api-adaptor
+func cloudAPIAdaptorImage() string {
+	if image := os.Getenv(cloudAPIAdaptorImageEnv); image != "" {
+		return image
+	}
+	return defaultCloudAPIAdaptorImage
+}
+
+// peerPodsNodeSelector returns the selector of the nodes running cloud-api-adaptor, the nodes
+// the kata runtime is installed on
+func (r *KataConfigOpenShiftReconciler) peerPodsNodeSelector() *metav1.LabelSelector {
+	if selector := r.kataNodeSelector(); selector != nil {
+		return selector
+	}
+	return &metav1.LabelSelector{MatchLabels: map[string]string{
+		"node-role.kubernetes.io/" + topologyPool(r.kataConfig.Status.ClusterTopology): ""}}
+}
+
+// newCloudAPIAdaptorDaemonSet returns the DaemonSet running cloud-api-adaptor on the selected
+// nodes, where it serves the kata runtime on the peer pods socket
+func (r *KataConfigOpenShiftReconciler) newCloudAPIAdaptorDaemonSet(provider CloudProvider) *appsv1.DaemonSet {
+	name := r.instanceName(cloudAPIAdaptorName)
+	labels := map[string]string{"app": name}
+	privileged := true
+	automountToken := false
+	hostPathDirectoryOrCreate := corev1.HostPathDirectoryOrCreate
+
+	env := []corev1.EnvVar{{Name: "CLOUD_PROVIDER", Value: provider.Name()}}
+	env = append(env, provider.AdaptorEnv(r.kataConfig.Spec.PeerPods.CredentialsSecretName)...)
+
+	return &appsv1.DaemonSet{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "apps/v1",
+			Kind:       "DaemonSet",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: operatorNamespace,
+			Labels:    labels,
+		},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					HostNetwork: true,
+					/* cloud-api-adaptor reads its credentials from its environment, not from the API server */
+					AutomountServiceAccountToken: &automountToken,
+					Affinity: &corev1.Affinity{
+						NodeAffinity: &corev1.NodeAffinity{
+							RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+								NodeSelectorTerms: []corev1.NodeSelectorTerm{
+									selectorAsNodeSelectorTerm(r.peerPodsNodeSelector()),
+								},
+							},
+						},
+					},
+					Containers: []corev1.Container{{
+						Name:            "cloud-api-adaptor",
+						Image:           cloudAPIAdaptorImage(),
+						Command:         []string{"/usr/local/bin/entrypoint.sh"},
+						Env:             env,
+						SecurityContext: &corev1.SecurityContext{Privileged: &privileged},
+						VolumeMounts: []corev1.VolumeMount{
+							{Name: "pods-dir", MountPath: peerPodsSocketDir},
+						},
+					}},
+					Volumes: []corev1.Volume{
+						{Name: "pods-dir", VolumeSource: corev1.VolumeSource{
+							HostPath: &corev1.HostPathVolumeSource{Path: peerPodsSocketDir, Type: &hostPathDirectoryOrCreate}}},
+					},
+				},
+			},
+		},
+	}
+}
+
+// applyCloudAPIAdaptor creates the cloud-api-adaptor DaemonSet, or updates it if it changed
+func (r *KataConfigOpenShiftReconciler) applyCloudAPIAdaptor(ds *appsv1.DaemonSet) (*appsv1.DaemonSet, error) {
+	if err := controllerutil.SetControllerReference(r.kataConfig, ds, r.Scheme); err != nil {
+		return nil, err
+	}
+
+	foundDs := &appsv1.DaemonSet{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: ds.Name, Namespace: ds.Namespace}, foundDs)
+	if err != nil && k8serrors.IsNotFound(err) {
+		r.Log.Info("Creating the cloud-api-adaptor DaemonSet", "ds.Name", ds.Name)
+		return ds, r.Client.Create(context.TODO(), ds)
+	} else if err != nil {
+		return nil, err
+	}
+
+	if equality.Semantic.DeepDerivative(ds.Spec.Template.Spec, foundDs.Spec.Template.Spec) {
+		return foundDs, nil
+	}
+	r.Log.Info("Updating the cloud-api-adaptor DaemonSet", "ds.Name", ds.Name)
+	foundDs.Spec.Template = ds.Spec.Template
+	return foundDs, r.Client.Update(context.TODO(), foundDs)
+}
+
+// deleteCloudAPIAdaptor deletes the cloud-api-adaptor DaemonSet
+func (r *KataConfigOpenShiftReconciler) deleteCloudAPIAdaptor() error {
+	ds := &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      r.instanceName(cloudAPIAdaptorName),
+			Namespace: operatorNamespace,
+		},
+	}
+	err := r.Client.Delete(context.TODO(), ds)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// peerPodsRuntimeClass returns the RuntimeClass running pods as peer pods, as a variant sharing
+// the handler of the kata-remote CRI-O runtime handler
+func (r *KataConfigOpenShiftReconciler) peerPodsRuntimeClass() *kataconfigurationv2.RuntimeClassVariant {
+	return &kataconfigurationv2.RuntimeClassVariant{
+		Name:     r.kataConfig.GetPeerPodsRuntimeClassName(),
+		Handler:  kataconfigurationv2.PeerPodsRuntimeClassHandler,
+		Overhead: peerPodsOverhead,
+	}
+}
+
+// reconcilePeerPods deploys cloud-api-adaptor and creates the kata-remote RuntimeClass once the
+// credentials of the provider are validated, and reports the readiness of the peer pods mode.
+// Without peer pods, cloud-api-adaptor is removed; the RuntimeClass is removed with the other
+// RuntimeClasses no longer requested.
+func (r *KataConfigOpenShiftReconciler) reconcilePeerPods() error {
+	peerPods := r.kataConfig.Spec.PeerPods
+	if peerPods == nil {
+		r.kataConfig.Status.PeerPods = nil
+		return r.deleteCloudAPIAdaptor()
+	}
+
+	rc := r.peerPodsRuntimeClass()
+	status := &kataconfigurationv2.PeerPodsStatus{RuntimeClass: rc.Name}
+	r.kataConfig.Status.PeerPods = status
+
+	provider, err := newCloudProvider(peerPods.Provider)
+	if err != nil {
+		status.Message = err.Error()
+		return nil
+	}
+
+	secret := &corev1.Secret{}
+	err = r.Client.Get(context.TODO(), types.NamespacedName{Name: peerPods.CredentialsSecretName, Namespace: operatorNamespace}, secret)
+	if err != nil && k8serrors.IsNotFound(err) {
+		status.Message = fmt.Sprintf("The credentials Secret %s was not found in namespace %s",
+			peerPods.CredentialsSecretName, operatorNamespace)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if err = provider.Validate(secret); err != nil {
+		r.Log.Info("The peer pods provider credentials are not valid", "provider", provider.Name(), "err", err.Error())
+		status.Message = fmt.Sprintf("Invalid %s credentials: %v", provider.Name(), err)
+		return nil
+	}
+
+	ds, err := r.applyCloudAPIAdaptor(r.newCloudAPIAdaptorDaemonSet(provider))
+	if err != nil {
+		return err
+	}
+	if err = r.applyRuntimeClassVariant(rc); err != nil {
+		status.Message = err.Error()
+		return nil
+	}
+
+	if ds.Status.DesiredNumberScheduled == 0 || ds.Status.NumberReady < ds.Status.DesiredNumberScheduled {
+		status.Message = fmt.Sprintf("Waiting for cloud-api-adaptor to run on the selected nodes: %d of %d ready",
+			ds.Status.NumberReady, ds.Status.DesiredNumberScheduled)
+		return nil
+	}
+	status.Ready = true
+	return nil
+}
+
+// isPeerPodsRuntimeClass tells whether the RuntimeClass is the one of the peer pods mode
+func (r *KataConfigOpenShiftReconciler) isPeerPodsRuntimeClass(name string) bool {
+	return r.kataConfig.Spec.PeerPods != nil && name == r.kataConfig.GetPeerPodsRuntimeClassName()
+}
+
+// peerPodsFiles returns the CRI-O runtime handler and kata configuration of the kata-remote
+// RuntimeClass
+func (r *KataConfigOpenShiftReconciler) peerPodsFiles() []ignTypes.File {
+	if r.kataConfig.Spec.PeerPods == nil {
+		return nil
+	}
+	return []ignTypes.File{
+		newIgnitionFile(crioDropInDir+"/50-kata-"+kataconfigurationv2.PeerPodsRuntimeClassHandler,
+			renderCrioRuntimeHandler(kataconfigurationv2.PeerPodsRuntimeClassHandler, peerPodsConfigPath, nil)),
+		newIgnitionFile(peerPodsConfigPath, peerPodsKataConfig),
+	}
+}
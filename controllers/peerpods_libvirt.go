@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	libvirtProviderName = "libvirt"
+
+	// The keys of the credentials Secret of the libvirt provider
+	libvirtURIKey     = "LIBVIRT_URI"
+	libvirtNetworkKey = "LIBVIRT_NET"
+	libvirtPoolKey    = "LIBVIRT_POOL"
+
+	// libvirtDialTimeout is how long to wait for the libvirt daemon to accept a connection
+	libvirtDialTimeout = 5 * time.Second
+)
+
+// libvirtDefaultPorts are the ports the libvirt daemon listens on by remote transport
+var libvirtDefaultPorts = map[string]string{
+	"ssh": "22",
+	"tcp": "16509",
+	"tls": "16514",
+}
+
+// libvirtProvider creates the peer pod virtual machines with a libvirt daemon. The test:/// URIs
+// of the libvirt test driver stand in for a real hypervisor, e.g. test:///default.
+type libvirtProvider struct {
+	// dial connects to the libvirt daemon of remote URIs
+	dial func(network string, address string, timeout time.Duration) (net.Conn, error)
+}
+
+func newLibvirtProvider() CloudProvider {
+	return &libvirtProvider{dial: net.DialTimeout}
+}
+
+func (p *libvirtProvider) Name() string {
+	return libvirtProviderName
+}
+
+// Validate checks the libvirt URI of the Secret, and that the daemon of a remote URI accepts
+// connections. Local and test URIs are not checked from the operator, cloud-api-adaptor
+// connects to them from the nodes.
+func (p *libvirtProvider) Validate(secret *corev1.Secret) error {
+	rawURI := string(secret.Data[libvirtURIKey])
+	if rawURI == "" {
+		return fmt.Errorf("the Secret %s has no %s key", secret.Name, libvirtURIKey)
+	}
+	uri, err := url.Parse(rawURI)
+	if err != nil {
+		return fmt.Errorf("invalid %s %s: %v", libvirtURIKey, rawURI, err)
+	}
+
+	driver := uri.Scheme
+	transport := ""
+	if i := strings.Index(uri.Scheme, "+"); i >= 0 {
+		driver, transport = uri.Scheme[:i], uri.Scheme[i+1:]
+	}
+	switch driver {
+	case "test":
+		if uri.Path == "" {
+			return fmt.Errorf("invalid %s %s: the test driver needs a configuration, e.g. test:///default", libvirtURIKey, rawURI)
+		}
+		return nil
+	case "qemu":
+	default:
+		return fmt.Errorf("unsupported libvirt driver %s in %s, use qemu or test", driver, rawURI)
+	}
+
+	if transport == "" || transport == "unix" {
+		return nil
+	}
+	port, ok := libvirtDefaultPorts[transport]
+	if !ok {
+		return fmt.Errorf("unsupported libvirt transport %s in %s", transport, rawURI)
+	}
+	if uri.Hostname() == "" {
+		return fmt.Errorf("invalid %s %s: the %s transport needs a host", libvirtURIKey, rawURI, transport)
+	}
+	if uri.Port() != "" {
+		port = uri.Port()
+	}
+
+	conn, err := p.dial("tcp", net.JoinHostPort(uri.Hostname(), port), libvirtDialTimeout)
+	if err != nil {
+		return fmt.Errorf("cannot reach the libvirt daemon of %s: %v", rawURI, err)
+	}
+	return conn.Close()
+}
+
+func (p *libvirtProvider) AdaptorEnv(secretName string) []corev1.EnvVar {
+	return []corev1.EnvVar{
+		secretEnvVar(libvirtURIKey, secretName, libvirtURIKey, false),
+		secretEnvVar(libvirtNetworkKey, secretName, libvirtNetworkKey, true),
+		secretEnvVar(libvirtPoolKey, secretName, libvirtPoolKey, true),
+	}
+}
@@ -0,0 +1,158 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	kataconfigurationv2 "github.com/openshift/sandboxed-containers-operator/api/v2"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	nodeapi "k8s.io/api/node/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newLibvirtSecret(uri string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "libvirt-credentials", Namespace: operatorNamespace},
+		Data:       map[string][]byte{libvirtURIKey: []byte(uri)},
+	}
+}
+
+var _ = Describe("Peer pods", func() {
+	Context("Validating the libvirt credentials", func() {
+		var dialed string
+		var dialErr error
+		var provider *libvirtProvider
+
+		BeforeEach(func() {
+			dialed, dialErr = "", nil
+			provider = &libvirtProvider{dial: func(network string, address string, timeout time.Duration) (net.Conn, error) {
+				dialed = address
+				if dialErr != nil {
+					return nil, dialErr
+				}
+				server, client := net.Pipe()
+				server.Close()
+				return client, nil
+			}}
+		})
+
+		It("Should accept the libvirt test driver without connecting", func() {
+			Expect(provider.Validate(newLibvirtSecret("test:///default"))).To(Succeed())
+			Expect(dialed).Should(BeEmpty())
+		})
+
+		It("Should refuse a Secret without URI", func() {
+			Expect(provider.Validate(newLibvirtSecret(""))).ToNot(Succeed())
+		})
+
+		It("Should refuse an unsupported driver or transport", func() {
+			Expect(provider.Validate(newLibvirtSecret("xen:///system"))).ToNot(Succeed())
+			Expect(provider.Validate(newLibvirtSecret("qemu+libssh2://host/system"))).ToNot(Succeed())
+		})
+
+		It("Should connect to the daemon of a remote URI", func() {
+			Expect(provider.Validate(newLibvirtSecret("qemu+ssh://root@192.168.122.1/system"))).To(Succeed())
+			Expect(dialed).Should(Equal("192.168.122.1:22"))
+			Expect(provider.Validate(newLibvirtSecret("qemu+tcp://host:16000/system"))).To(Succeed())
+			Expect(dialed).Should(Equal("host:16000"))
+
+			dialErr = errors.New("connection refused")
+			Expect(provider.Validate(newLibvirtSecret("qemu+tls://host/system"))).ToNot(Succeed())
+			Expect(dialed).Should(Equal("host:16514"))
+		})
+	})
+
+	It("Should refuse an unknown provider", func() {
+		_, err := newCloudProvider("unknown")
+		Expect(err).To(HaveOccurred())
+	})
+
+	Context("Reconciling the peer pods mode", func() {
+		var c client.Client
+		var r *KataConfigOpenShiftReconciler
+
+		BeforeEach(func() {
+			scheme := runtime.NewScheme()
+			Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+			Expect(kataconfigurationv2.AddToScheme(scheme)).To(Succeed())
+			kataConfig := &kataconfigurationv2.KataConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: "example-kataconfig", UID: "uid"},
+				Spec: kataconfigurationv2.KataConfigSpec{
+					PeerPods: &kataconfigurationv2.PeerPodsConfig{
+						Provider:              libvirtProviderName,
+						CredentialsSecretName: "libvirt-credentials",
+					},
+				},
+				Status: kataconfigurationv2.KataConfigStatus{PoolName: "kata-oc"},
+			}
+			c = fake.NewClientBuilder().WithScheme(scheme).Build()
+			r = &KataConfigOpenShiftReconciler{
+				Client:     c,
+				Log:        ctrl.Log.WithName("test"),
+				Scheme:     scheme,
+				kataConfig: kataConfig,
+			}
+		})
+
+		It("Should wait for the credentials Secret", func() {
+			Expect(r.reconcilePeerPods()).To(Succeed())
+			Expect(r.kataConfig.Status.PeerPods.Ready).Should(BeFalse())
+			Expect(r.kataConfig.Status.PeerPods.Message).Should(ContainSubstring("libvirt-credentials was not found"))
+		})
+
+		It("Should deploy cloud-api-adaptor and the kata-remote RuntimeClass", func() {
+			Expect(c.Create(context.TODO(), newLibvirtSecret("test:///default"))).To(Succeed())
+			Expect(r.reconcilePeerPods()).To(Succeed())
+			Expect(r.kataConfig.Status.PeerPods.RuntimeClass).Should(Equal("kata-remote"))
+			Expect(r.kataConfig.Status.PeerPods.Ready).Should(BeFalse())
+
+			ds := &appsv1.DaemonSet{}
+			Expect(c.Get(context.TODO(), types.NamespacedName{Name: cloudAPIAdaptorName, Namespace: operatorNamespace}, ds)).To(Succeed())
+			container := ds.Spec.Template.Spec.Containers[0]
+			Expect(container.Env[0]).Should(Equal(corev1.EnvVar{Name: "CLOUD_PROVIDER", Value: "libvirt"}))
+			Expect(container.Env[1].ValueFrom.SecretKeyRef.Name).Should(Equal("libvirt-credentials"))
+			Expect(*ds.Spec.Template.Spec.AutomountServiceAccountToken).Should(BeFalse())
+
+			rc := &nodeapi.RuntimeClass{}
+			Expect(c.Get(context.TODO(), types.NamespacedName{Name: "kata-remote"}, rc)).To(Succeed())
+			Expect(rc.Handler).Should(Equal(kataconfigurationv2.PeerPodsRuntimeClassHandler))
+
+			By("Reporting ready once cloud-api-adaptor runs on the nodes")
+			ds.Status.DesiredNumberScheduled = 2
+			ds.Status.NumberReady = 2
+			Expect(c.Status().Update(context.TODO(), ds)).To(Succeed())
+			Expect(r.reconcilePeerPods()).To(Succeed())
+			Expect(r.kataConfig.Status.PeerPods.Ready).Should(BeTrue())
+
+			By("Removing cloud-api-adaptor when peer pods are disabled")
+			r.kataConfig.Spec.PeerPods = nil
+			Expect(r.reconcilePeerPods()).To(Succeed())
+			Expect(r.kataConfig.Status.PeerPods).Should(BeNil())
+			Expect(c.Get(context.TODO(), types.NamespacedName{Name: cloudAPIAdaptorName, Namespace: operatorNamespace}, ds)).ToNot(Succeed())
+		})
+
+		It("Should report invalid credentials", func() {
+			Expect(c.Create(context.TODO(), newLibvirtSecret("xen:///system"))).To(Succeed())
+			Expect(r.reconcilePeerPods()).To(Succeed())
+			Expect(r.kataConfig.Status.PeerPods.Message).Should(ContainSubstring("Invalid libvirt credentials"))
+		})
+
+		It("Should render the kata-remote runtime handler", func() {
+			files := r.peerPodsFiles()
+			Expect(files).Should(HaveLen(2))
+			Expect(files[0].Path).Should(Equal(crioDropInDir + "/50-kata-kata-remote"))
+			Expect(files[1].Path).Should(Equal(peerPodsConfigPath))
+		})
+	})
+})
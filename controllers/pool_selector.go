@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// nodesLeavingSelector returns the names of the nodes matched by the previous selector but
+// not by the current one
+func nodesLeavingSelector(nodes []corev1.Node, previous *metav1.LabelSelector, current *metav1.LabelSelector) ([]string, error) {
+	previousSelector, err := metav1.LabelSelectorAsSelector(previous)
+	if err != nil {
+		return nil, err
+	}
+	currentSelector, err := metav1.LabelSelectorAsSelector(current)
+	if err != nil {
+		return nil, err
+	}
+
+	var leaving []string
+	for _, node := range nodes {
+		nodeLabels := labels.Set(node.Labels)
+		if previousSelector.Matches(nodeLabels) && !currentSelector.Matches(nodeLabels) {
+			leaving = append(leaving, node.Name)
+		}
+	}
+	return leaving, nil
+}
+
+// hasLeftPool tells whether a node rolled out a configuration not rendered for any of the given pools
+func hasLeftPool(node *corev1.Node, machinePools []string) bool {
+	if node.Annotations["machineconfiguration.openshift.io/state"] != "Done" {
+		return false
+	}
+	currentConfig := node.Annotations["machineconfiguration.openshift.io/currentConfig"]
+	for _, machinePool := range machinePools {
+		if strings.HasPrefix(currentConfig, "rendered-"+machinePool+"-") {
+			return false
+		}
+	}
+	return true
+}
+
+// trackLeavingNodes records the nodes leaving the pool when the kataConfigPoolSelector changed,
+// and drops the ones that rolled back to the configuration of their original pool
+func (r *KataConfigOpenShiftReconciler) trackLeavingNodes(machinePools []string) error {
+	status := &r.kataConfig.Status
+	currentSelector := r.kataConfig.Spec.KataConfigPoolSelector
+
+	if status.PoolSelector != nil && !equality.Semantic.DeepEqual(status.PoolSelector, currentSelector) {
+		nodes := &corev1.NodeList{}
+		if err := r.Client.List(context.TODO(), nodes); err != nil {
+			return err
+		}
+		leaving, err := nodesLeavingSelector(nodes.Items, status.PoolSelector, currentSelector)
+		if err != nil {
+			return err
+		}
+		for _, nodeName := range leaving {
+			if !contains(status.LeavingNodes, nodeName) {
+				r.Log.Info("Node left the kataConfigPoolSelector", "node", nodeName)
+				status.LeavingNodes = append(status.LeavingNodes, nodeName)
+			}
+		}
+	}
+	status.PoolSelector = currentSelector.DeepCopy()
+
+	var stillLeaving []string
+	for _, nodeName := range status.LeavingNodes {
+		node := &corev1.Node{}
+		err := r.Client.Get(context.TODO(), types.NamespacedName{Name: nodeName}, node)
+		if err != nil && k8serrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if hasLeftPool(node, machinePools) {
+			r.Log.Info("Node rolled back to the configuration of its original pool", "node", nodeName)
+			r.Recorder.Event(r.kataConfig, corev1.EventTypeNormal, "NodeLeftPool",
+				fmt.Sprintf("The kata runtime was removed from node %s", nodeName))
+			continue
+		}
+		stillLeaving = append(stillLeaving, nodeName)
+	}
+	status.LeavingNodes = stillLeaving
+	return nil
+}
@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("KataConfigPoolSelector changes", func() {
+	nodes := []corev1.Node{
+		{ObjectMeta: metav1.ObjectMeta{Name: "worker0", Labels: map[string]string{"custom-kata1": "test"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "worker1", Labels: map[string]string{"custom-kata1": "test", "zone": "a"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "worker2", Labels: map[string]string{"zone": "a"}}},
+	}
+
+	It("Should find the nodes matched by the previous selector only", func() {
+		previous := &metav1.LabelSelector{MatchLabels: map[string]string{"custom-kata1": "test"}}
+		current := &metav1.LabelSelector{MatchLabels: map[string]string{"zone": "a"}}
+
+		leaving, err := nodesLeavingSelector(nodes, previous, current)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(leaving).Should(Equal([]string{"worker0"}))
+	})
+
+	It("Should find no node when the selector is extended", func() {
+		previous := &metav1.LabelSelector{MatchLabels: map[string]string{"custom-kata1": "test", "zone": "a"}}
+		current := &metav1.LabelSelector{MatchLabels: map[string]string{"custom-kata1": "test"}}
+
+		leaving, err := nodesLeavingSelector(nodes, previous, current)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(leaving).Should(BeEmpty())
+	})
+
+	It("Should tell when a node rolled out the configuration of its original pool", func() {
+		node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+			"machineconfiguration.openshift.io/currentConfig": "rendered-kata-oc-1234",
+			"machineconfiguration.openshift.io/state":         "Done",
+		}}}
+		Expect(hasLeftPool(node, []string{"kata-oc"})).Should(BeFalse())
+
+		node.Annotations["machineconfiguration.openshift.io/currentConfig"] = "rendered-worker-5678"
+		node.Annotations["machineconfiguration.openshift.io/state"] = "Working"
+		Expect(hasLeftPool(node, []string{"kata-oc"})).Should(BeFalse())
+
+		node.Annotations["machineconfiguration.openshift.io/state"] = "Done"
+		Expect(hasLeftPool(node, []string{"kata-oc"})).Should(BeTrue())
+	})
+})
@@ -0,0 +1,324 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	kataconfigurationv2 "github.com/openshift/sandboxed-containers-operator/api/v2"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// operatorNamespace is the namespace the operator and its workloads run in
+	operatorNamespace = "openshift-sandboxed-containers-operator"
+
+	preflightName = "sandboxed-containers-preflight"
+
+	// preflightImageEnv overrides the image running the pre-flight check. The deployment of the
+	// operator sets it to an image pinned by digest, the default is only used outside of it.
+	preflightImageEnv     = "PREFLIGHT_IMAGE"
+	defaultPreflightImage = "registry.access.redhat.com/ubi8/ubi-minimal:latest"
+
+	// preflightTimeout is how long to wait for the pre-flight check before considering the
+	// nodes that did not report as not eligible
+	preflightTimeout = 5 * time.Minute
+
+	// eligibleNodeLabel is set on the checked nodes to tell whether they can run the kata runtime
+	eligibleNodeLabel = "kataconfiguration.openshift.io/kata-eligible"
+)
+
+// preflightScript checks the virtualization capabilities of the node and reports them as
+// the termination message of the container
+const preflightScript = `kvm=false
+[ -c /host/dev/kvm ] && kvm=true
+flags=""
+for flag in vmx svm sie; do
+  grep -qw "$flag" /proc/cpuinfo && flags="$flags\"$flag\","
+done
+nested=false
+for param in /host/sys/module/kvm_intel/parameters/nested /host/sys/module/kvm_amd/parameters/nested; do
+  [ -f "$param" ] && grep -q -e Y -e 1 "$param" && nested=true
+done
+echo "{\"kvm\":$kvm,\"nestedVirtualization\":$nested,\"cpuVirtualizationFlags\":[${flags%,}]}" > /dev/termination-log
+`
+
+// preflightResult is the report of the pre-flight check on a node
+type preflightResult struct {
+	KVM                    bool     `json:"kvm"`
+	NestedVirtualization   bool     `json:"nestedVirtualization"`
+	CPUVirtualizationFlags []string `json:"cpuVirtualizationFlags"`
+}
+
+// parsePreflightResult returns the eligibility of a node from the report of the pre-flight check
+func parsePreflightResult(nodeName string, message string) kataconfigurationv2.NodeEligibilityStatus {
+	status := kataconfigurationv2.NodeEligibilityStatus{Name: nodeName}
+
+	result := preflightResult{}
+	if err := json.Unmarshal([]byte(message), &result); err != nil {
+		status.Message = fmt.Sprintf("Invalid pre-flight check report: %v", err)
+		return status
+	}
+
+	status.KVM = result.KVM
+	status.NestedVirtualization = result.NestedVirtualization
+	status.CPUVirtualizationFlags = result.CPUVirtualizationFlags
+	status.Eligible = result.KVM
+	if !status.Eligible {
+		status.Message = "/dev/kvm is not present on the node"
+	}
+	return status
+}
+
+func preflightImage() string {
+	if image := os.Getenv(preflightImageEnv); image != "" {
+		return image
+	}
+	return defaultPreflightImage
+}
+
+// selectorAsNodeSelectorTerm converts a label selector to the equivalent node affinity term
+func selectorAsNodeSelectorTerm(selector *metav1.LabelSelector) corev1.NodeSelectorTerm {
+	term := corev1.NodeSelectorTerm{}
+	for key, value := range selector.MatchLabels {
+		term.MatchExpressions = append(term.MatchExpressions, corev1.NodeSelectorRequirement{
+			Key:      key,
+			Operator: corev1.NodeSelectorOpIn,
+			Values:   []string{value},
+		})
+	}
+	for _, expression := range selector.MatchExpressions {
+		term.MatchExpressions = append(term.MatchExpressions, corev1.NodeSelectorRequirement{
+			Key:      expression.Key,
+			Operator: corev1.NodeSelectorOperator(expression.Operator),
+			Values:   expression.Values,
+		})
+	}
+	return term
+}
+
+// newPreflightDaemonSet returns the DaemonSet running the pre-flight check on the selected nodes.
+// The check runs in an init container, the main container only keeps the pod around until
+// the report is collected.
+func (r *KataConfigOpenShiftReconciler) newPreflightDaemonSet() *appsv1.DaemonSet {
+	labels := map[string]string{"app": r.instanceName(preflightName)}
+	privileged := true
+	automountToken := false
+	hostPathDirectory := corev1.HostPathDirectory
+
+	ds := &appsv1.DaemonSet{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "apps/v1",
+			Kind:       "DaemonSet",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      r.instanceName(preflightName),
+			Namespace: operatorNamespace,
+			Labels:    labels,
+		},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					Affinity: &corev1.Affinity{
+						NodeAffinity: &corev1.NodeAffinity{
+							RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+								NodeSelectorTerms: []corev1.NodeSelectorTerm{
+									selectorAsNodeSelectorTerm(r.kataNodeSelector()),
+								},
+							},
+						},
+					},
+					/* The report is collected from the termination message, not written to the API server */
+					AutomountServiceAccountToken: &automountToken,
+					Tolerations:                  []corev1.Toleration{{Operator: corev1.TolerationOpExists}},
+					InitContainers: []corev1.Container{{
+						Name:            "check",
+						Image:           preflightImage(),
+						Command:         []string{"/bin/sh", "-c", preflightScript},
+						SecurityContext: &corev1.SecurityContext{Privileged: &privileged},
+						VolumeMounts: []corev1.VolumeMount{
+							{Name: "dev", MountPath: "/host/dev", ReadOnly: true},
+							{Name: "modules", MountPath: "/host/sys/module", ReadOnly: true},
+						},
+					}},
+					Containers: []corev1.Container{{
+						Name:    "wait",
+						Image:   preflightImage(),
+						Command: []string{"/bin/sh", "-c", "sleep infinity"},
+					}},
+					Volumes: []corev1.Volume{
+						{Name: "dev", VolumeSource: corev1.VolumeSource{
+							HostPath: &corev1.HostPathVolumeSource{Path: "/dev", Type: &hostPathDirectory}}},
+						{Name: "modules", VolumeSource: corev1.VolumeSource{
+							HostPath: &corev1.HostPathVolumeSource{Path: "/sys/module", Type: &hostPathDirectory}}},
+					},
+				},
+			},
+		},
+	}
+
+	return ds
+}
+
+// runPreflight checks the selected nodes are able to run the kata runtime. It only checks the
+// nodes without a result, so that the nodes joining the selection after the first check are
+// checked too, and drops the results of the nodes that left it. It returns true once every
+// selected node reported its eligibility.
+func (r *KataConfigOpenShiftReconciler) runPreflight() (bool, error) {
+	selector, err := metav1.LabelSelectorAsSelector(r.kataNodeSelector())
+	if err != nil {
+		return false, err
+	}
+	nodes := &corev1.NodeList{}
+	if err = r.Client.List(context.TODO(), nodes, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return false, err
+	}
+	if len(nodes.Items) == 0 {
+		r.Log.Info("Waiting for nodes to be selected for the pre-flight check")
+		return false, nil
+	}
+
+	previous := map[string]kataconfigurationv2.NodeEligibilityStatus{}
+	for _, result := range r.kataConfig.Status.NodeEligibility {
+		previous[result.Name] = result
+	}
+	var results []kataconfigurationv2.NodeEligibilityStatus
+	var pending []corev1.Node
+	for _, node := range nodes.Items {
+		if result, ok := previous[node.Name]; ok {
+			results = append(results, result)
+		} else {
+			pending = append(pending, node)
+		}
+	}
+	r.kataConfig.Status.NodeEligibility = results
+	if len(pending) == 0 {
+		return true, nil
+	}
+
+	ds := &appsv1.DaemonSet{}
+	err = r.Client.Get(context.TODO(), types.NamespacedName{Name: r.instanceName(preflightName), Namespace: operatorNamespace}, ds)
+	if err != nil && k8serrors.IsNotFound(err) {
+		ds = r.newPreflightDaemonSet()
+		if err = controllerutil.SetControllerReference(r.kataConfig, ds, r.Scheme); err != nil {
+			return false, err
+		}
+		r.Log.Info("Creating the pre-flight check DaemonSet", "ds.Name", ds.Name)
+		return false, r.Client.Create(context.TODO(), ds)
+	} else if err != nil {
+		return false, err
+	}
+
+	pods := &corev1.PodList{}
+	if err = r.Client.List(context.TODO(), pods, client.InNamespace(operatorNamespace),
+		client.MatchingLabels{"app": r.instanceName(preflightName)}); err != nil {
+		return false, err
+	}
+	reports := map[string]string{}
+	for _, pod := range pods.Items {
+		for _, initStatus := range pod.Status.InitContainerStatuses {
+			if initStatus.State.Terminated != nil && initStatus.State.Terminated.Message != "" {
+				reports[pod.Spec.NodeName] = initStatus.State.Terminated.Message
+			}
+		}
+	}
+
+	timedOut := time.Since(ds.CreationTimestamp.Time) > preflightTimeout
+	var newResults []kataconfigurationv2.NodeEligibilityStatus
+	for _, node := range pending {
+		report, ok := reports[node.Name]
+		if !ok && !timedOut {
+			r.Log.Info("Waiting for the pre-flight check", "node", node.Name)
+			return false, nil
+		}
+		if !ok {
+			newResults = append(newResults, kataconfigurationv2.NodeEligibilityStatus{
+				Name:    node.Name,
+				Message: "The pre-flight check did not complete on the node",
+			})
+			continue
+		}
+		newResults = append(newResults, parsePreflightResult(node.Name, report))
+	}
+
+	for _, result := range newResults {
+		if err = r.labelNodeEligibility(result.Name, result.Eligible); err != nil {
+			return false, err
+		}
+	}
+
+	r.Log.Info("Pre-flight check completed, deleting its DaemonSet", "ds.Name", ds.Name)
+	if err = r.Client.Delete(context.TODO(), ds); err != nil && !k8serrors.IsNotFound(err) {
+		return false, err
+	}
+
+	r.kataConfig.Status.NodeEligibility = append(results, newResults...)
+	return true, nil
+}
+
+// labelNodeEligibility labels a node with the result of its pre-flight check
+func (r *KataConfigOpenShiftReconciler) labelNodeEligibility(nodeName string, eligible bool) error {
+	node := &corev1.Node{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: nodeName}, node); err != nil {
+		return err
+	}
+
+	value := strconv.FormatBool(eligible)
+	if node.Labels[eligibleNodeLabel] == value {
+		return nil
+	}
+	patch := client.MergeFrom(node.DeepCopy())
+	if node.Labels == nil {
+		node.Labels = map[string]string{}
+	}
+	node.Labels[eligibleNodeLabel] = value
+	return r.Client.Patch(context.TODO(), node, patch)
+}
+
+// removeNodeLabels removes the labels set by the pre-flight check and on the nodes of the named
+// pools from the selected nodes
+func (r *KataConfigOpenShiftReconciler) removeNodeLabels() error {
+	err, nodes := r.getNodes()
+	if err != nil {
+		return err
+	}
+
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		_, isEligibilityLabelled := node.Labels[eligibleNodeLabel]
+		_, isRuntimeLabelled := node.Labels[kataRuntimeNodeLabel]
+		if !isEligibilityLabelled && !isRuntimeLabelled {
+			continue
+		}
+		patch := client.MergeFrom(node.DeepCopy())
+		delete(node.Labels, eligibleNodeLabel)
+		delete(node.Labels, kataRuntimeNodeLabel)
+		r.Log.Info("Removing the labels of the operator", "node", node.Name)
+		if err := r.Client.Patch(context.TODO(), node, patch); err != nil && !k8serrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// countEligibleNodes returns the number of nodes the pre-flight check found eligible
+func countEligibleNodes(results []kataconfigurationv2.NodeEligibilityStatus) int {
+	count := 0
+	for _, result := range results {
+		if result.Eligible {
+			count++
+		}
+	}
+	return count
+}
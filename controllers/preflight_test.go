@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	kataconfigurationv2 "github.com/openshift/sandboxed-containers-operator/api/v2"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Pre-flight check", func() {
+	It("Should consider a node with KVM eligible", func() {
+		result := parsePreflightResult("worker0",
+			`{"kvm":true,"nestedVirtualization":true,"cpuVirtualizationFlags":["vmx"]}`)
+		Expect(result.Name).Should(Equal("worker0"))
+		Expect(result.Eligible).Should(BeTrue())
+		Expect(result.NestedVirtualization).Should(BeTrue())
+		Expect(result.CPUVirtualizationFlags).Should(ConsistOf("vmx"))
+		Expect(result.Message).Should(BeEmpty())
+	})
+
+	It("Should consider a node without KVM not eligible", func() {
+		result := parsePreflightResult("worker1", `{"kvm":false,"nestedVirtualization":false,"cpuVirtualizationFlags":[]}`)
+		Expect(result.Eligible).Should(BeFalse())
+		Expect(result.Message).ShouldNot(BeEmpty())
+	})
+
+	It("Should consider a node with an invalid report not eligible", func() {
+		result := parsePreflightResult("worker2", "sh: grep: command not found")
+		Expect(result.Eligible).Should(BeFalse())
+		Expect(result.Message).Should(ContainSubstring("Invalid pre-flight check report"))
+	})
+
+	It("Should schedule on the nodes selected by the KataConfig", func() {
+		selector := &metav1.LabelSelector{
+			MatchLabels: map[string]string{"node-role.kubernetes.io/worker": ""},
+			MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "zone", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"edge"}},
+			},
+		}
+		term := selectorAsNodeSelectorTerm(selector)
+		Expect(term.MatchExpressions).Should(ConsistOf(
+			corev1.NodeSelectorRequirement{Key: "node-role.kubernetes.io/worker",
+				Operator: corev1.NodeSelectorOpIn, Values: []string{""}},
+			corev1.NodeSelectorRequirement{Key: "zone",
+				Operator: corev1.NodeSelectorOpNotIn, Values: []string{"edge"}},
+		))
+	})
+
+	It("Should not give the privileged pre-flight pods access to the API server", func() {
+		r := &KataConfigOpenShiftReconciler{kataConfig: &kataconfigurationv2.KataConfig{
+			Spec: kataconfigurationv2.KataConfigSpec{KataConfigPoolSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"node-role.kubernetes.io/worker": ""},
+			}},
+		}}
+		ds := r.newPreflightDaemonSet()
+		Expect(*ds.Spec.Template.Spec.AutomountServiceAccountToken).Should(BeFalse())
+	})
+
+	It("Should check the nodes joining the selection and forget the ones that left it", func() {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(kataconfigurationv2.AddToScheme(scheme)).To(Succeed())
+		workerLabels := map[string]string{"node-role.kubernetes.io/worker": ""}
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "worker0", Labels: workerLabels}},
+			&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "worker1", Labels: workerLabels}},
+		).Build()
+		r := &KataConfigOpenShiftReconciler{
+			Client: c,
+			Log:    ctrl.Log.WithName("test"),
+			Scheme: scheme,
+			kataConfig: &kataconfigurationv2.KataConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: "example-kataconfig", UID: "uid"},
+				Spec: kataconfigurationv2.KataConfigSpec{
+					KataConfigPoolSelector: &metav1.LabelSelector{MatchLabels: workerLabels},
+				},
+				Status: kataconfigurationv2.KataConfigStatus{
+					NodeEligibility: []kataconfigurationv2.NodeEligibilityStatus{
+						{Name: "worker0", Eligible: true, KVM: true},
+						{Name: "worker9", Eligible: true, KVM: true},
+					},
+				},
+			},
+		}
+
+		isDone, err := r.runPreflight()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(isDone).Should(BeFalse())
+		Expect(r.kataConfig.Status.NodeEligibility).Should(HaveLen(1))
+		Expect(r.kataConfig.Status.NodeEligibility[0].Name).Should(Equal("worker0"))
+		dsName := types.NamespacedName{Name: r.instanceName(preflightName), Namespace: operatorNamespace}
+		Expect(c.Get(context.TODO(), dsName, &appsv1.DaemonSet{})).To(Succeed())
+
+		By("Recording the report of the new node")
+		Expect(c.Create(context.TODO(), &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "preflight-worker1", Namespace: operatorNamespace,
+				Labels: map[string]string{"app": r.instanceName(preflightName)}},
+			Spec: corev1.PodSpec{NodeName: "worker1"},
+			Status: corev1.PodStatus{InitContainerStatuses: []corev1.ContainerStatus{{
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Message: `{"kvm":true}`}},
+			}}},
+		})).To(Succeed())
+		isDone, err = r.runPreflight()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(isDone).Should(BeTrue())
+		Expect(r.kataConfig.Status.NodeEligibility).Should(HaveLen(2))
+		Expect(r.kataConfig.Status.NodeEligibility[1].Name).Should(Equal("worker1"))
+		Expect(r.kataConfig.Status.NodeEligibility[1].Eligible).Should(BeTrue())
+		node := &corev1.Node{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: "worker1"}, node)).To(Succeed())
+		Expect(node.Labels[eligibleNodeLabel]).Should(Equal("true"))
+		Expect(c.Get(context.TODO(), dsName, &appsv1.DaemonSet{})).ToNot(Succeed())
+	})
+
+	It("Should count the eligible nodes", func() {
+		Expect(countEligibleNodes([]kataconfigurationv2.NodeEligibilityStatus{
+			{Name: "worker0", Eligible: true},
+			{Name: "worker1", Eligible: false},
+			{Name: "worker2", Eligible: true},
+		})).Should(Equal(2))
+	})
+})
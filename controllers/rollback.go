@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	kataconfigurationv2 "github.com/openshift/sandboxed-containers-operator/api/v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// rollbackReason returns why a degraded installation has to be rolled back according to the
+// failure policy of the KataConfig, or an empty string when it does not
+func rollbackReason(kataConfig *kataconfigurationv2.KataConfig, mcp *mcfgv1.MachineConfigPool, now time.Time) string {
+	if kataConfig.Spec.GetFailurePolicy() != kataconfigurationv2.FailurePolicyRollback ||
+		!kataConfig.Status.InstallationStatus.IsInProgress || kataConfig.Status.RuntimeClass != "" {
+		return ""
+	}
+
+	var degradedSince *metav1.Time
+	for _, conditionType := range []mcfgv1.MachineConfigPoolConditionType{
+		mcfgv1.MachineConfigPoolNodeDegraded, mcfgv1.MachineConfigPoolDegraded} {
+		condition := mcfgv1.GetMachineConfigPoolCondition(mcp.Status, conditionType)
+		if condition != nil && condition.Status == corev1.ConditionTrue &&
+			(degradedSince == nil || condition.LastTransitionTime.Before(degradedSince)) {
+			degradedSince = &condition.LastTransitionTime
+		}
+	}
+	degradedNodes := mcp.Status.DegradedMachineCount
+	if degradedSince == nil && degradedNodes == 0 {
+		return ""
+	}
+
+	threshold := kataConfig.Spec.RollbackThreshold
+	if threshold == nil || (threshold.DegradedNodes == nil && threshold.Timeout == nil) {
+		return fmt.Sprintf("%d nodes of the pool %s are degraded", degradedNodes, mcp.Name)
+	}
+	if threshold.DegradedNodes != nil && degradedNodes >= *threshold.DegradedNodes {
+		return fmt.Sprintf("%d nodes of the pool %s are degraded, the threshold is %d",
+			degradedNodes, mcp.Name, *threshold.DegradedNodes)
+	}
+	if threshold.Timeout != nil && degradedSince != nil && now.Sub(degradedSince.Time) > threshold.Timeout.Duration {
+		return fmt.Sprintf("The pool %s is degraded for more than %v", mcp.Name, threshold.Timeout.Duration)
+	}
+	return ""
+}
+
+// startRollback deletes the MachineConfigs installing the kata runtime so that the pool
+// recovers from a degraded installation
+func (r *KataConfigOpenShiftReconciler) startRollback(mcp *mcfgv1.MachineConfigPool, reason string) (ctrl.Result, error) {
+	r.Log.Info("Rolling back the installation", "reason", reason)
+	r.Recorder.Event(r.kataConfig, corev1.EventTypeWarning, "RollbackStarted", reason)
+
+	for _, mcName := range r.kataMcNames() {
+		if err := r.deleteMc(mcName); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	r.kataConfig.Status.Rollback = &kataconfigurationv2.RollbackStatus{
+		Phase:              kataconfigurationv2.RollbackPhaseInProgress,
+		Reason:             reason,
+		StartTime:          metav1.Now(),
+		ObservedGeneration: r.kataConfig.Generation,
+	}
+	r.kataConfig.Status.InstallationStatus.IsInProgress = false
+	r.setBaseMcpGeneration(mcp)
+	return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, nil
+}
+
+// processRollback waits for the pool to recover from the rollback, then halts the installation
+// until the KataConfig is changed. It returns true when the installation can be retried.
+func (r *KataConfigOpenShiftReconciler) processRollback(machinePools []string) (ctrl.Result, bool, error) {
+	rollback := r.kataConfig.Status.Rollback
+	if rollback.ObservedGeneration != r.kataConfig.Generation {
+		r.Log.Info("The KataConfig changed since the rollback, installing the kata runtime again")
+		r.kataConfig.Status.Rollback = nil
+		return ctrl.Result{}, true, nil
+	}
+
+	if rollback.Phase == kataconfigurationv2.RollbackPhaseCompleted {
+		r.Log.Info("The installation was rolled back, waiting for the KataConfig to be changed", "reason", rollback.Reason)
+		return ctrl.Result{}, false, nil
+	}
+
+	mcp, err := r.getMachineConfigPool(machinePools)
+	if err != nil {
+		return ctrl.Result{}, false, err
+	}
+
+	if !r.isNewMcpGenerationObserved(mcp) ||
+		isAnyMcInConfiguration(r.kataMcNames(), mcp.Status.Configuration) ||
+		!mcfgv1.IsMachineConfigPoolConditionTrue(mcp.Status.Conditions, mcfgv1.MachineConfigPoolUpdated) ||
+		mcp.Status.DegradedMachineCount > 0 || mcp.Status.ReadyMachineCount != mcp.Status.MachineCount {
+		r.Log.Info("Waiting for the MachineConfigPool to recover from the rollback", "mcp.Names", machinePools)
+		return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, false, nil
+	}
+
+	r.Log.Info("Rollback completed", "mcp.Names", machinePools)
+	r.Recorder.Event(r.kataConfig, corev1.EventTypeNormal, "RollbackCompleted",
+		fmt.Sprintf("The pools %s recovered from the rollback of the installation", strings.Join(machinePools, ", ")))
+	rollback.Phase = kataconfigurationv2.RollbackPhaseCompleted
+	return ctrl.Result{}, false, nil
+}
@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	kataconfigurationv2 "github.com/openshift/sandboxed-containers-operator/api/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Rollback of a degraded installation", func() {
+	var kataConfig *kataconfigurationv2.KataConfig
+	var mcp *mcfgv1.MachineConfigPool
+	now := time.Now()
+
+	BeforeEach(func() {
+		kataConfig = &kataconfigurationv2.KataConfig{
+			Spec: kataconfigurationv2.KataConfigSpec{
+				FailurePolicy: kataconfigurationv2.FailurePolicyRollback,
+			},
+		}
+		kataConfig.Status.InstallationStatus.IsInProgress = true
+
+		mcp = &mcfgv1.MachineConfigPool{
+			ObjectMeta: metav1.ObjectMeta{Name: "kata-oc"},
+			Status: mcfgv1.MachineConfigPoolStatus{
+				MachineCount:         3,
+				DegradedMachineCount: 1,
+				Conditions: []mcfgv1.MachineConfigPoolCondition{{
+					Type:               mcfgv1.MachineConfigPoolNodeDegraded,
+					Status:             corev1.ConditionTrue,
+					LastTransitionTime: metav1.NewTime(now.Add(-10 * time.Minute)),
+				}},
+			},
+		}
+	})
+
+	It("Should not roll back with the default failure policy", func() {
+		kataConfig.Spec.FailurePolicy = ""
+		Expect(rollbackReason(kataConfig, mcp, now)).Should(BeEmpty())
+	})
+
+	It("Should roll back as soon as a node is degraded without threshold", func() {
+		Expect(rollbackReason(kataConfig, mcp, now)).Should(ContainSubstring("1 nodes of the pool kata-oc are degraded"))
+	})
+
+	It("Should not roll back once the kata runtime is installed", func() {
+		kataConfig.Status.RuntimeClass = "kata"
+		Expect(rollbackReason(kataConfig, mcp, now)).Should(BeEmpty())
+	})
+
+	It("Should roll back when the number of degraded nodes reaches the threshold", func() {
+		degradedNodes := int32(2)
+		kataConfig.Spec.RollbackThreshold = &kataconfigurationv2.RollbackThreshold{DegradedNodes: &degradedNodes}
+		Expect(rollbackReason(kataConfig, mcp, now)).Should(BeEmpty())
+
+		mcp.Status.DegradedMachineCount = 2
+		Expect(rollbackReason(kataConfig, mcp, now)).Should(ContainSubstring("the threshold is 2"))
+	})
+
+	It("Should roll back when the pool is degraded for longer than the timeout", func() {
+		kataConfig.Spec.RollbackThreshold = &kataconfigurationv2.RollbackThreshold{
+			Timeout: &metav1.Duration{Duration: 15 * time.Minute},
+		}
+		Expect(rollbackReason(kataConfig, mcp, now)).Should(BeEmpty())
+		Expect(rollbackReason(kataConfig, mcp, now.Add(10*time.Minute))).Should(ContainSubstring("degraded for more than 15m0s"))
+	})
+
+	It("Should report the rollback in the conditions", func() {
+		kataConfig.Status.Rollback = &kataconfigurationv2.RollbackStatus{
+			Phase:  kataconfigurationv2.RollbackPhaseCompleted,
+			Reason: "1 nodes of the pool kata-oc are degraded",
+		}
+		updateConditions(kataConfig)
+
+		Expect(meta.FindStatusCondition(kataConfig.Status.Conditions,
+			kataconfigurationv2.KataConfigRolledBack).Reason).Should(Equal("RollbackCompleted"))
+		Expect(meta.FindStatusCondition(kataConfig.Status.Conditions,
+			kataconfigurationv2.KataConfigReady).Reason).Should(Equal("RolledBack"))
+	})
+})
@@ -0,0 +1,450 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	ignTypes "github.com/coreos/ignition/v2/config/v3_2/types"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	kataconfigurationv2 "github.com/openshift/sandboxed-containers-operator/api/v2"
+	"github.com/vincent-petithory/dataurl"
+	nodeapi "k8s.io/api/node/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// runtimeHandlersMcName is the MachineConfig holding the CRI-O runtime handlers
+	// rendered for the additional RuntimeClasses
+	runtimeHandlersMcName = "50-sandboxed-containers-runtime-handlers"
+
+	crioDropInDir = "/etc/crio/crio.conf.d"
+)
+
+// renderCrioRuntimeHandler returns the CRI-O drop-in configuration of a kata runtime handler.
+// It mirrors the kata handler shipped by the sandboxed-containers extension.
+func renderCrioRuntimeHandler(handler string, configPath string, allowedAnnotations []string) string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "[crio.runtime.runtimes.%s]\n", handler)
+	fmt.Fprintf(&sb, "  runtime_path = %s\n", strconv.Quote("/usr/bin/containerd-shim-kata-v2"))
+	fmt.Fprintf(&sb, "  runtime_type = %s\n", strconv.Quote("vm"))
+	fmt.Fprintf(&sb, "  runtime_root = %s\n", strconv.Quote("/run/vc"))
+	if configPath != "" {
+		fmt.Fprintf(&sb, "  runtime_config_path = %s\n", strconv.Quote(configPath))
+	}
+	fmt.Fprintf(&sb, "  privileged_without_host_devices = true\n")
+	if len(allowedAnnotations) > 0 {
+		quoted := make([]string, 0, len(allowedAnnotations))
+		for _, annotation := range allowedAnnotations {
+			quoted = append(quoted, strconv.Quote(annotation))
+		}
+		fmt.Fprintf(&sb, "  allowed_annotations = [%s]\n", strings.Join(quoted, ", "))
+	}
+
+	return sb.String()
+}
+
+// newIgnitionFile returns an Ignition file entry writing contents at path
+func newIgnitionFile(path string, contents string) ignTypes.File {
+	source := dataurl.EncodeBytes([]byte(contents))
+	overwrite := true
+	mode := 0644
+
+	return ignTypes.File{
+		Node: ignTypes.Node{
+			Path:      path,
+			Overwrite: &overwrite,
+		},
+		FileEmbedded1: ignTypes.FileEmbedded1{
+			Contents: ignTypes.Resource{
+				Source: &source,
+			},
+			Mode: &mode,
+		},
+	}
+}
+
+// defaultRuntimeHandlerFiles returns the CRI-O drop-in of the runtime handler of the default
+// RuntimeClass. The kata handler is shipped by the extension and is only rendered when renamed,
+// for an alternative hypervisor, with the kata configuration of the hypervisor, or to load the
+// kata configuration from configDir when it is set.
+func defaultRuntimeHandlerFiles(spec *kataconfigurationv2.KataConfigSpec, configDir string) []ignTypes.File {
+	handler := spec.GetRuntimeClassHandler()
+	if handler == kataconfigurationv2.DefaultRuntimeClassHandler && configDir == "" {
+		return nil
+	}
+
+	configPath := ""
+	if configDir != "" {
+		configPath = kataConfigPath(configDir, spec.GetHypervisor())
+	} else if hypervisor := spec.GetHypervisor(); hypervisor != kataconfigurationv2.HypervisorQEMU {
+		configPath = hypervisorConfigPath(kataDefaultsDir, hypervisor)
+	}
+	return []ignTypes.File{newIgnitionFile(crioDropInDir+"/50-kata-"+handler,
+		renderCrioRuntimeHandler(handler, configPath, nil))}
+}
+
+// runtimeHandlerConfigDir returns the directory the runtime handlers load the kata
+// configuration from, empty for the configuration of the extension
+func (r *KataConfigOpenShiftReconciler) runtimeHandlerConfigDir() string {
+	if r.hasKataDropIns() {
+		return kataConfigDir
+	}
+	return ""
+}
+
+// runtimeHandlerFiles returns the CRI-O drop-ins needed by the RuntimeClasses of the KataConfig.
+// With kata configuration drop-ins, the handlers not naming their own configuration load the
+// copy of the configuration of the extension the drop-ins sit next to.
+// The kata-remote handler of peer pods comes with its own kata configuration.
+func (r *KataConfigOpenShiftReconciler) runtimeHandlerFiles() []ignTypes.File {
+	configDir := r.runtimeHandlerConfigDir()
+	files := defaultRuntimeHandlerFiles(&r.kataConfig.Spec, configDir)
+
+	for _, variant := range r.kataConfig.Spec.RuntimeClasses {
+		configPath := variant.ConfigPath
+		if configPath == "" && configDir != "" {
+			configPath = kataConfigPath(configDir, r.kataConfig.Spec.GetHypervisor())
+		}
+		files = append(files, newIgnitionFile(crioDropInDir+"/50-kata-"+variant.GetHandler(),
+			renderCrioRuntimeHandler(variant.GetHandler(), configPath, variant.AllowedAnnotations)))
+	}
+	files = append(files, r.peerPodsFiles()...)
+
+	return files
+}
+
+// runtimeHandlerUnits returns the systemd units the runtime handlers need
+func (r *KataConfigOpenShiftReconciler) runtimeHandlerUnits() []ignTypes.Unit {
+	if r.runtimeHandlerConfigDir() == "" {
+		return nil
+	}
+	return []ignTypes.Unit{newKataConfigUnit()}
+}
+
+// newMCWithFiles returns a MachineConfig writing the given files and enabling the given
+// systemd units on the nodes of the pool
+func (r *KataConfigOpenShiftReconciler) newMCWithFiles(name string, machinePool string, files []ignTypes.File, units ...ignTypes.Unit) (*mcfgv1.MachineConfig, error) {
+	machinePool, err := r.getMcRole(machinePool)
+	if err != nil {
+		return nil, err
+	}
+
+	ic := ignTypes.Config{
+		Ignition: ignTypes.Ignition{
+			Version: "3.2.0",
+		},
+		Storage: ignTypes.Storage{
+			Files: files,
+		},
+		Systemd: ignTypes.Systemd{
+			Units: units,
+		},
+	}
+
+	icb, err := json.Marshal(ic)
+	if err != nil {
+		return nil, err
+	}
+
+	mc := mcfgv1.MachineConfig{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "machineconfiguration.openshift.io/v1",
+			Kind:       "MachineConfig",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Labels: map[string]string{
+				"machineconfiguration.openshift.io/role": machinePool,
+				"app":                                    r.kataConfig.Name,
+			},
+			Namespace: "openshift-sandboxed-containers-operator",
+		},
+		Spec: mcfgv1.MachineConfigSpec{
+			Config: runtime.RawExtension{
+				Raw: icb,
+			},
+		},
+	}
+
+	return &mc, nil
+}
+
+// applyMcWithFiles makes sure the named MachineConfig writes exactly the given files and
+// enables the given units, deleting it when there are none. It returns true if the
+// MachineConfig was changed.
+func (r *KataConfigOpenShiftReconciler) applyMcWithFiles(name string, machinePool string, files []ignTypes.File, units ...ignTypes.Unit) (bool, error) {
+	foundMc := &mcfgv1.MachineConfig{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: name}, foundMc)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return false, err
+	}
+	exists := err == nil
+
+	if len(files) == 0 && len(units) == 0 {
+		if !exists {
+			return false, nil
+		}
+		r.Log.Info("Deleting MachineConfig", "mc.Name", name)
+		err = r.Client.Delete(context.TODO(), foundMc)
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return false, err
+		}
+		return true, nil
+	}
+
+	mc, err := r.newMCWithFiles(name, machinePool, files, units...)
+	if err != nil {
+		return false, err
+	}
+
+	if !exists {
+		r.Log.Info("Creating MachineConfig", "mc.Name", name)
+		err = r.Client.Create(context.TODO(), mc)
+		if err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	// The configuration is compared once decoded as the API server may
+	// serialize it differently
+	foundConfig := ignTypes.Config{}
+	if err = json.Unmarshal(foundMc.Spec.Config.Raw, &foundConfig); err == nil {
+		desiredConfig := ignTypes.Config{}
+		if err = json.Unmarshal(mc.Spec.Config.Raw, &desiredConfig); err != nil {
+			return false, err
+		}
+		if reflect.DeepEqual(foundConfig, desiredConfig) &&
+			reflect.DeepEqual(foundMc.Labels, mc.Labels) {
+			return false, nil
+		}
+	}
+
+	r.Log.Info("Updating MachineConfig", "mc.Name", name)
+	foundMc.Labels = mc.Labels
+	foundMc.Spec.Config = mc.Spec.Config
+	err = r.Client.Update(context.TODO(), foundMc)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// reconcileRuntimeHandlersMc renders the CRI-O runtime handlers of the KataConfig. It returns
+// true if the MachineConfig was changed and the pool has to roll out the change.
+func (r *KataConfigOpenShiftReconciler) reconcileRuntimeHandlersMc(machinePool string) (bool, error) {
+	changed, err := r.applyMcWithFiles(r.mcName(runtimeHandlersMcName, machinePool), machinePool, r.runtimeHandlerFiles(), r.runtimeHandlerUnits()...)
+	if err != nil {
+		r.Log.Error(err, "Failed to apply the runtime handlers MachineConfig")
+		return false, err
+	}
+
+	if changed {
+		var rcStatuses []kataconfigurationv2.RuntimeClassStatus
+		for _, variant := range r.kataConfig.Spec.RuntimeClasses {
+			rcStatuses = append(rcStatuses, kataconfigurationv2.RuntimeClassStatus{
+				Name:    variant.Name,
+				Handler: variant.GetHandler(),
+				Ready:   false,
+				Message: "Waiting for the runtime handler to be rolled out",
+			})
+		}
+		/* Keep track of the removed RuntimeClasses until they are deleted */
+		for _, rcStatus := range r.kataConfig.Status.RuntimeClasses {
+			if r.getRuntimeClassVariant(rcStatus.Name) == nil {
+				rcStatus.Ready = false
+				rcStatus.Message = "Removal pending"
+				rcStatuses = append(rcStatuses, rcStatus)
+			}
+		}
+		r.kataConfig.Status.RuntimeClasses = rcStatuses
+	}
+
+	return changed, nil
+}
+
+// newRuntimeClassVariant returns the RuntimeClass of an additional runtime variant
+func (r *KataConfigOpenShiftReconciler) newRuntimeClassVariant(variant *kataconfigurationv2.RuntimeClassVariant) (*nodeapi.RuntimeClass, error) {
+	overhead := variant.Overhead
+	if len(overhead) == 0 {
+		overhead = r.kataConfig.Spec.GetRuntimeClassOverhead()
+	}
+
+	nodeSelector := variant.NodeSelector
+	if nodeSelector == nil && r.kataNodeSelector() != nil {
+		var err error
+		nodeSelector, err = metav1.LabelSelectorAsMap(r.kataNodeSelector())
+		if err != nil {
+			r.Log.Error(err, "Unable to get nodeSelector for runtimeClass", "rc.Name", variant.Name)
+		}
+	}
+
+	rc := &nodeapi.RuntimeClass{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "node.k8s.io/v1beta1",
+			Kind:       "RuntimeClass",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: variant.Name,
+		},
+		Handler: variant.GetHandler(),
+		Overhead: &nodeapi.Overhead{
+			PodFixed: overhead,
+		},
+	}
+	if nodeSelector != nil || len(variant.Tolerations) > 0 {
+		rc.Scheduling = &nodeapi.Scheduling{
+			NodeSelector: nodeSelector,
+			Tolerations:  variant.Tolerations,
+		}
+	}
+
+	if err := controllerutil.SetControllerReference(r.kataConfig, rc, r.Scheme); err != nil {
+		return nil, err
+	}
+	return rc, nil
+}
+
+// setRuntimeClassVariants creates or updates the additional RuntimeClasses and removes the
+// ones no longer requested, then reports the readiness of each of them
+func (r *KataConfigOpenShiftReconciler) setRuntimeClassVariants() error {
+	var rcStatuses []kataconfigurationv2.RuntimeClassStatus
+
+	for i := range r.kataConfig.Spec.RuntimeClasses {
+		variant := &r.kataConfig.Spec.RuntimeClasses[i]
+		rcStatus := kataconfigurationv2.RuntimeClassStatus{
+			Name:    variant.Name,
+			Handler: variant.GetHandler(),
+		}
+
+		if err := r.applyRuntimeClassVariant(variant); err != nil {
+			r.Log.Error(err, "Failed to apply RuntimeClass", "rc.Name", variant.Name)
+			rcStatus.Message = err.Error()
+		} else {
+			rcStatus.Ready = true
+		}
+		rcStatuses = append(rcStatuses, rcStatus)
+	}
+
+	/* Remove the RuntimeClasses that are no longer requested */
+	rcList := &nodeapi.RuntimeClassList{}
+	if err := r.Client.List(context.TODO(), rcList); err != nil {
+		return err
+	}
+	for i := range rcList.Items {
+		rc := &rcList.Items[i]
+		if !metav1.IsControlledBy(rc, r.kataConfig) || rc.Name == r.kataConfig.GetRuntimeClassName() ||
+			r.getRuntimeClassVariant(rc.Name) != nil || r.isPeerPodsRuntimeClass(rc.Name) {
+			continue
+		}
+
+		if err := r.listKataPods(rc.Name); err != nil {
+			r.Log.Info("Pods still use the RuntimeClass, not removing it yet", "rc.Name", rc.Name)
+			rcStatuses = append(rcStatuses, kataconfigurationv2.RuntimeClassStatus{
+				Name:    rc.Name,
+				Handler: rc.Handler,
+				Message: "Removal pending, the RuntimeClass is still used by pods",
+			})
+			continue
+		}
+		if err := r.deleteRuntimeClass(rc.Name); err != nil {
+			return err
+		}
+	}
+
+	r.kataConfig.Status.RuntimeClasses = rcStatuses
+	return nil
+}
+
+// applyRuntimeClassVariant creates the RuntimeClass of a variant, or updates it if it changed
+func (r *KataConfigOpenShiftReconciler) applyRuntimeClassVariant(variant *kataconfigurationv2.RuntimeClassVariant) error {
+	rc, err := r.newRuntimeClassVariant(variant)
+	if err != nil {
+		return err
+	}
+
+	foundRc := &nodeapi.RuntimeClass{}
+	err = r.Client.Get(context.TODO(), types.NamespacedName{Name: rc.Name}, foundRc)
+	if err != nil && k8serrors.IsNotFound(err) {
+		r.Log.Info("Creating a new RuntimeClass", "rc.Name", rc.Name)
+		return r.Client.Create(context.TODO(), rc)
+	} else if err != nil {
+		return err
+	}
+
+	if !metav1.IsControlledBy(foundRc, r.kataConfig) {
+		return fmt.Errorf("RuntimeClass %s already exists and is not managed by KataConfig %s", rc.Name, r.kataConfig.Name)
+	}
+
+	if foundRc.Handler != rc.Handler {
+		/* The handler of a RuntimeClass is immutable, it has to be recreated */
+		r.Log.Info("Replacing RuntimeClass with a new handler", "rc.Name", rc.Name, "rc.Handler", rc.Handler)
+		if err = r.Client.Delete(context.TODO(), foundRc); err != nil {
+			return err
+		}
+		return r.Client.Create(context.TODO(), rc)
+	}
+
+	if !equality.Semantic.DeepEqual(foundRc.Overhead, rc.Overhead) ||
+		!equality.Semantic.DeepEqual(foundRc.Scheduling, rc.Scheduling) {
+		r.Log.Info("Updating RuntimeClass", "rc.Name", rc.Name)
+		foundRc.Overhead = rc.Overhead
+		foundRc.Scheduling = rc.Scheduling
+		return r.Client.Update(context.TODO(), foundRc)
+	}
+
+	return nil
+}
+
+// getRuntimeClassVariant returns the additional RuntimeClass with the given name, if any
+func (r *KataConfigOpenShiftReconciler) getRuntimeClassVariant(name string) *kataconfigurationv2.RuntimeClassVariant {
+	for i := range r.kataConfig.Spec.RuntimeClasses {
+		if r.kataConfig.Spec.RuntimeClasses[i].Name == name {
+			return &r.kataConfig.Spec.RuntimeClasses[i]
+		}
+	}
+	return nil
+}
+
+// runtimeClassNames returns the names of all the RuntimeClasses created for the KataConfig
+func (r *KataConfigOpenShiftReconciler) runtimeClassNames() []string {
+	var names []string
+	if r.kataConfig.Status.RuntimeClass != "" {
+		names = append(names, r.kataConfig.Status.RuntimeClass)
+	}
+	for _, rcStatus := range r.kataConfig.Status.RuntimeClasses {
+		names = append(names, rcStatus.Name)
+	}
+	if r.kataConfig.Status.PeerPods != nil {
+		names = append(names, r.kataConfig.Status.PeerPods.RuntimeClass)
+	}
+	return names
+}
+
+// deleteMc deletes the named MachineConfig if it exists
+func (r *KataConfigOpenShiftReconciler) deleteMc(name string) error {
+	mc := &mcfgv1.MachineConfig{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: name}, mc)
+	if err != nil && k8serrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	r.Log.Info("Deleting MachineConfig", "mc.Name", name)
+	err = r.Client.Delete(context.TODO(), mc)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
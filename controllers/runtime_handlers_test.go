@@ -0,0 +1,28 @@
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CRI-O runtime handlers", func() {
+	Context("Rendering a runtime handler drop-in", func() {
+		It("Should mirror the kata handler of the extension", func() {
+			Expect(renderCrioRuntimeHandler("kata-debug", "", nil)).Should(Equal(
+				"[crio.runtime.runtimes.kata-debug]\n" +
+					"  runtime_path = \"/usr/bin/containerd-shim-kata-v2\"\n" +
+					"  runtime_type = \"vm\"\n" +
+					"  runtime_root = \"/run/vc\"\n" +
+					"  privileged_without_host_devices = true\n"))
+		})
+
+		It("Should render the configuration path and allowed annotations", func() {
+			dropIn := renderCrioRuntimeHandler("kata-debug", "/etc/kata-containers/configuration-debug.toml",
+				[]string{"io.katacontainers.config.agent.debug_console_enabled"})
+			Expect(dropIn).Should(ContainSubstring(
+				"  runtime_config_path = \"/etc/kata-containers/configuration-debug.toml\"\n"))
+			Expect(dropIn).Should(ContainSubstring(
+				"  allowed_annotations = [\"io.katacontainers.config.agent.debug_console_enabled\"]\n"))
+		})
+	})
+})
@@ -37,6 +37,7 @@ import (
 
 	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
 	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	kataconfigurationv2 "github.com/openshift/sandboxed-containers-operator/api/v2"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -72,17 +73,17 @@ var _ = BeforeSuite(func(done Done) {
 		WebhookInstallOptions: webhookOptions,
 	}
 
-	var err error
-	cfg, err = testEnv.Start()
-	Expect(err).ToNot(HaveOccurred())
-	Expect(cfg).ToNot(BeNil())
-
+	// The scheme is set up before starting the test environment so that it
+	// enables the conversion webhook of the KataConfig CRD
 	s := scheme.Scheme
 
 	s.AddKnownTypes(appsv1.SchemeGroupVersion, &appsv1.DaemonSet{})
 	s.AddKnownTypes(corev1.SchemeGroupVersion, &corev1.NodeList{})
 
-	err = kataconfigurationv1.AddToScheme(s)
+	err := kataconfigurationv1.AddToScheme(s)
+	Expect(err).NotTo(HaveOccurred())
+
+	err = kataconfigurationv2.AddToScheme(s)
 	Expect(err).NotTo(HaveOccurred())
 
 	err = mcfgv1.AddToScheme(s)
@@ -90,6 +91,10 @@ var _ = BeforeSuite(func(done Done) {
 
 	// +kubebuilder:scaffold:scheme
 
+	cfg, err = testEnv.Start()
+	Expect(err).ToNot(HaveOccurred())
+	Expect(cfg).ToNot(BeNil())
+
 	k8sManager, err = ctrl.NewManager(cfg, ctrl.Options{
 		Scheme:  scheme.Scheme,
 		Port:    testEnv.WebhookInstallOptions.LocalServingPort,
@@ -99,13 +104,14 @@ var _ = BeforeSuite(func(done Done) {
 	Expect(err).ToNot(HaveOccurred())
 
 	err = (&KataConfigOpenShiftReconciler{
-		Client: k8sManager.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("KataConfig"),
-		Scheme: k8sManager.GetScheme(),
+		Client:   k8sManager.GetClient(),
+		Log:      ctrl.Log.WithName("controllers").WithName("KataConfig"),
+		Scheme:   k8sManager.GetScheme(),
+		Recorder: k8sManager.GetEventRecorderFor("kataconfig-controller"),
 	}).SetupWithManager(k8sManager)
 	Expect(err).ToNot(HaveOccurred())
 
-	err = (&kataconfigurationv1.KataConfig{}).SetupWebhookWithManager(k8sManager)
+	err = (&kataconfigurationv2.KataConfig{}).SetupWebhookWithManager(k8sManager)
 	Expect(err).ToNot(HaveOccurred())
 
 	go func() {
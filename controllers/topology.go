@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	kataconfigurationv2 "github.com/openshift/sandboxed-containers-operator/api/v2"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// singleReplicaTopology is the control plane topology of single node clusters
+	singleReplicaTopology = "SingleReplica"
+
+	// externalTopology is the control plane topology of clusters with a hosted control plane
+	externalTopology = "External"
+)
+
+// errMasterPoolSelector refuses a custom MachineConfigPool on the clusters running the workloads
+// on the master nodes
+var errMasterPoolSelector = errors.New("The master nodes running the workloads of this cluster cannot join a custom " +
+	"MachineConfigPool, remove the kataConfigPoolSelector to install the kata runtime on all of them")
+
+// infrastructureGVK is the cluster-wide Infrastructure resource reporting the topology. It is
+// read unstructured as the OpenShift API version the operator builds with predates the topology.
+var infrastructureGVK = schema.GroupVersionKind{Group: "config.openshift.io", Version: "v1", Kind: "Infrastructure"}
+
+// getControlPlaneTopology returns the control plane topology reported by the Infrastructure
+// resource, which is empty on the OpenShift versions not reporting it
+func getControlPlaneTopology(c client.Reader) (string, error) {
+	infrastructure := &unstructured.Unstructured{}
+	infrastructure.SetGroupVersionKind(infrastructureGVK)
+	err := c.Get(context.TODO(), types.NamespacedName{Name: "cluster"}, infrastructure)
+	if err != nil && (k8serrors.IsNotFound(err) || meta.IsNoMatchError(err)) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	topology, _, err := unstructured.NestedString(infrastructure.Object, "status", "controlPlaneTopology")
+	return topology, err
+}
+
+// clusterTopology returns the topology of the cluster. Compact clusters have no worker node,
+// their master nodes are schedulable.
+func clusterTopology(controlPlaneTopology string, workerMachineCount int32) kataconfigurationv2.ClusterTopology {
+	switch {
+	case controlPlaneTopology == externalTopology:
+		return kataconfigurationv2.ClusterTopologyHostedControlPlane
+	case controlPlaneTopology == singleReplicaTopology:
+		return kataconfigurationv2.ClusterTopologySingleNode
+	case workerMachineCount == 0:
+		return kataconfigurationv2.ClusterTopologyCompact
+	default:
+		return kataconfigurationv2.ClusterTopologyStandard
+	}
+}
+
+// IsHostedControlPlane tells whether the control plane of the cluster is hosted outside of it, in
+// which case there is no MachineConfigPool and the nodes are configured through NodePools
+func IsHostedControlPlane(c client.Reader) (bool, error) {
+	controlPlaneTopology, err := getControlPlaneTopology(c)
+	if err != nil {
+		return false, err
+	}
+	return controlPlaneTopology == externalTopology, nil
+}
+
+// topologyPool returns the MachineConfigPool of the nodes running the workloads
+func topologyPool(topology kataconfigurationv2.ClusterTopology) string {
+	if topology == kataconfigurationv2.ClusterTopologyCompact || topology == kataconfigurationv2.ClusterTopologySingleNode {
+		return "master"
+	}
+	return "worker"
+}
+
+// topologyPoolSelector returns the kataConfigPoolSelector to install the kata runtime with on
+// the given pool. The master nodes cannot join a custom MachineConfigPool, so on the clusters
+// running the workloads on them only the selectors of the whole pool are accepted. These nodes
+// also carry the worker role, which then selects the master pool too.
+func topologyPoolSelector(selector *metav1.LabelSelector, machinePool string) (*metav1.LabelSelector, error) {
+	poolSelector := &metav1.LabelSelector{
+		MatchLabels: map[string]string{"node-role.kubernetes.io/" + machinePool: ""},
+	}
+	if selector == nil {
+		return poolSelector, nil
+	}
+	if machinePool != "master" {
+		return selector, nil
+	}
+
+	workerSelector := &metav1.LabelSelector{
+		MatchLabels: map[string]string{"node-role.kubernetes.io/worker": ""},
+	}
+	if equality.Semantic.DeepEqual(selector, poolSelector) || equality.Semantic.DeepEqual(selector, workerSelector) {
+		return poolSelector, nil
+	}
+	return nil, errMasterPoolSelector
+}
+
+// detectTopology records the topology of the cluster in the KataConfig status and returns it
+func (r *KataConfigOpenShiftReconciler) detectTopology() (kataconfigurationv2.ClusterTopology, error) {
+	workerMcp := &mcfgv1.MachineConfigPool{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: "worker"}, workerMcp)
+	if err != nil && k8serrors.IsNotFound(err) {
+		r.Log.Error(err, "No worker MachineConfigPool found!")
+		return "", err
+	} else if err != nil {
+		r.Log.Error(err, "Could not get the worker MachineConfigPool!")
+		return "", err
+	}
+
+	controlPlaneTopology, err := getControlPlaneTopology(r.Client)
+	if err != nil {
+		return "", err
+	}
+
+	topology := clusterTopology(controlPlaneTopology, workerMcp.Status.MachineCount)
+	if r.kataConfig.Status.ClusterTopology != topology {
+		r.Log.Info("Detected the cluster topology", "topology", topology)
+		r.kataConfig.Status.ClusterTopology = topology
+	}
+	return topology, nil
+}
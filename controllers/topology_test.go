@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	kataconfigurationv2 "github.com/openshift/sandboxed-containers-operator/api/v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Cluster topology", func() {
+	It("Should detect the topology of the cluster", func() {
+		Expect(clusterTopology("HighlyAvailable", 3)).Should(Equal(kataconfigurationv2.ClusterTopologyStandard))
+		Expect(clusterTopology("HighlyAvailable", 0)).Should(Equal(kataconfigurationv2.ClusterTopologyCompact))
+		Expect(clusterTopology("", 0)).Should(Equal(kataconfigurationv2.ClusterTopologyCompact))
+		Expect(clusterTopology("SingleReplica", 0)).Should(Equal(kataconfigurationv2.ClusterTopologySingleNode))
+		Expect(clusterTopology("External", 0)).Should(Equal(kataconfigurationv2.ClusterTopologyHostedControlPlane))
+	})
+
+	It("Should read the control plane topology of the Infrastructure", func() {
+		infrastructure := &unstructured.Unstructured{}
+		infrastructure.SetGroupVersionKind(infrastructureGVK)
+		infrastructure.SetName("cluster")
+		Expect(unstructured.SetNestedField(infrastructure.Object, "SingleReplica", "status", "controlPlaneTopology")).To(Succeed())
+		c := fake.NewClientBuilder().WithObjects(infrastructure).Build()
+
+		topology, err := getControlPlaneTopology(c)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(topology).Should(Equal("SingleReplica"))
+		Expect(IsHostedControlPlane(c)).Should(BeFalse())
+	})
+
+	It("Should install on the master pool of compact and single node clusters", func() {
+		Expect(topologyPool(kataconfigurationv2.ClusterTopologyStandard)).Should(Equal("worker"))
+		Expect(topologyPool(kataconfigurationv2.ClusterTopologyCompact)).Should(Equal("master"))
+		Expect(topologyPool(kataconfigurationv2.ClusterTopologySingleNode)).Should(Equal("master"))
+
+		masterSelector := &metav1.LabelSelector{MatchLabels: map[string]string{"node-role.kubernetes.io/master": ""}}
+		selector, err := topologyPoolSelector(nil, "master")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(selector).Should(Equal(masterSelector))
+
+		By("Selecting the master pool with the worker role the master nodes carry")
+		selector, err = topologyPoolSelector(&metav1.LabelSelector{
+			MatchLabels: map[string]string{"node-role.kubernetes.io/worker": ""}}, "master")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(selector).Should(Equal(masterSelector))
+
+		By("Refusing a custom pool of master nodes")
+		_, err = topologyPoolSelector(&metav1.LabelSelector{
+			MatchLabels: map[string]string{"kata": "true"}}, "master")
+		Expect(err).Should(Equal(errMasterPoolSelector))
+	})
+
+	It("Should keep the custom selectors of standard clusters", func() {
+		customSelector := &metav1.LabelSelector{MatchLabels: map[string]string{"kata": "true"}}
+		selector, err := topologyPoolSelector(customSelector, "worker")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(selector).Should(Equal(customSelector))
+	})
+})
@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	kataconfigurationv2 "github.com/openshift/sandboxed-containers-operator/api/v2"
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// effectiveUninstallPolicy returns the uninstall policy applied to the pods still using the
+// kata runtime. The Evict policy turns into Force once the uninstall timeout expired.
+func effectiveUninstallPolicy(kataConfig *kataconfigurationv2.KataConfig, now time.Time) kataconfigurationv2.UninstallPolicy {
+	policy := kataConfig.Spec.GetUninstallPolicy()
+	timeout := kataConfig.Spec.UninstallTimeout
+	if policy != kataconfigurationv2.UninstallPolicyEvict || timeout == nil || kataConfig.DeletionTimestamp == nil {
+		return policy
+	}
+	if now.Sub(kataConfig.DeletionTimestamp.Time) > timeout.Duration {
+		return kataconfigurationv2.UninstallPolicyForce
+	}
+	return policy
+}
+
+// podNames returns the namespace/name of the given pods
+func podNames(pods []corev1.Pod) string {
+	names := make([]string, 0, len(pods))
+	for _, pod := range pods {
+		names = append(names, pod.Namespace+"/"+pod.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// maxBlockingPods caps the number of blocking pods listed in the uninstallation status
+const maxBlockingPods = 50
+
+// setBlockingPods records the pods blocking the uninstallation in the status. When they
+// changed, it emits an event per namespace so that the owners of the pods can be notified.
+func (r *KataConfigOpenShiftReconciler) setBlockingPods(pods []corev1.Pod) {
+	recordBlockingPods(r.Recorder, r.kataConfig, pods)
+}
+
+// recordBlockingPods implements setBlockingPods for the reconcilers of all the cluster flavours
+func recordBlockingPods(recorder record.EventRecorder, kataConfig *kataconfigurationv2.KataConfig, pods []corev1.Pod) {
+	status := &kataConfig.Status.UnInstallationStatus
+
+	var blockingPods []kataconfigurationv2.BlockingPod
+	for i, pod := range pods {
+		if i == maxBlockingPods {
+			break
+		}
+		blockingPods = append(blockingPods, kataconfigurationv2.BlockingPod{
+			Namespace: pod.Namespace,
+			Name:      pod.Name,
+			NodeName:  pod.Spec.NodeName,
+		})
+	}
+	isChanged := status.BlockingPodsCount != len(pods) || !reflect.DeepEqual(status.BlockingPods, blockingPods)
+	status.BlockingPods = blockingPods
+	status.BlockingPodsCount = len(pods)
+	if !isChanged || len(pods) == 0 {
+		return
+	}
+
+	podsByNamespace := map[string][]string{}
+	var namespaces []string
+	for _, pod := range pods {
+		if _, ok := podsByNamespace[pod.Namespace]; !ok {
+			namespaces = append(namespaces, pod.Namespace)
+		}
+		podsByNamespace[pod.Namespace] = append(podsByNamespace[pod.Namespace], pod.Name)
+	}
+	for _, namespace := range namespaces {
+		recorder.Eventf(kataConfig, corev1.EventTypeWarning, "UninstallBlockedByPods",
+			"%d pods in namespace %s use the kata runtime and block the uninstallation: %s",
+			len(podsByNamespace[namespace]), namespace, strings.Join(podsByNamespace[namespace], ", "))
+	}
+}
+
+// removeKataPods applies the uninstall policy to the pods still using the kata runtime and
+// returns the message reported in the uninstallation status while they are present
+func (r *KataConfigOpenShiftReconciler) removeKataPods(pods []corev1.Pod) (string, error) {
+	return applyUninstallPolicy(r.Client, r.clientset, r.Log, r.kataConfig, pods)
+}
+
+// applyUninstallPolicy implements removeKataPods for the reconcilers of all the cluster flavours
+func applyUninstallPolicy(c client.Client, clientset kubernetes.Interface, log logr.Logger,
+	kataConfig *kataconfigurationv2.KataConfig, pods []corev1.Pod) (string, error) {
+	policy := effectiveUninstallPolicy(kataConfig, time.Now())
+
+	switch policy {
+	case kataconfigurationv2.UninstallPolicyEvict:
+		for i := range pods {
+			if err := evictKataPod(clientset, log, &pods[i]); err != nil {
+				return "", err
+			}
+		}
+		return fmt.Sprintf("Evicting the pods using the kata runtime: %s", podNames(pods)), nil
+	case kataconfigurationv2.UninstallPolicyForce:
+		for i := range pods {
+			pod := &pods[i]
+			if pod.DeletionTimestamp != nil {
+				continue
+			}
+			log.Info("Deleting the kata pod", "pod.Namespace", pod.Namespace, "pod.Name", pod.Name)
+			if err := c.Delete(context.TODO(), pod); err != nil && !k8serrors.IsNotFound(err) {
+				return "", err
+			}
+		}
+		return fmt.Sprintf("Deleting the pods using the kata runtime: %s", podNames(pods)), nil
+	}
+
+	return "Existing pods using Kata Runtime found. Please delete the pods manually for KataConfig deletion to proceed", nil
+}
+
+// evictKataPod evicts a pod through the eviction API. An eviction refused by a
+// PodDisruptionBudget is retried on the next reconciliation.
+func evictKataPod(clientset kubernetes.Interface, log logr.Logger, pod *corev1.Pod) error {
+	if pod.DeletionTimestamp != nil {
+		return nil
+	}
+
+	log.Info("Evicting the kata pod", "pod.Namespace", pod.Namespace, "pod.Name", pod.Name)
+	err := clientset.CoreV1().Pods(pod.Namespace).Evict(context.TODO(), &policyv1beta1.Eviction{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      pod.Name,
+			Namespace: pod.Namespace,
+		},
+	})
+	if k8serrors.IsTooManyRequests(err) {
+		log.Info("The eviction of the kata pod is refused by a PodDisruptionBudget, retrying later",
+			"pod.Namespace", pod.Namespace, "pod.Name", pod.Name)
+		return nil
+	}
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	kataconfigurationv2 "github.com/openshift/sandboxed-containers-operator/api/v2"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	clientfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Uninstall policy", func() {
+	var kataConfig *kataconfigurationv2.KataConfig
+	now := time.Now()
+
+	BeforeEach(func() {
+		deletionTimestamp := metav1.NewTime(now.Add(-10 * time.Minute))
+		kataConfig = &kataconfigurationv2.KataConfig{
+			ObjectMeta: metav1.ObjectMeta{DeletionTimestamp: &deletionTimestamp},
+		}
+	})
+
+	It("Should block by default", func() {
+		Expect(effectiveUninstallPolicy(kataConfig, now)).Should(Equal(kataconfigurationv2.UninstallPolicyBlock))
+	})
+
+	It("Should keep evicting without timeout", func() {
+		kataConfig.Spec.UninstallPolicy = kataconfigurationv2.UninstallPolicyEvict
+		Expect(effectiveUninstallPolicy(kataConfig, now)).Should(Equal(kataconfigurationv2.UninstallPolicyEvict))
+	})
+
+	It("Should keep evicting until the timeout expires", func() {
+		kataConfig.Spec.UninstallPolicy = kataconfigurationv2.UninstallPolicyEvict
+		kataConfig.Spec.UninstallTimeout = &metav1.Duration{Duration: time.Hour}
+		Expect(effectiveUninstallPolicy(kataConfig, now)).Should(Equal(kataconfigurationv2.UninstallPolicyEvict))
+	})
+
+	It("Should delete the remaining pods once the timeout expired", func() {
+		kataConfig.Spec.UninstallPolicy = kataconfigurationv2.UninstallPolicyEvict
+		kataConfig.Spec.UninstallTimeout = &metav1.Duration{Duration: 5 * time.Minute}
+		Expect(effectiveUninstallPolicy(kataConfig, now)).Should(Equal(kataconfigurationv2.UninstallPolicyForce))
+	})
+
+	It("Should keep waiting when a PodDisruptionBudget refuses the eviction", func() {
+		clientset := fake.NewSimpleClientset()
+		clientset.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, k8serrors.NewTooManyRequests("Cannot evict pod as it would violate the pod's disruption budget.", 10)
+		})
+		r := &KataConfigOpenShiftReconciler{
+			Log:        ctrl.Log.WithName("test"),
+			clientset:  clientset,
+			kataConfig: kataConfig,
+		}
+		kataConfig.Spec.UninstallPolicy = kataconfigurationv2.UninstallPolicyEvict
+
+		pods := []corev1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "ns1"}}}
+		message, err := r.removeKataPods(pods)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(message).Should(Equal("Evicting the pods using the kata runtime: ns1/pod1"))
+		Expect(clientset.Actions()).Should(HaveLen(1))
+		Expect(clientset.Actions()[0].GetSubresource()).Should(Equal("eviction"))
+	})
+
+	It("Should apply the uninstall policy on Kubernetes clusters", func() {
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "ns1"}}
+		c := clientfake.NewClientBuilder().WithObjects(pod).Build()
+		r := &KataConfigKubernetesReconciler{
+			Client:     c,
+			Log:        ctrl.Log.WithName("test"),
+			kataConfig: kataConfig,
+		}
+		kataConfig.Spec.UninstallPolicy = kataconfigurationv2.UninstallPolicyForce
+
+		message, err := r.removeKataPods([]corev1.Pod{*pod})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(message).Should(Equal("Deleting the pods using the kata runtime: ns1/pod1"))
+		Expect(c.Get(context.TODO(), client.ObjectKeyFromObject(pod), &corev1.Pod{})).ToNot(Succeed())
+	})
+
+	It("Should list the blocking pods and emit an event per namespace", func() {
+		recorder := record.NewFakeRecorder(10)
+		r := &KataConfigOpenShiftReconciler{
+			Recorder:   recorder,
+			kataConfig: kataConfig,
+		}
+		pods := []corev1.Pod{
+			{ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "ns1"}, Spec: corev1.PodSpec{NodeName: "worker0"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "pod2", Namespace: "ns2"}, Spec: corev1.PodSpec{NodeName: "worker1"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "pod3", Namespace: "ns1"}, Spec: corev1.PodSpec{NodeName: "worker1"}},
+		}
+
+		r.setBlockingPods(pods)
+		status := kataConfig.Status.UnInstallationStatus
+		Expect(status.BlockingPodsCount).Should(Equal(3))
+		Expect(status.BlockingPods).Should(ContainElement(kataconfigurationv2.BlockingPod{
+			Namespace: "ns2", Name: "pod2", NodeName: "worker1"}))
+		Expect(recorder.Events).Should(HaveLen(2))
+		Expect(<-recorder.Events).Should(ContainSubstring("2 pods in namespace ns1 use the kata runtime and block the uninstallation: pod1, pod3"))
+
+		By("Not emitting the events again while the blocking pods are the same")
+		r.setBlockingPods(pods)
+		Expect(recorder.Events).Should(HaveLen(1))
+
+		By("Clearing the list once the pods are gone")
+		r.setBlockingPods(nil)
+		Expect(kataConfig.Status.UnInstallationStatus.BlockingPods).Should(BeEmpty())
+		Expect(kataConfig.Status.UnInstallationStatus.BlockingPodsCount).Should(BeZero())
+	})
+
+	It("Should cap the number of blocking pods listed", func() {
+		r := &KataConfigOpenShiftReconciler{
+			Recorder:   record.NewFakeRecorder(10),
+			kataConfig: kataConfig,
+		}
+		var pods []corev1.Pod
+		for i := 0; i < maxBlockingPods+10; i++ {
+			pods = append(pods, corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("pod%d", i), Namespace: "ns1"}})
+		}
+
+		r.setBlockingPods(pods)
+		Expect(kataConfig.Status.UnInstallationStatus.BlockingPods).Should(HaveLen(maxBlockingPods))
+		Expect(kataConfig.Status.UnInstallationStatus.BlockingPodsCount).Should(Equal(maxBlockingPods + 10))
+	})
+})
+
+var _ = Describe("Uninstall phases", func() {
+	It("Should tell whether any kata MachineConfig is still rendered", func() {
+		r := &KataConfigOpenShiftReconciler{kataConfig: &kataconfigurationv2.KataConfig{}}
+		configuration := mcfgv1.MachineConfigPoolStatusConfiguration{
+			Source: []corev1.ObjectReference{{Name: "00-worker"}},
+		}
+		Expect(isAnyMcInConfiguration(r.kataMcNames(), configuration)).Should(BeFalse())
+
+		configuration.Source = append(configuration.Source, corev1.ObjectReference{Name: extensionMcName})
+		Expect(isAnyMcInConfiguration(r.kataMcNames(), configuration)).Should(BeTrue())
+	})
+})
@@ -0,0 +1,158 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	ignTypes "github.com/coreos/ignition/v2/config/v3_2/types"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	kataconfigurationv2 "github.com/openshift/sandboxed-containers-operator/api/v2"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// unknownVersion is recorded as the version upgraded from when the kata runtime
+// was installed by an operator that did not record its version
+const unknownVersion = "unknown"
+
+// isMcContentEqual tells whether two MachineConfigs enable the same extensions with the same configuration
+func isMcContentEqual(mc1 *mcfgv1.MachineConfig, mc2 *mcfgv1.MachineConfig) bool {
+	if !reflect.DeepEqual(mc1.Spec.Extensions, mc2.Spec.Extensions) {
+		return false
+	}
+
+	// The configuration is compared once decoded as the API server may
+	// serialize it differently
+	config1 := ignTypes.Config{}
+	config2 := ignTypes.Config{}
+	if json.Unmarshal(mc1.Spec.Config.Raw, &config1) != nil || json.Unmarshal(mc2.Spec.Config.Raw, &config2) != nil {
+		return false
+	}
+	return reflect.DeepEqual(config1, config2)
+}
+
+// isUpgradeInProgress tells whether the kata runtime is being upgraded
+func (r *KataConfigOpenShiftReconciler) isUpgradeInProgress() bool {
+	return r.kataConfig.Status.UpgradeStatus.IsInProgress
+}
+
+// checkUpgrade starts an upgrade when the kata runtime was installed by another operator
+// version and the bundled extension MachineConfig changed since. It returns true if the
+// extension MachineConfig was updated and the pool has to roll out the change.
+func (r *KataConfigOpenShiftReconciler) checkUpgrade(machinePools []string) (bool, error) {
+	status := &r.kataConfig.Status
+	if status.RuntimeClass == "" || status.InstallationStatus.IsInProgress ||
+		r.isUpgradeInProgress() || status.InstalledVersion == OperatorVersion {
+		return false, nil
+	}
+
+	var outdatedMcs []*mcfgv1.MachineConfig
+	for _, role := range machinePools {
+		mc, err := r.newMCForCR(role)
+		if err != nil {
+			return false, err
+		}
+
+		foundMc := &mcfgv1.MachineConfig{}
+		err = r.Client.Get(context.TODO(), types.NamespacedName{Name: mc.Name}, foundMc)
+		if err != nil && k8serrors.IsNotFound(err) {
+			// Nothing to upgrade, the MachineConfig is created again by the installation
+			continue
+		} else if err != nil {
+			return false, err
+		}
+
+		if !isMcContentEqual(foundMc, mc) {
+			foundMc.Spec.Extensions = mc.Spec.Extensions
+			foundMc.Spec.Config = mc.Spec.Config
+			outdatedMcs = append(outdatedMcs, foundMc)
+		}
+	}
+
+	if len(outdatedMcs) == 0 {
+		r.Log.Info("Extension MachineConfig is up to date", "version", OperatorVersion)
+		status.InstalledVersion = OperatorVersion
+		return false, nil
+	}
+
+	foundMcp, err := r.getMachineConfigPool(machinePools)
+	if err != nil {
+		return false, err
+	}
+
+	fromVersion := status.InstalledVersion
+	if fromVersion == "" {
+		fromVersion = unknownVersion
+	}
+
+	r.Log.Info("Upgrading extension MachineConfig", "from", fromVersion, "to", OperatorVersion)
+	for _, foundMc := range outdatedMcs {
+		err = r.Client.Update(context.TODO(), foundMc)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	status.UpgradeStatus = kataconfigurationv2.KataUpgradeStatus{
+		KataNodesStatus: kataconfigurationv2.KataNodesStatus{IsInProgress: true},
+		FromVersion:     fromVersion,
+		ToVersion:       OperatorVersion,
+	}
+	r.setBaseMcpGeneration(foundMcp)
+	status.NodeValidation = nil
+
+	return true, nil
+}
+
+// completeUpgrade records the new version once the pool rolled out the upgrade
+func (r *KataConfigOpenShiftReconciler) completeUpgrade() {
+	r.Log.Info("Upgrade completed", "version", r.kataConfig.Status.UpgradeStatus.ToVersion)
+	r.kataConfig.Status.InstalledVersion = r.kataConfig.Status.UpgradeStatus.ToVersion
+	r.kataConfig.Status.UpgradeStatus.IsInProgress = false
+}
+
+func (r *KataConfigOpenShiftReconciler) updateUpgradeStatus() (error, bool) {
+	var err error
+	err, nodeList := r.getNodes()
+	if err != nil {
+		return err, false
+	}
+
+	r.clearUpgradeStatus()
+
+	for _, node := range nodeList.Items {
+		if annotation, ok := node.Annotations["machineconfiguration.openshift.io/state"]; ok {
+			switch annotation {
+			case "Done":
+				err, r.kataConfig.Status.UpgradeStatus.Completed =
+					r.updateCompletedNodes(&node, r.kataConfig.Status.UpgradeStatus.Completed)
+			case "Degraded":
+				err, r.kataConfig.Status.UpgradeStatus.Failed.FailedNodesList =
+					r.updateFailedNodes(&node, r.kataConfig.Status.UpgradeStatus.Failed.FailedNodesList)
+			case "Working":
+				err, r.kataConfig.Status.UpgradeStatus.InProgress.InProgressNodesList =
+					r.updateInProgressNodes(&node, r.kataConfig.Status.UpgradeStatus.InProgress.InProgressNodesList)
+			default:
+				err = fmt.Errorf("Invalid machineconfig state: %v ", annotation)
+				r.Log.Error(err, "Error updating Upgrade status")
+			}
+		}
+	}
+	r.kataConfig.Status.UpgradeStatus.InProgress.InProgressNodesCount =
+		len(r.kataConfig.Status.UpgradeStatus.InProgress.InProgressNodesList)
+	r.kataConfig.Status.UpgradeStatus.Failed.FailedNodesCount =
+		len(r.kataConfig.Status.UpgradeStatus.Failed.FailedNodesList)
+	return err, true
+}
+
+func (r *KataConfigOpenShiftReconciler) clearUpgradeStatus() {
+	r.kataConfig.Status.UpgradeStatus.Completed.CompletedNodesList = nil
+	r.kataConfig.Status.UpgradeStatus.Completed.CompletedNodesCount = 0
+	r.kataConfig.Status.UpgradeStatus.InProgress.InProgressNodesList = nil
+	r.kataConfig.Status.UpgradeStatus.InProgress.InProgressNodesCount = 0
+	r.kataConfig.Status.UpgradeStatus.Failed.FailedNodesList = nil
+	r.kataConfig.Status.UpgradeStatus.Failed.FailedReason = ""
+	r.kataConfig.Status.UpgradeStatus.Failed.FailedNodesCount = 0
+}
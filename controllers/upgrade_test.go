@@ -0,0 +1,39 @@
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+var _ = Describe("Kata upgrade", func() {
+	Context("Comparing extension MachineConfigs", func() {
+		newMc := func(config string, extensions ...string) *mcfgv1.MachineConfig {
+			return &mcfgv1.MachineConfig{
+				Spec: mcfgv1.MachineConfigSpec{
+					Extensions: extensions,
+					Config:     runtime.RawExtension{Raw: []byte(config)},
+				},
+			}
+		}
+
+		It("Should ignore how the configuration is serialized", func() {
+			Expect(isMcContentEqual(
+				newMc(`{"ignition":{"version":"3.2.0"},"storage":{}}`, "sandboxed-containers"),
+				newMc(`{"storage":{}, "ignition": {"version": "3.2.0"}}`, "sandboxed-containers"))).Should(BeTrue())
+		})
+
+		It("Should detect a change of the extensions", func() {
+			Expect(isMcContentEqual(
+				newMc(`{"ignition":{"version":"3.2.0"}}`, "sandboxed-containers"),
+				newMc(`{"ignition":{"version":"3.2.0"}}`, "sandboxed-containers", "kernel-devel"))).Should(BeFalse())
+		})
+
+		It("Should detect a change of the configuration", func() {
+			Expect(isMcContentEqual(
+				newMc(`{"ignition":{"version":"3.2.0"}}`, "sandboxed-containers"),
+				newMc(`{"ignition":{"version":"3.1.0"}}`, "sandboxed-containers"))).Should(BeFalse())
+		})
+	})
+})
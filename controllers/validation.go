@@ -0,0 +1,273 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	kataconfigurationv2 "github.com/openshift/sandboxed-containers-operator/api/v2"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	validationName = "sandboxed-containers-validation"
+
+	// smokeTestTimeout is how long to wait for a smoke test pod to complete
+	smokeTestTimeout = 5 * time.Minute
+
+	// validationRetryBackoff is the delay before retrying a failed node validation. It doubles
+	// with each failed attempt, up to validationRetryMaxBackoff.
+	validationRetryBackoff    = time.Minute
+	validationRetryMaxBackoff = 30 * time.Minute
+)
+
+// smokeTestResult is the outcome of a smoke test pod on a node
+type smokeTestResult struct {
+	passed         bool
+	startupLatency time.Duration
+	message        string
+}
+
+// newSmokeTestPod returns a pod using the given RuntimeClass on the given node. It reports the
+// kernel it ran on as the termination message of its container.
+func newSmokeTestPod(runtimeClassName string, name string, nodeName string) *corev1.Pod {
+	return &corev1.Pod{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Pod",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name + "-" + nodeName,
+			Namespace: operatorNamespace,
+			Labels:    map[string]string{"app": name},
+		},
+		Spec: corev1.PodSpec{
+			NodeName:         nodeName,
+			RuntimeClassName: &runtimeClassName,
+			RestartPolicy:    corev1.RestartPolicyNever,
+			Tolerations:      []corev1.Toleration{{Operator: corev1.TolerationOpExists}},
+			Containers: []corev1.Container{{
+				Name:    "smoke-test",
+				Image:   preflightImage(),
+				Command: []string{"/bin/sh", "-c", "uname -r > /dev/termination-log"},
+			}},
+		},
+	}
+}
+
+// checkSmokeTestPod returns the result of the smoke test pod of a node, or nil while it runs.
+// A pod running in a virtual machine reports a kernel different from the one of the node.
+func checkSmokeTestPod(pod *corev1.Pod, node *corev1.Node) *smokeTestResult {
+	result := &smokeTestResult{}
+
+	switch pod.Status.Phase {
+	case corev1.PodSucceeded:
+		kernel := ""
+		for _, containerStatus := range pod.Status.ContainerStatuses {
+			if terminated := containerStatus.State.Terminated; terminated != nil {
+				kernel = strings.TrimSpace(terminated.Message)
+				if !terminated.StartedAt.IsZero() {
+					result.startupLatency = terminated.StartedAt.Sub(pod.CreationTimestamp.Time)
+				}
+			}
+		}
+		switch kernel {
+		case "":
+			result.message = "The smoke test pod did not report its kernel"
+		case node.Status.NodeInfo.KernelVersion:
+			result.message = fmt.Sprintf("The smoke test pod ran on the kernel %s of the node, not in a virtual machine", kernel)
+		default:
+			result.passed = true
+		}
+		return result
+	case corev1.PodFailed:
+		result.message = fmt.Sprintf("The smoke test pod failed: %s %s", pod.Status.Reason, pod.Status.Message)
+		return result
+	}
+
+	if time.Since(pod.CreationTimestamp.Time) > smokeTestTimeout {
+		result.message = fmt.Sprintf("The smoke test pod did not complete within %v", smokeTestTimeout)
+		return result
+	}
+	return nil
+}
+
+// runSmokeTestPods starts a smoke test pod using the given RuntimeClass on each of the given
+// nodes and collects the results of the completed ones. It returns true while some of the pods
+// are still running.
+func runSmokeTestPods(c client.Client, scheme *runtime.Scheme, log logr.Logger, kataConfig *kataconfigurationv2.KataConfig,
+	runtimeClassName string, name string, nodes []corev1.Node) (map[string]smokeTestResult, bool, error) {
+	results := map[string]smokeTestResult{}
+	isTestRunning := false
+
+	for i := range nodes {
+		node := &nodes[i]
+		pod := &corev1.Pod{}
+		err := c.Get(context.TODO(), types.NamespacedName{Name: name + "-" + node.Name, Namespace: operatorNamespace}, pod)
+		if err != nil && k8serrors.IsNotFound(err) {
+			pod = newSmokeTestPod(runtimeClassName, name, node.Name)
+			if err = controllerutil.SetControllerReference(kataConfig, pod, scheme); err != nil {
+				return nil, false, err
+			}
+			log.Info("Creating the smoke test pod", "pod.Name", pod.Name, "node", node.Name)
+			if err = c.Create(context.TODO(), pod); err != nil {
+				return nil, false, err
+			}
+			isTestRunning = true
+			continue
+		} else if err != nil {
+			return nil, false, err
+		}
+
+		result := checkSmokeTestPod(pod, node)
+		if result == nil {
+			isTestRunning = true
+			continue
+		}
+		results[node.Name] = *result
+	}
+
+	return results, isTestRunning, nil
+}
+
+// deleteSmokeTestPods removes the smoke test pods once their result is collected
+func deleteSmokeTestPods(c client.Client, name string) error {
+	pods := &corev1.PodList{}
+	if err := c.List(context.TODO(), pods, client.InNamespace(operatorNamespace),
+		client.MatchingLabels{"app": name}); err != nil {
+		return err
+	}
+	for i := range pods.Items {
+		if err := c.Delete(context.TODO(), &pods.Items[i]); err != nil && !k8serrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// nextValidationAttempt returns when the validation of a node that failed is retried
+func nextValidationAttempt(validation *kataconfigurationv2.NodeValidationStatus) time.Time {
+	if validation.LastAttemptTime == nil {
+		return time.Time{}
+	}
+	backoff := validationRetryBackoff
+	for i := int32(1); i < validation.Attempts && backoff < validationRetryMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > validationRetryMaxBackoff {
+		backoff = validationRetryMaxBackoff
+	}
+	return validation.LastAttemptTime.Add(backoff)
+}
+
+// validateNodes runs a validation pod on each node of the pool that was not validated since the
+// last rollout, or whose validation failed and is due for a retry, and records the result in the
+// status. It returns true once all the nodes of the pool have a result, along with the delay
+// until the next retry of a failed node, if any.
+//
+// The nodes are only validated once the pool rendered the configuration of its current node
+// selector, as the nodes that just joined the selector do not have the kata runtime yet.
+func (r *KataConfigOpenShiftReconciler) validateNodes(foundMcp *mcfgv1.MachineConfigPool) (bool, time.Duration, error) {
+	if foundMcp.Status.ObservedGeneration < foundMcp.Generation {
+		r.Log.Info("Waiting for the MachineConfigPool to observe its node selector before validating the nodes")
+		return false, 0, nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(r.poolNodeSelector())
+	if err != nil {
+		return false, 0, err
+	}
+	nodes := &corev1.NodeList{}
+	if err = r.Client.List(context.TODO(), nodes, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return false, 0, err
+	}
+
+	previous := map[string]kataconfigurationv2.NodeValidationStatus{}
+	for _, validation := range r.kataConfig.Status.NodeValidation {
+		previous[validation.Name] = validation
+	}
+
+	now := time.Now()
+	var pending []corev1.Node
+	var retryAfter time.Duration
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		validation, ok := previous[node.Name]
+		if ok && validation.Validated {
+			continue
+		}
+		if ok {
+			if next := nextValidationAttempt(&validation); next.After(now) {
+				if retryAfter == 0 || next.Sub(now) < retryAfter {
+					retryAfter = next.Sub(now)
+				}
+				continue
+			}
+		}
+
+		isOnConfig, err := r.isNodeOnPoolConfig(node, foundMcp)
+		if err != nil {
+			return false, 0, err
+		}
+		if !isOnConfig {
+			r.Log.Info("Waiting for the node to run the configuration of the pool before validating it", "node", node.Name)
+			return false, 0, nil
+		}
+		pending = append(pending, *node)
+	}
+	if len(pending) == 0 && len(previous) == len(nodes.Items) {
+		return true, retryAfter, nil
+	}
+
+	results, isTestRunning, err := runSmokeTestPods(r.Client, r.Scheme, r.Log, r.kataConfig,
+		r.kataConfig.GetRuntimeClassName(), r.instanceName(validationName), pending)
+	if err != nil {
+		return false, 0, err
+	}
+	if isTestRunning {
+		r.Log.Info("Waiting for the validation of the nodes")
+		return false, 0, nil
+	}
+
+	/* keep the results of the nodes still in the pool only */
+	var validations []kataconfigurationv2.NodeValidationStatus
+	for _, node := range nodes.Items {
+		validation, ok := previous[node.Name]
+		if result, isNew := results[node.Name]; isNew {
+			validation = kataconfigurationv2.NodeValidationStatus{
+				Name:            node.Name,
+				Validated:       result.passed,
+				StartupLatency:  &metav1.Duration{Duration: result.startupLatency},
+				Message:         result.message,
+				Attempts:        validation.Attempts + 1,
+				LastAttemptTime: &metav1.Time{Time: now},
+			}
+			if !result.passed {
+				r.Log.Info("The validation of the node failed, retrying later", "node", node.Name,
+					"attempts", validation.Attempts, "message", result.message)
+				if next := nextValidationAttempt(&validation).Sub(now); retryAfter == 0 || next < retryAfter {
+					retryAfter = next
+				}
+			}
+			ok = true
+		}
+		if ok {
+			validations = append(validations, validation)
+		}
+	}
+	r.kataConfig.Status.NodeValidation = validations
+
+	if err = deleteSmokeTestPods(r.Client, r.instanceName(validationName)); err != nil {
+		return false, 0, err
+	}
+	return true, retryAfter, nil
+}
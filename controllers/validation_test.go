@@ -0,0 +1,192 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	kataconfigurationv2 "github.com/openshift/sandboxed-containers-operator/api/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Node validation", func() {
+	var node *corev1.Node
+
+	BeforeEach(func() {
+		node = &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "worker0"},
+			Status: corev1.NodeStatus{
+				NodeInfo: corev1.NodeSystemInfo{KernelVersion: "4.18.0-305.el8.x86_64"},
+			},
+		}
+	})
+
+	newSmokeTestPod := func(phase corev1.PodPhase, message string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.Now()},
+			Status: corev1.PodStatus{
+				Phase: phase,
+				ContainerStatuses: []corev1.ContainerStatus{{
+					Name: "smoke-test",
+					State: corev1.ContainerState{
+						Terminated: &corev1.ContainerStateTerminated{Message: message},
+					},
+				}},
+			},
+		}
+	}
+
+	It("Should pass when the pod ran in a virtual machine", func() {
+		pod := newSmokeTestPod(corev1.PodSucceeded, "5.10.0-kata\n")
+		pod.Status.ContainerStatuses[0].State.Terminated.StartedAt =
+			metav1.NewTime(pod.CreationTimestamp.Add(3 * time.Second))
+		result := checkSmokeTestPod(pod, node)
+		Expect(result).ShouldNot(BeNil())
+		Expect(result.passed).Should(BeTrue())
+		Expect(result.startupLatency).Should(Equal(3 * time.Second))
+	})
+
+	It("Should fail when the pod ran on the kernel of the node", func() {
+		result := checkSmokeTestPod(newSmokeTestPod(corev1.PodSucceeded, "4.18.0-305.el8.x86_64\n"), node)
+		Expect(result.passed).Should(BeFalse())
+		Expect(result.message).Should(ContainSubstring("not in a virtual machine"))
+	})
+
+	It("Should fail when the pod failed", func() {
+		result := checkSmokeTestPod(newSmokeTestPod(corev1.PodFailed, ""), node)
+		Expect(result.passed).Should(BeFalse())
+	})
+
+	It("Should wait while the pod runs", func() {
+		Expect(checkSmokeTestPod(newSmokeTestPod(corev1.PodPending, ""), node)).Should(BeNil())
+	})
+
+	It("Should not be Ready until all the nodes are validated", func() {
+		kataConfig := &kataconfigurationv2.KataConfig{
+			Status: kataconfigurationv2.KataConfigStatus{
+				RuntimeClass:    "kata",
+				TotalNodesCount: 2,
+				NodeValidation: []kataconfigurationv2.NodeValidationStatus{
+					{Name: "worker0", Validated: true},
+				},
+			},
+		}
+		updateConditions(kataConfig)
+		Expect(meta.FindStatusCondition(kataConfig.Status.Conditions,
+			kataconfigurationv2.KataConfigReady).Reason).Should(Equal("Validating"))
+
+		kataConfig.Status.NodeValidation = append(kataConfig.Status.NodeValidation,
+			kataconfigurationv2.NodeValidationStatus{Name: "worker1", Validated: false, Message: "The smoke test pod failed"})
+		updateConditions(kataConfig)
+		Expect(meta.FindStatusCondition(kataConfig.Status.Conditions,
+			kataconfigurationv2.KataConfigReady).Reason).Should(Equal("ValidationFailed"))
+
+		kataConfig.Status.NodeValidation[1].Validated = true
+		updateConditions(kataConfig)
+		Expect(isConditionTrue(kataConfig, kataconfigurationv2.KataConfigReady)).Should(BeTrue())
+	})
+
+	It("Should retry a failed validation with a growing delay", func() {
+		lastAttempt := metav1.NewTime(time.Now())
+		validation := &kataconfigurationv2.NodeValidationStatus{Name: "worker0", Attempts: 1, LastAttemptTime: &lastAttempt}
+		Expect(nextValidationAttempt(validation)).Should(Equal(lastAttempt.Add(validationRetryBackoff)))
+		validation.Attempts = 3
+		Expect(nextValidationAttempt(validation)).Should(Equal(lastAttempt.Add(4 * validationRetryBackoff)))
+		validation.Attempts = 20
+		Expect(nextValidationAttempt(validation)).Should(Equal(lastAttempt.Add(validationRetryMaxBackoff)))
+	})
+
+	Context("Validating the nodes of the pool", func() {
+		var c client.Client
+		var r *KataConfigOpenShiftReconciler
+		var mcp *mcfgv1.MachineConfigPool
+
+		BeforeEach(func() {
+			scheme := runtime.NewScheme()
+			Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+			Expect(kataconfigurationv2.AddToScheme(scheme)).To(Succeed())
+			node.Labels = map[string]string{"node-role.kubernetes.io/kata-oc": ""}
+			node.Annotations = map[string]string{"machineconfiguration.openshift.io/currentConfig": "rendered-kata-oc-1"}
+			mcp = &mcfgv1.MachineConfigPool{ObjectMeta: metav1.ObjectMeta{Name: "kata-oc", Generation: 2}}
+			mcp.Spec.Configuration.Name = "rendered-kata-oc-1"
+			mcp.Status.ObservedGeneration = 2
+			c = fake.NewClientBuilder().WithScheme(scheme).WithObjects(node).Build()
+			r = &KataConfigOpenShiftReconciler{
+				Client: c,
+				Log:    ctrl.Log.WithName("test"),
+				Scheme: scheme,
+				kataConfig: &kataconfigurationv2.KataConfig{
+					ObjectMeta: metav1.ObjectMeta{Name: "example-kataconfig", UID: "uid"},
+					Spec: kataconfigurationv2.KataConfigSpec{
+						KataConfigPoolSelector: &metav1.LabelSelector{
+							MatchLabels: map[string]string{"node-role.kubernetes.io/kata-oc": ""},
+						},
+					},
+					Status: kataconfigurationv2.KataConfigStatus{PoolName: "kata-oc", RuntimeClass: "kata"},
+				},
+			}
+		})
+
+		listSmokeTestPods := func() []corev1.Pod {
+			pods := &corev1.PodList{}
+			Expect(c.List(context.TODO(), pods, client.InNamespace(operatorNamespace))).To(Succeed())
+			return pods.Items
+		}
+
+		It("Should wait for the pool to observe its node selector", func() {
+			mcp.Generation = 3
+			isValidated, _, err := r.validateNodes(mcp)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(isValidated).Should(BeFalse())
+			Expect(listSmokeTestPods()).Should(BeEmpty())
+		})
+
+		It("Should wait for the nodes to run the configuration of the pool", func() {
+			mcp.Spec.Configuration.Name = "rendered-kata-oc-2"
+			isValidated, _, err := r.validateNodes(mcp)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(isValidated).Should(BeFalse())
+			Expect(listSmokeTestPods()).Should(BeEmpty())
+		})
+
+		It("Should only retry a failed validation once its delay elapsed", func() {
+			lastAttempt := metav1.NewTime(time.Now())
+			r.kataConfig.Status.NodeValidation = []kataconfigurationv2.NodeValidationStatus{
+				{Name: "worker0", Validated: false, Attempts: 1, LastAttemptTime: &lastAttempt},
+			}
+			isValidated, retryAfter, err := r.validateNodes(mcp)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(isValidated).Should(BeTrue())
+			Expect(retryAfter).Should(BeNumerically(">", 0))
+			Expect(retryAfter).Should(BeNumerically("<=", validationRetryBackoff))
+			Expect(listSmokeTestPods()).Should(BeEmpty())
+
+			By("Running a new smoke test pod once the delay elapsed")
+			lastAttempt = metav1.NewTime(time.Now().Add(-2 * validationRetryBackoff))
+			isValidated, _, err = r.validateNodes(mcp)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(isValidated).Should(BeFalse())
+			Expect(listSmokeTestPods()).Should(HaveLen(1))
+		})
+
+		It("Should not retry a node that passed the validation", func() {
+			r.kataConfig.Status.NodeValidation = []kataconfigurationv2.NodeValidationStatus{
+				{Name: "worker0", Validated: true, Attempts: 1},
+			}
+			isValidated, retryAfter, err := r.validateNodes(mcp)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(isValidated).Should(BeTrue())
+			Expect(retryAfter).Should(BeZero())
+			Expect(listSmokeTestPods()).Should(BeEmpty())
+		})
+	})
+})
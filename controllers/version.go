@@ -0,0 +1,5 @@
+package controllers
+
+// OperatorVersion is the version of the operator, it is recorded in the KataConfig status
+// once the kata runtime is installed and drives upgrades. It is set at build time.
+var OperatorVersion = "1.0.1"
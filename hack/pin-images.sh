@@ -0,0 +1,34 @@
+#!/usr/bin/env bash
+#
+# Pins the images the operator runs by digest: it resolves each image with skopeo and writes
+# the digest reference into the environment of the manager deployment and into the related
+# images of the ClusterServiceVersion. Each argument is ENV_NAME=image, e.g.
+#
+#   hack/pin-images.sh PREFLIGHT_IMAGE=registry.access.redhat.com/ubi8/ubi-minimal:latest
+#
+# The related image of ENV_NAME is named after it, lower case without the _IMAGE suffix,
+# e.g. preflight for PREFLIGHT_IMAGE.
+
+set -euo pipefail
+
+MANAGER=config/manager/manager.yaml
+CSV=config/manifests/bases/sandboxed-containers-operator.clusterserviceversion.yaml
+
+for arg in "$@"; do
+	env_name=${arg%%=*}
+	image=${arg#*=}
+	related_name=$(echo "${env_name%_IMAGE}" | tr '[:upper:]_' '[:lower:]-')
+
+	# Drop the tag or digest of the image, the registry may have a port
+	repository=${image%@*}
+	if [[ ${repository##*/} == *:* ]]; then
+		repository=${repository%:*}
+	fi
+
+	digest=$(skopeo inspect --format '{{.Digest}}' "docker://${image}")
+	pinned="${repository}@${digest}"
+	echo "${env_name}: ${image} -> ${pinned}"
+
+	sed -i "/- name: ${env_name}\$/{n;s|value: .*|value: ${pinned}|}" "${MANAGER}"
+	sed -i "/- image: .*\$/{N;s|- image: .*\n\( *name: ${related_name}\)\$|- image: ${pinned}\n\1|}" "${CSV}"
+done
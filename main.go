@@ -30,6 +30,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	kataconfigurationv2 "github.com/openshift/sandboxed-containers-operator/api/v2"
 	"github.com/openshift/sandboxed-containers-operator/controllers"
 	// +kubebuilder:scaffold:imports
 )
@@ -47,6 +48,7 @@ func init() {
 	utilruntime.Must(mcfgapi.Install(scheme))
 
 	utilruntime.Must(kataconfigurationv1.AddToScheme(scheme))
+	utilruntime.Must(kataconfigurationv2.AddToScheme(scheme))
 	// +kubebuilder:scaffold:scheme
 }
 
@@ -79,17 +81,50 @@ func main() {
 		os.Exit(1)
 	}
 
+	isHostedControlPlane := false
 	if isOpenshift {
+		isHostedControlPlane, err = controllers.IsHostedControlPlane(mgr.GetAPIReader())
+		if err != nil {
+			setupLog.Error(err, "unable to detect the control plane topology")
+			os.Exit(1)
+		}
+	}
+
+	if isHostedControlPlane {
+		if err = (&controllers.KataConfigHostedReconciler{
+			KataConfigKubernetesReconciler: controllers.KataConfigKubernetesReconciler{
+				Client:   mgr.GetClient(),
+				Log:      ctrl.Log.WithName("controllers").WithName("KataConfig"),
+				Scheme:   mgr.GetScheme(),
+				Recorder: mgr.GetEventRecorderFor("kataconfig-controller"),
+			},
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create KataConfig controller for hosted control plane cluster", "controller", "KataConfig")
+			os.Exit(1)
+		}
+	} else if isOpenshift {
 		if err = (&controllers.KataConfigOpenShiftReconciler{
-			Client: mgr.GetClient(),
-			Log:    ctrl.Log.WithName("controllers").WithName("KataConfig"),
-			Scheme: mgr.GetScheme(),
+			Client:   mgr.GetClient(),
+			Log:      ctrl.Log.WithName("controllers").WithName("KataConfig"),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorderFor("kataconfig-controller"),
 		}).SetupWithManager(mgr); err != nil {
 			setupLog.Error(err, "unable to create KataConfig controller for OpenShift cluster", "controller", "KataConfig")
 			os.Exit(1)
 		}
+	} else {
+		kataconfigurationv2.SupportedHypervisors = controllers.KataDeployHypervisors
+		if err = (&controllers.KataConfigKubernetesReconciler{
+			Client:   mgr.GetClient(),
+			Log:      ctrl.Log.WithName("controllers").WithName("KataConfig"),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorderFor("kataconfig-controller"),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create KataConfig controller for Kubernetes cluster", "controller", "KataConfig")
+			os.Exit(1)
+		}
 	}
-	if err = (&kataconfigurationv1.KataConfig{}).SetupWebhookWithManager(mgr); err != nil {
+	if err = (&kataconfigurationv2.KataConfig{}).SetupWebhookWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create webhook", "webhook", "KataConfig")
 		os.Exit(1)
 	}